@@ -0,0 +1,41 @@
+package fault
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// LatencyAmplification delays the response of the next handler in proportion to how long the next handler took,
+// so that the slowdowns scale with the cost of each endpoint instead of adding a constant, as an overloaded
+// dependency does. For example, Factor 1 doubles the latency, and Factor 0.5 adds 50% to it.
+// The response is buffered until the delay ends, so the streaming responses are sent at once.
+type LatencyAmplification struct {
+	// Factor is the extra delay relative to the latency of the next handler. Must not be negative.
+	Factor float64
+	// Max caps the extra delay. Zero means no cap.
+	Max time.Duration
+}
+
+// Handle proxies the request to next, then delays its response.
+func (f *LatencyAmplification) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	start := CurrentClock().Now()
+	next.ServeHTTP(buf, r)
+
+	d := time.Duration(float64(CurrentClock().Now().Sub(start)) * f.Factor)
+	if f.Max > 0 && d > f.Max {
+		d = f.Max
+	}
+	sleep(d)
+	buf.copyTo(w, buf.body.Bytes())
+}
+
+// Validate checks Factor and Max are not negative.
+func (f *LatencyAmplification) Validate() error {
+	if math.IsNaN(f.Factor) || f.Factor < 0 {
+		return fmt.Errorf("factor %v must not be negative", f.Factor)
+	}
+	return validateDuration("max", f.Max)
+}
@@ -0,0 +1,157 @@
+package fault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AuthError is the authentication or authorization failure AuthFailure simulates.
+type AuthError int
+
+const (
+	// AuthExpiredToken responds 401 with the "invalid_token" challenge telling the token is expired,
+	// which the clients should handle by refreshing the token.
+	AuthExpiredToken AuthError = iota
+	// AuthInvalidToken responds 401 with the "invalid_token" challenge.
+	AuthInvalidToken
+	// AuthMissingCredentials responds 401 with the challenge without an error, as to a request without credentials.
+	AuthMissingCredentials
+	// AuthInsufficientScope responds 403 with the "insufficient_scope" challenge.
+	AuthInsufficientScope
+	// AuthForbidden responds 403 without a challenge, as to a principal not allowed to access the resource.
+	AuthForbidden
+)
+
+// authErrors is the names of AuthError, used by the effect.
+var authErrors = map[string]AuthError{
+	"expired_token":       AuthExpiredToken,
+	"invalid_token":       AuthInvalidToken,
+	"missing_credentials": AuthMissingCredentials,
+	"insufficient_scope":  AuthInsufficientScope,
+	"forbidden":           AuthForbidden,
+}
+
+// AuthBody is the shape of the body AuthFailure responds.
+type AuthBody int
+
+const (
+	// AuthBodyOAuth is the OAuth 2.0 error JSON, such as {"error":"invalid_token","error_description":"..."}.
+	AuthBodyOAuth AuthBody = iota
+	// AuthBodyProblem is the problem details JSON of RFC 7807 in application/problem+json.
+	AuthBodyProblem
+	// AuthBodyText is the plain text description.
+	AuthBodyText
+)
+
+// authBodies is the names of AuthBody, used by the effect.
+var authBodies = map[string]AuthBody{
+	"oauth":   AuthBodyOAuth,
+	"problem": AuthBodyProblem,
+	"text":    AuthBodyText,
+}
+
+// AuthFailure responds 401 with a WWW-Authenticate challenge or 403 as the authentication and authorization
+// servers do, without calling the next handler, so that the token refresh and the re-authentication flows
+// of the clients can be tested apart from the generic errors.
+type AuthFailure struct {
+	// Error is the failure to simulate.
+	Error AuthError
+	// Scheme is the scheme of the challenge. If empty, "Bearer" is used.
+	// The error parameters of the challenge are sent only with "Bearer", as RFC 6750 defines.
+	Scheme string
+	// Realm is the realm of the challenge. Optional.
+	Realm string
+	// Body is the shape of the body.
+	Body AuthBody
+}
+
+// Handle responds the failure.
+func (f *AuthFailure) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	code, errCode, desc := http.StatusUnauthorized, "invalid_token", ""
+	switch f.Error {
+	case AuthExpiredToken:
+		desc = "The access token expired"
+	case AuthInvalidToken:
+		desc = "The access token is invalid"
+	case AuthMissingCredentials:
+		errCode, desc = "", "Authentication is required"
+	case AuthInsufficientScope:
+		code, errCode, desc = http.StatusForbidden, "insufficient_scope", "The request requires higher privileges than provided by the access token"
+	case AuthForbidden:
+		code, errCode, desc = http.StatusForbidden, "access_denied", "Access to the resource is denied"
+	}
+
+	if f.Error != AuthForbidden {
+		w.Header().Set("WWW-Authenticate", f.challenge(errCode, desc))
+	}
+
+	var body []byte
+	switch f.Body {
+	case AuthBodyOAuth:
+		if errCode == "" {
+			errCode = "unauthorized"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		body, _ = json.Marshal(map[string]string{"error": errCode, "error_description": desc})
+	case AuthBodyProblem:
+		w.Header().Set("Content-Type", "application/problem+json")
+		body, _ = json.Marshal(map[string]interface{}{
+			"type":   "about:blank",
+			"title":  http.StatusText(code),
+			"status": code,
+			"detail": desc,
+		})
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		body = []byte(desc)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// challenge returns the value of WWW-Authenticate.
+func (f *AuthFailure) challenge(errCode, desc string) string {
+	scheme := f.Scheme
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	var params []string
+	if f.Realm != "" {
+		params = append(params, "realm="+strconv.Quote(f.Realm))
+	}
+	if scheme == "Bearer" && errCode != "" {
+		params = append(params, "error="+strconv.Quote(errCode), "error_description="+strconv.Quote(desc))
+	}
+	if len(params) == 0 {
+		return scheme
+	}
+	return scheme + " " + strings.Join(params, ", ")
+}
+
+// parseAuthError parses the name of an AuthError. An empty name is AuthExpiredToken.
+func parseAuthError(s string) (AuthError, error) {
+	if s == "" {
+		return AuthExpiredToken, nil
+	}
+	e, ok := authErrors[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid error %s", strconv.Quote(s))
+	}
+	return e, nil
+}
+
+// parseAuthBody parses the name of an AuthBody. An empty name is AuthBodyOAuth.
+func parseAuthBody(s string) (AuthBody, error) {
+	if s == "" {
+		return AuthBodyOAuth, nil
+	}
+	b, ok := authBodies[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid body %s", strconv.Quote(s))
+	}
+	return b, nil
+}
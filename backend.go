@@ -0,0 +1,76 @@
+package fault
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// ConfigBackend is a store the config is kept in, such as a KV store shared with the service config.
+// EtcdBackend and ConsulBackend are provided.
+type ConfigBackend interface {
+	// Watch calls update with the config document every time it changes, starting with the current one.
+	// Transient errors, such as a lost connection or a missing key, are passed to onError and the watch continues.
+	// update and onError must be called sequentially from one goroutine. Watch returns when ctx is done.
+	Watch(ctx context.Context, update func(doc []byte), onError func(err error))
+}
+
+// WatchBackend loads the config from the backend and returns a Reloader serving it,
+// then applies the changes live until ctx is done.
+// If the first load fails, an error is returned. Later failures are reported by logf and the current faults are kept;
+// if logf is nil, log.Printf is used.
+func WatchBackend(ctx context.Context, b ConfigBackend, logf func(format string, args ...interface{})) (_ *Reloader, err error) {
+	if logf == nil {
+		logf = log.Printf
+	}
+	source := fmt.Sprint(b)
+
+	// The watch lasts until ctx is done, unless the first load fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+
+	first := make(chan error, 1)
+	// r and reported are accessed only by the watching goroutine until the first result is sent.
+	var r *Reloader
+	reported := false
+	report := func(err error) {
+		if !reported {
+			reported = true
+			first <- err
+		}
+	}
+	go b.Watch(ctx, func(doc []byte) {
+		if r != nil {
+			r.reload(source, doc, logf)
+			return
+		}
+		if reported {
+			return
+		}
+		nr, err := newReloader(source, doc, logf)
+		if err == nil {
+			r = nr
+		}
+		report(err)
+	}, func(err error) {
+		if r != nil {
+			logf("fault: reload %s: %v", source, err)
+			return
+		}
+		report(err)
+	})
+
+	select {
+	case err := <-first:
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,186 @@
+package fault
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Baseline observes the latency of the next handler per route without injecting anything, so that the delays of
+// the experiments can be calibrated to the measured latency by BaselineDelay, such as the p99 times 3.
+// Put it inside the Handler injecting the delay, so that the injected delays are not observed:
+//
+//	baseline := &fault.Baseline{Pattern: fault.ServeMuxPattern(mux)}
+//	h := fault.New(&fault.BaselineDelay{Baseline: baseline, Percentile: 99, Factor: 3}, 0.1)
+//	http.ListenAndServe(":8080", h.Handler(baseline.Handler(mux)))
+//
+// The percentiles are estimated from up to 10000 samples per route, as Report does.
+// To use it from the configs and the overrides, register it by RegisterBaseline and refer to it by the name:
+// baseline_delay(baseline=api,percentile=99,factor=3).
+type Baseline struct {
+	// Pattern finds the route of a request. If nil, the route set by WithRoute is used.
+	// The requests without a route are observed together as the route "".
+	Pattern PatternFunc
+
+	mu     sync.Mutex
+	routes map[string]*baselineRoute
+}
+
+// baselineRoute is the observation of a route, with the samples sorted at most once a second for BaselineDelay.
+type baselineRoute struct {
+	obs      *observation
+	sorted   []time.Duration
+	sortedAt time.Time
+}
+
+// Handler wraps next, observing the latency of every request.
+func (b *Baseline) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.route(b.pattern(r)).obs.observe(next, w, r)
+	})
+}
+
+func (b *Baseline) pattern(r *http.Request) string {
+	if b.Pattern != nil {
+		return b.Pattern(r)
+	}
+	return RouteFrom(r.Context())
+}
+
+func (b *Baseline) route(pattern string) *baselineRoute {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.routes == nil {
+		b.routes = map[string]*baselineRoute{}
+	}
+	br, ok := b.routes[pattern]
+	if !ok {
+		br = &baselineRoute{obs: &observation{}}
+		b.routes[pattern] = br
+	}
+	return br
+}
+
+// Latency returns the distribution of the latency observed on the route.
+// It reports false if no request of the route has been observed.
+func (b *Baseline) Latency(route string) (LatencyReport, bool) {
+	b.mu.Lock()
+	br, ok := b.routes[route]
+	b.mu.Unlock()
+	if !ok {
+		return LatencyReport{}, false
+	}
+
+	br.obs.mu.Lock()
+	defer br.obs.mu.Unlock()
+	return br.obs.latency(), br.obs.requests > 0
+}
+
+// Latencies returns the distributions of the latency observed per route.
+func (b *Baseline) Latencies() map[string]LatencyReport {
+	b.mu.Lock()
+	routes := make([]string, 0, len(b.routes))
+	for p := range b.routes {
+		routes = append(routes, p)
+	}
+	b.mu.Unlock()
+
+	ls := make(map[string]LatencyReport, len(routes))
+	for _, p := range routes {
+		if l, ok := b.Latency(p); ok {
+			ls[p] = l
+		}
+	}
+	return ls
+}
+
+var (
+	baselinesMu sync.RWMutex
+	baselines   = map[string]*Baseline{}
+)
+
+// RegisterBaseline makes the Baseline available by the given name to the baseline_delay effect, so that the configs
+// and the overrides can calibrate the delays to it.
+// If RegisterBaseline is called twice with the same name or if b is nil, it panics.
+func RegisterBaseline(name string, b *Baseline) {
+	baselinesMu.Lock()
+	defer baselinesMu.Unlock()
+	if b == nil {
+		panic("fault: RegisterBaseline baseline is nil")
+	}
+	if _, dup := baselines[name]; dup {
+		panic("fault: RegisterBaseline called twice for baseline " + name)
+	}
+	baselines[name] = b
+}
+
+// LookupBaseline returns the Baseline registered by the name.
+func LookupBaseline(name string) (*Baseline, bool) {
+	baselinesMu.RLock()
+	defer baselinesMu.RUnlock()
+	b, ok := baselines[name]
+	return b, ok
+}
+
+// percentile returns the p-th percentile of the latency observed on the route.
+func (b *Baseline) percentile(route string, p float64, now time.Time) (time.Duration, bool) {
+	br := b.route(route)
+	br.obs.mu.Lock()
+	defer br.obs.mu.Unlock()
+	if len(br.obs.samples) == 0 {
+		return 0, false
+	}
+	if br.sorted == nil || now.Sub(br.sortedAt) >= time.Second {
+		br.sorted, br.sortedAt = br.obs.sorted(), now
+	}
+	return percentile(br.sorted, p), true
+}
+
+// BaselineDelay delays the request by the latency observed by Baseline on its route multiplied by Factor,
+// such as the p99 times 3, so that the experiments are calibrated to the real latency of each route.
+// The requests of the routes not observed yet are delayed by Fallback.
+type BaselineDelay struct {
+	// Baseline observes the latency. Required.
+	Baseline *Baseline
+	// Percentile is the percentile of the observed latency, in (0, 100]. If zero, 99 is used.
+	Percentile float64
+	// Factor multiplies the percentile. If zero, 1 is used.
+	Factor float64
+	// Fallback is the delay of the requests of the routes not observed yet.
+	Fallback time.Duration
+}
+
+// Handle delays the request by the observed latency, then proxies it to next.
+func (f *BaselineDelay) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	p := f.Percentile
+	if p == 0 {
+		p = 99
+	}
+	factor := f.Factor
+	if factor == 0 {
+		factor = 1
+	}
+
+	d := f.Fallback
+	if l, ok := f.Baseline.percentile(f.Baseline.pattern(r), p, CurrentClock().Now()); ok {
+		d = time.Duration(float64(l) * factor)
+	}
+	sleep(d)
+	next.ServeHTTP(w, r)
+}
+
+// Validate checks Baseline is set, Percentile is in (0, 100] if set, and Factor and Fallback are not negative.
+func (f *BaselineDelay) Validate() error {
+	if f.Baseline == nil {
+		return fmt.Errorf("baseline is required")
+	}
+	if math.IsNaN(f.Percentile) || f.Percentile < 0 || f.Percentile > 100 {
+		return fmt.Errorf("percentile %v must be in (0, 100]", f.Percentile)
+	}
+	if math.IsNaN(f.Factor) || f.Factor < 0 {
+		return fmt.Errorf("factor %v must not be negative", f.Factor)
+	}
+	return validateDuration("fallback", f.Fallback)
+}
@@ -0,0 +1,88 @@
+package fault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The Baselines are registered once, so that the tests can run with -count.
+var testBaseline = &Baseline{}
+
+func init() {
+	RegisterBaseline("test", testBaseline)
+	RegisterBaseline("dup", &Baseline{})
+}
+
+func TestBaselineDelayEffect(t *testing.T) {
+	b := testBaseline
+
+	e, ok := LookupEffect("baseline_delay")
+	if !ok {
+		t.Fatal("baseline_delay is not registered")
+	}
+	f, err := e(map[string]string{"baseline": "test", "percentile": "90", "factor": "3", "fallback": "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &BaselineDelay{Baseline: b, Percentile: 90, Factor: 3, Fallback: time.Second}
+	if got := f.(*BaselineDelay); *got != *want {
+		t.Errorf("effect = %+v, want %+v", got, want)
+	}
+
+	for _, tc := range []struct {
+		params map[string]string
+		err    string
+	}{
+		{map[string]string{}, "baseline is required"},
+		{map[string]string{"baseline": "nope"}, `unknown baseline "nope"`},
+		{map[string]string{"baseline": "test", "factor": "x"}, "factor"},
+		{map[string]string{"baseline": "test", "extra": "1"}, "extra"},
+	} {
+		if _, err := e(tc.params); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("effect(%v) = %v, want an error containing %q", tc.params, err, tc.err)
+		}
+	}
+}
+
+func TestBaselineDelayOverride(t *testing.T) {
+	clk := NewFakeClock(epoch)
+	SetClock(clk)
+	defer SetClock(nil)
+
+	h := New(nop, 0)
+	h.Override = &Override{Secret: "s"}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Fault-Inject", "baseline_delay(baseline=test,fallback=1m)")
+	r.Header.Set("X-Fault-Secret", "s")
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.Handler(next).ServeHTTP(w, r)
+		close(done)
+	}()
+	// No latency of the route is observed, so the request is delayed by the fallback.
+	waitSleepers(t, clk, 1)
+	clk.Add(time.Minute)
+	<-done
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRegisterBaselinePanics(t *testing.T) {
+	for name, b := range map[string]*Baseline{"dup": {}, "nil": nil} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterBaseline(%q) did not panic", name)
+				}
+			}()
+			RegisterBaseline(name, b)
+		}()
+	}
+}
@@ -0,0 +1,66 @@
+package fault
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// LargeBody makes the response of the next handler very large, as a misbehaving upstream does, so that the max body
+// size enforcement and the memory behavior of the clients can be tested.
+// By default, MB megabytes of data are appended to the body; if Replace is true, the body is replaced with them.
+// The data are streamed, so the fault itself does not hold them in memory.
+type LargeBody struct {
+	// MB is the size of the data in megabytes. Must be positive.
+	MB int
+	// Replace replaces the body instead of appending to it.
+	Replace bool
+}
+
+// paddingChunk is the unit of the data LargeBody writes.
+var paddingChunk = bytes.Repeat([]byte("x"), 32*1024)
+
+// Handle proxies the request to next, then sends the response with the large body.
+func (f *LargeBody) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	next.ServeHTTP(buf, r)
+
+	size := int64(f.MB) << 20
+	h := w.Header()
+	for k, vs := range buf.header {
+		h[k] = vs
+	}
+	if f.Replace {
+		h.Del("Content-Encoding")
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("Content-Length", strconv.FormatInt(size, 10))
+	} else if h.Get("Content-Length") != "" {
+		h.Set("Content-Length", strconv.FormatInt(int64(buf.body.Len())+size, 10))
+	}
+	w.WriteHeader(buf.code)
+	if !f.Replace {
+		if _, err := w.Write(buf.body.Bytes()); err != nil {
+			return
+		}
+	}
+	for size > 0 {
+		chunk := paddingChunk
+		if size < int64(len(chunk)) {
+			chunk = chunk[:size]
+		}
+		n, err := w.Write(chunk)
+		if err != nil {
+			return
+		}
+		size -= int64(n)
+	}
+}
+
+// Validate checks MB is positive.
+func (f *LargeBody) Validate() error {
+	if f.MB <= 0 {
+		return fmt.Errorf("mb %d must be positive", f.MB)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker behaves like an upstream protecting itself with a circuit breaker, which makes the compound
+// failures the dependencies show: while closed, it fails ErrorRatio of the requests with Fault; after Threshold
+// failures it opens, and fast-fails every request with 503 Service Unavailable for Cooldown; then it half-opens,
+// and lets HalfOpenRequests trial requests through. If a trial fails by ErrorRatio, it opens again;
+// once all the trials pass, it closes.
+// The state is kept across the requests, so the Handler injects it at the ratio 1 to see every request:
+//
+//	fault.New(&fault.CircuitBreaker{ErrorRatio: 0.2, Threshold: 10, Cooldown: 30 * time.Second}, 1)
+type CircuitBreaker struct {
+	// ErrorRatio is the ratio of the requests failing while closed or half-open, in [0, 1].
+	ErrorRatio float64
+	// Fault fails the requests while closed or half-open. If nil, Error with 500 Internal Server Error is used.
+	Fault Fault
+	// Threshold is the number of the failures opening the breaker. Must be positive.
+	Threshold int
+	// Cooldown is how long the breaker stays open. If zero, 30 seconds is used.
+	Cooldown time.Duration
+	// HalfOpenRequests is the number of the trial requests closing the breaker. If zero, 1 is used.
+	HalfOpenRequests int
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trials   int
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// Handle fails, fast-fails or passes the request by the state of the breaker.
+func (f *CircuitBreaker) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	now := CurrentClock().Now()
+	state, retryAfter, fail := f.admit(now)
+	switch {
+	case state == breakerOpen:
+		// Round up so that the client never retries before the breaker half-opens.
+		w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+		writeError(w, r, http.StatusServiceUnavailable, "fault: pseudo circuit breaker is open", false)
+	case fail:
+		failure := f.Fault
+		if failure == nil {
+			failure = &Error{StatusCode: http.StatusInternalServerError}
+		}
+		failure.Handle(w, r, next)
+	default:
+		next.ServeHTTP(w, r)
+	}
+}
+
+// Validate checks ErrorRatio is in [0, 1], Threshold is positive, the others are not negative, and Fault is valid.
+func (f *CircuitBreaker) Validate() error {
+	if err := validateRatio("error ratio", f.ErrorRatio); err != nil {
+		return err
+	}
+	if f.Threshold <= 0 {
+		return fmt.Errorf("threshold %d must be positive", f.Threshold)
+	}
+	if err := validateDuration("cooldown", f.Cooldown); err != nil {
+		return err
+	}
+	if err := validateCount("half-open requests", f.HalfOpenRequests); err != nil {
+		return err
+	}
+	if f.Fault != nil {
+		return validateFault(f.Fault)
+	}
+	return nil
+}
+
+// admit updates the state of the breaker for a request at now, and returns the state the request is admitted in,
+// how long the breaker stays open if it is open, and whether the request fails.
+func (f *CircuitBreaker) admit(now time.Time) (breakerState, time.Duration, bool) {
+	cooldown := f.Cooldown
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	halfOpen := f.HalfOpenRequests
+	if halfOpen <= 0 {
+		halfOpen = 1
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state == breakerOpen {
+		if d := f.openedAt.Add(cooldown).Sub(now); d > 0 {
+			return breakerOpen, d, false
+		}
+		f.state, f.trials = breakerHalfOpen, 0
+	}
+
+	state := f.state
+	fail := randomFloat() < f.ErrorRatio
+	switch {
+	case state == breakerHalfOpen && fail:
+		f.state, f.openedAt = breakerOpen, now
+	case state == breakerHalfOpen:
+		if f.trials++; f.trials >= halfOpen {
+			f.state, f.failures = breakerClosed, 0
+		}
+	case fail:
+		if f.failures++; f.failures >= f.Threshold {
+			f.state, f.openedAt = breakerOpen, now
+		}
+	}
+	return state, 0, fail
+}
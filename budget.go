@@ -0,0 +1,67 @@
+package fault
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget caps the number of injections, so that the absolute damage is bounded even when the traffic spikes.
+// Once the budget is exhausted, the fault silently stops being injected until the interval passes or Reset is called.
+// A Budget must not be copied after first use, and must not be shared among Handlers unless intended.
+type Budget struct {
+	// Rate is the maximum number of injections per Interval. Zero means no limit.
+	Rate int
+	// Interval is the length of the window Rate applies to. If zero, 1 minute is used.
+	Interval time.Duration
+	// Total is the maximum number of injections until Reset is called. Zero means no limit.
+	Total int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windowCount int
+	total       int
+}
+
+// take consumes one injection from the budget. It returns false if the budget is exhausted.
+func (b *Budget) take(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Total > 0 && b.total >= b.Total {
+		return false
+	}
+
+	if b.Rate > 0 {
+		interval := b.Interval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		if now.Sub(b.windowStart) >= interval {
+			b.windowStart = now
+			b.windowCount = 0
+		}
+		if b.windowCount >= b.Rate {
+			return false
+		}
+		b.windowCount++
+	}
+
+	b.total++
+	return true
+}
+
+// Used returns the number of injections consumed since the last Reset.
+func (b *Budget) Used() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.total
+}
+
+// Reset restores the budget.
+func (b *Budget) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.windowStart = time.Time{}
+	b.windowCount = 0
+	b.total = 0
+}
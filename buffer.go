@@ -0,0 +1,94 @@
+package fault
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// responseBuffer is a ResponseWriter buffering the response of the next handler,
+// so that faults can rewrite it before it is sent.
+type responseBuffer struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: http.Header{}, code: http.StatusOK}
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(code int) {
+	if b.wroteHeader {
+		return
+	}
+	b.wroteHeader = true
+	b.code = code
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// copyTo writes the buffered response with the body to w.
+// The Content-Length header is updated in case the body is rewritten.
+func (b *responseBuffer) copyTo(w http.ResponseWriter, body []byte) {
+	h := w.Header()
+	for k, vs := range b.header {
+		h[k] = vs
+	}
+	if h.Get("Content-Length") != "" {
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	w.WriteHeader(b.code)
+	w.Write(body)
+}
+
+// headerWriter is a ResponseWriter letting faults rewrite the header of the response of the next handler
+// when it is written, without buffering the body.
+type headerWriter struct {
+	http.ResponseWriter
+	rewrite     func(h http.Header)
+	wroteHeader bool
+}
+
+func (w *headerWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.rewrite(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *headerWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("fault: %T does not support hijacking", w.ResponseWriter)
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,32 @@
+package fault
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// Bypass lets a request opt out of the injection by a secret header, so that smoke tests after a deploy and
+// debugging traffic of on-call engineers are never injected even while an experiment runs.
+// A bypassed request is never injected, even if it also has an Override or Envoy header.
+//
+//	h.Bypass = &fault.Bypass{Secret: os.Getenv("FAULT_BYPASS_SECRET")}
+//
+// and send "X-Fault-Bypass: <secret>" with the requests.
+type Bypass struct {
+	// Header is the name of the header carrying the Secret. If empty, "X-Fault-Bypass" is used.
+	Header string
+	// Secret is the value of the header to bypass. Required; if empty, no request bypasses.
+	Secret string
+}
+
+// bypassed reports whether the request has the secret.
+func (b *Bypass) bypassed(r *http.Request) bool {
+	if b.Secret == "" {
+		return false
+	}
+	header := b.Header
+	if header == "" {
+		header = "X-Fault-Bypass"
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(header)), []byte(b.Secret)) == 1
+}
@@ -0,0 +1,53 @@
+package fault
+
+import "net/http"
+
+// Middleware wraps an http.Handler with faults, such as *Handler, Chain and *Reloader.
+type Middleware interface {
+	Handler(next http.Handler) http.Handler
+}
+
+// Chain applies several Handlers to a request in order.
+// Each Handler decides on its own whether to inject its fault. To inject several faults together by one decision,
+// use All instead.
+type Chain []*Handler
+
+// Handler wraps the given handler with every Handler in the chain.
+// The first Handler in the chain sees the request first.
+func (c Chain) Handler(next http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		next = c[i].Handler(next)
+	}
+
+	return next
+}
+
+// All returns a fault applying every fault to the request in order, so that a compound failure is injected
+// coherently by one decision of the Handler. For example, the request below is delayed, then its response is
+// replaced with 503 together:
+//
+//	fault.New(fault.All(&fault.Delay{Duration: time.Second}, &fault.Error{StatusCode: 503}), 0.1)
+//
+// Each fault sees the rest as its next handler, so a fault not calling next, such as Error, ends the sequence.
+// The effects described by ParseEffects, such as "delay=1s;error=503", are applied in the same way.
+func All(faults ...Fault) Fault {
+	return sequence(faults)
+}
+
+// Steps returns the faults f applies in order, for the adapters applying the faults on their own:
+// the faults of All and of the effects described by ParseEffects, with the faults given to Named unwrapped.
+// Other faults are returned as the only step.
+func Steps(f Fault) []Fault {
+	switch f := f.(type) {
+	case sequence:
+		var steps []Fault
+		for _, s := range f {
+			steps = append(steps, Steps(s)...)
+		}
+		return steps
+	case *namedFault:
+		return Steps(f.f)
+	default:
+		return []Fault{f}
+	}
+}
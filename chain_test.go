@@ -0,0 +1,59 @@
+package fault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSteps(t *testing.T) {
+	d, e, a := &Delay{}, &Error{StatusCode: 503}, &Abort{}
+	for _, tc := range []struct {
+		name string
+		f    Fault
+		want []Fault
+	}{
+		{"single", d, []Fault{d}},
+		{"all", All(d, e), []Fault{d, e}},
+		{"nested", All(d, Named("x", All(e, a))), []Fault{d, e, a}},
+		{"named", Named("x", e), []Fault{e}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Steps(tc.f); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Steps() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateSupported(t *testing.T) {
+	onlyErrors := func(f Fault) bool {
+		for _, s := range Steps(f) {
+			if _, ok := s.(*Error); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	sampled := 0
+	h := New(&Delay{}, 1)
+	h.Sampler = SamplerFunc(func(_ *http.Request, _ float64) bool {
+		sampled++
+		return true
+	})
+	if o := h.EvaluateSupported(httptest.NewRequest("GET", "/", nil), onlyErrors); o.Decision != Pass || o.Reason != "unsupported" {
+		t.Errorf("Outcome = %v %q, want pass unsupported", o.Decision, o.Reason)
+	}
+	if sampled != 0 || h.Stats().Injected != 0 {
+		t.Errorf("sampled %d, injected %d, want neither", sampled, h.Stats().Injected)
+	}
+
+	h.Override = &Override{Secret: "s"}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Fault-Inject", "error=503;error=502")
+	r.Header.Set("X-Fault-Secret", "s")
+	if o := h.EvaluateSupported(r, onlyErrors); o.Decision != Inject || o.Reason != "override" {
+		t.Errorf("Outcome = %v %q, want inject override", o.Decision, o.Reason)
+	}
+}
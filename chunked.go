@@ -0,0 +1,101 @@
+package fault
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ChunkedError is how MalformedChunked breaks the chunked transfer encoding.
+type ChunkedError int
+
+const (
+	// ChunkedBadSize sends a chunk size which is not a hex number.
+	ChunkedBadSize ChunkedError = iota
+	// ChunkedMissingTerminal closes the connection without sending the last chunk.
+	ChunkedMissingTerminal
+	// ChunkedSizeMismatch sends a chunk with more data than its size.
+	ChunkedSizeMismatch
+)
+
+// chunkedErrors is the names of ChunkedError, used by the effect.
+var chunkedErrors = map[string]ChunkedError{
+	"bad_size":         ChunkedBadSize,
+	"missing_terminal": ChunkedMissingTerminal,
+	"size_mismatch":    ChunkedSizeMismatch,
+}
+
+// MalformedChunked sends the response of the next handler in an intentionally broken chunked transfer encoding,
+// so that the parsers of the proxies and the clients can be tested.
+// It hijacks the connection to write the raw response, so it works only on HTTP/1.x.
+// On HTTP/2, which has no chunked encoding, the request is aborted as Abort does.
+type MalformedChunked struct {
+	// Error is how the encoding is broken.
+	Error ChunkedError
+}
+
+// Handle proxies the request to next, then sends the response in the broken encoding.
+func (f *MalformedChunked) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	next.ServeHTTP(buf, r)
+
+	writeRaw(w, r, func(bw *bufio.Writer) {
+		f.write(bw, buf)
+	})
+}
+
+// writeRaw hijacks the connection, then lets write send a raw HTTP/1.1 response, then closes the connection.
+// If the connection cannot be hijacked, such as on HTTP/2, the request is aborted as Abort does.
+func writeRaw(w http.ResponseWriter, r *http.Request, write func(bw *bufio.Writer)) {
+	hj, ok := w.(http.Hijacker)
+	if !ok || r.ProtoMajor != 1 {
+		panic(http.ErrAbortHandler)
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	defer conn.Close()
+
+	write(rw.Writer)
+	rw.Flush()
+}
+
+// writeRawHeader writes the status line and the header of an HTTP/1.1 response closing the connection.
+func writeRawHeader(w *bufio.Writer, code int, h http.Header) {
+	fmt.Fprintf(w, "HTTP/1.1 %03d %s\r\n", code, http.StatusText(code))
+	h.Set("Connection", "close")
+	h.Write(w)
+	w.WriteString("\r\n")
+}
+
+func (f *MalformedChunked) write(w *bufio.Writer, buf *responseBuffer) {
+	buf.header.Del("Content-Length")
+	buf.header.Set("Transfer-Encoding", "chunked")
+	writeRawHeader(w, buf.code, buf.header)
+
+	body := buf.body.Bytes()
+	switch f.Error {
+	case ChunkedBadSize:
+		fmt.Fprintf(w, "zz\r\n%s\r\n0\r\n\r\n", body)
+	case ChunkedMissingTerminal:
+		if len(body) > 0 {
+			fmt.Fprintf(w, "%x\r\n%s\r\n", len(body), body)
+		}
+	case ChunkedSizeMismatch:
+		fmt.Fprintf(w, "%x\r\n%s\r\n0\r\n\r\n", len(body)/2, body)
+	}
+}
+
+// parseChunkedError parses the name of a ChunkedError. An empty name is ChunkedBadSize.
+func parseChunkedError(s string) (ChunkedError, error) {
+	if s == "" {
+		return ChunkedBadSize, nil
+	}
+	e, ok := chunkedErrors[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid error %s", strconv.Quote(s))
+	}
+	return e, nil
+}
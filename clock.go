@@ -0,0 +1,168 @@
+package fault
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of the time of the package: the sleeps of the faults, the schedules, the TTLs and
+// the timestamps of the reports go through it, so that the tests of the time-based behaviors can run without
+// waiting for the real time to pass. See SetClock.
+//
+// The real time is still used for what happens on the real resources, such as the timeouts of the calls to
+// the counters and the webhooks, the CPU burned by CPU and the overhead measured by MaxOverhead.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep pauses for d, or returns the error of ctx if it is done earlier.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// RealClock is the Clock of the real time, which is used by default.
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep pauses for d by a timer.
+func (RealClock) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// clockHolder wraps the Clock so that the different types of Clocks can be stored in the atomic.Value.
+type clockHolder struct {
+	Clock
+}
+
+var globalClock atomic.Value
+
+// SetClock replaces the Clock of the package. If c is nil, RealClock is restored.
+// It is intended for the tests, and must not be called while the faults are being injected.
+func SetClock(c Clock) {
+	if c == nil {
+		c = RealClock{}
+	}
+	globalClock.Store(clockHolder{c})
+}
+
+// CurrentClock returns the Clock of the package, for the adapters applying the faults on their own.
+func CurrentClock() Clock {
+	if h, ok := globalClock.Load().(clockHolder); ok {
+		return h.Clock
+	}
+	return RealClock{}
+}
+
+// sleep sleeps for d by the Clock.
+func sleep(d time.Duration) {
+	CurrentClock().Sleep(context.Background(), d)
+}
+
+// sleepContext sleeps for d by the Clock, or returns the error of ctx if it is done earlier.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	return CurrentClock().Sleep(ctx, d)
+}
+
+// FakeClock is a Clock whose time passes only by Add, for the tests:
+//
+//	clk := fault.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//	fault.SetClock(clk)
+//	defer fault.SetClock(nil)
+//
+//	go h.Handler(next).ServeHTTP(w, r) // h injects Delay{Duration: time.Minute}
+//	for clk.Sleepers() == 0 {
+//		runtime.Gosched()
+//	}
+//	clk.Add(time.Minute)
+type FakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	sleepers []*fakeSleeper
+}
+
+// fakeSleeper is a Sleep waiting for the FakeClock to reach until.
+type fakeSleeper struct {
+	until time.Time
+	done  chan struct{}
+}
+
+// NewFakeClock returns a FakeClock starting at t.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep pauses until the clock is advanced by d, or returns the error of ctx if it is done earlier.
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	c.mu.Lock()
+	s := &fakeSleeper{until: c.now.Add(d), done: make(chan struct{})}
+	c.sleepers = append(c.sleepers, s)
+	c.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.remove(s)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Add advances the clock by d, waking up the Sleeps which end by then, in the order of their ends.
+func (c *FakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.sleepers, func(i, j int) bool {
+		return c.sleepers[i].until.Before(c.sleepers[j].until)
+	})
+	for len(c.sleepers) > 0 && !c.sleepers[0].until.After(c.now) {
+		close(c.sleepers[0].done)
+		c.sleepers = c.sleepers[1:]
+	}
+}
+
+// Sleepers returns the number of the Sleeps waiting for the clock to be advanced, so that the tests can wait
+// for the goroutines to sleep before calling Add.
+func (c *FakeClock) Sleepers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sleepers)
+}
+
+func (c *FakeClock) remove(s *fakeSleeper) {
+	for i, v := range c.sleepers {
+		if v == s {
+			c.sleepers = append(c.sleepers[:i], c.sleepers[i+1:]...)
+			return
+		}
+	}
+}
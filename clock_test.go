@@ -0,0 +1,125 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) // Monday
+
+// waitSleepers waits for n goroutines to sleep on clk.
+func waitSleepers(t *testing.T, clk *FakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clk.Sleepers() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Sleepers() = %d, want %d", clk.Sleepers(), n)
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	clk := NewFakeClock(epoch)
+	done := make(chan error, 1)
+	go func() { done <- clk.Sleep(context.Background(), time.Minute) }()
+	waitSleepers(t, clk, 1)
+
+	clk.Add(30 * time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("Sleep returned %v before the clock reached the end", err)
+	default:
+	}
+
+	clk.Add(30 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("Sleep() = %v, want nil", err)
+	}
+	if got, want := clk.Now(), epoch.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+	if n := clk.Sleepers(); n != 0 {
+		t.Errorf("Sleepers() = %d, want 0", n)
+	}
+}
+
+func TestFakeClockSleepCanceled(t *testing.T) {
+	clk := NewFakeClock(epoch)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- clk.Sleep(ctx, time.Minute) }()
+	waitSleepers(t, clk, 1)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Sleep() = %v, want %v", err, context.Canceled)
+	}
+	if n := clk.Sleepers(); n != 0 {
+		t.Errorf("Sleepers() = %d, want 0", n)
+	}
+}
+
+func TestFakeClockDelay(t *testing.T) {
+	clk := NewFakeClock(epoch)
+	SetClock(clk)
+	defer SetClock(nil)
+
+	h := New(&Delay{Duration: time.Minute}, 1)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.Handler(next).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		close(done)
+	}()
+	waitSleepers(t, clk, 1)
+
+	clk.Add(time.Minute)
+	<-done
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestHandlerTimeBox(t *testing.T) {
+	defer SetClock(nil)
+
+	for _, tc := range []struct {
+		name     string
+		start    time.Time
+		ttl      time.Duration
+		schedule Schedule
+		elapsed  time.Duration
+		reason   string
+	}{
+		{"active", time.Time{}, time.Hour, nil, 59 * time.Minute, "sampled"},
+		{"expired", time.Time{}, time.Hour, nil, time.Hour, "expired"},
+		{"not started", epoch.Add(time.Hour), 0, nil, 59 * time.Minute, "not started"},
+		{"started", epoch.Add(time.Hour), time.Hour, nil, 90 * time.Minute, "sampled"},
+		{"expired after start", epoch.Add(time.Hour), time.Hour, nil, 2 * time.Hour, "expired"},
+		{"in window", time.Time{}, 0, &Window{From: 14 * time.Hour, To: 15 * time.Hour, Location: time.UTC}, 14 * time.Hour, "sampled"},
+		{"off window", time.Time{}, 0, &Window{From: 14 * time.Hour, To: 15 * time.Hour, Location: time.UTC}, 15 * time.Hour, "off schedule"},
+		{"window on another weekday", time.Time{}, 0, &Window{Weekdays: []time.Weekday{time.Tuesday}, From: 14 * time.Hour, To: 15 * time.Hour, Location: time.UTC}, 14 * time.Hour, "off schedule"},
+		{"window over midnight", time.Time{}, 0, &Window{Weekdays: []time.Weekday{time.Monday}, From: 23 * time.Hour, To: time.Hour, Location: time.UTC}, 24*time.Hour + 30*time.Minute, "sampled"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := NewFakeClock(epoch)
+			SetClock(clk)
+
+			h := New(&Delay{}, 1)
+			h.Start = tc.start
+			h.TTL = tc.ttl
+			h.Schedule = tc.schedule
+			clk.Add(tc.elapsed)
+
+			if o := h.Evaluate(httptest.NewRequest("GET", "/", nil)); o.Reason != tc.reason {
+				t.Errorf("Reason = %q, want %q", o.Reason, tc.reason)
+			}
+		})
+	}
+}
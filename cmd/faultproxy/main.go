@@ -0,0 +1,107 @@
+// Command faultproxy is a reverse proxy injecting faults into the requests to an upstream,
+// so that services not written in Go can use the faults of this package.
+//
+// Usage:
+//
+//	faultproxy -upstream http://localhost:8080 [-listen :9090] [-config faults.json [-watch 5s]]
+//	faultproxy -upstream http://localhost:8080 -fault "delay=2s;error=503" -ratio 0.1
+//
+// With -config, the faults are loaded from the config file (see fault.Config).
+// If -watch is also given, the file is watched and changes are applied live.
+// With -fault, the fault is described in the same syntax as the X-Fault-Inject header (see fault.Override).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hidetatz/fault"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "faultproxy:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		listen   = flag.String("listen", ":9090", "address to listen on")
+		upstream = flag.String("upstream", "", "URL of the upstream (required)")
+		config   = flag.String("config", "", "path to the fault config file")
+		watch    = flag.Duration("watch", 0, "interval to check the config file for changes; 0 disables watching")
+		desc     = flag.String("fault", "", `fault description such as "delay=2s;error=503"`)
+		ratio    = flag.Float64("ratio", 1, "injection ratio of -fault in [0, 1]")
+	)
+	flag.Parse()
+
+	if *upstream == "" {
+		flag.Usage()
+		return fmt.Errorf("-upstream is required")
+	}
+	if (*config == "") == (*desc == "") {
+		return fmt.Errorf("exactly one of -config or -fault is required")
+	}
+	u, err := url.Parse(*upstream)
+	if err != nil {
+		return fmt.Errorf("invalid -upstream: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var mw fault.Middleware
+	switch {
+	case *config != "" && *watch > 0:
+		if mw, err = fault.WatchConfig(ctx, *config, *watch, nil); err != nil {
+			return err
+		}
+	case *config != "":
+		c, err := fault.LoadConfig(*config)
+		if err != nil {
+			return err
+		}
+		for _, w := range c.Warnings {
+			log.Printf("%s: %s", *config, w)
+		}
+		if mw, err = c.Build(); err != nil {
+			return err
+		}
+	default:
+		f, err := fault.ParseEffects(*desc)
+		if err != nil {
+			return err
+		}
+		if *ratio < 0 || *ratio > 1 {
+			return fmt.Errorf("-ratio must be in [0, 1]")
+		}
+		mw = fault.New(f, *ratio)
+	}
+
+	srv := &http.Server{
+		Addr:    *listen,
+		Handler: fault.NewReverseProxy(u, mw, nil),
+	}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+
+	log.Printf("faultproxy: proxying %s to %s", *listen, u)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
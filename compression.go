@@ -0,0 +1,48 @@
+package fault
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// BadCompression breaks the compression of the response of the next handler, so that the transparent
+// decompression of the clients and the proxies can be tested to fail properly.
+// By default, the response is declared "Content-Encoding: gzip" while the body is sent uncompressed.
+// If Corrupt is true, the body is compressed in gzip, then corrupted in the middle of the stream.
+type BadCompression struct {
+	// Corrupt corrupts a valid gzip stream instead of sending the plain body.
+	Corrupt bool
+}
+
+// Handle proxies the request to next, then sends the response with the broken compression.
+func (f *BadCompression) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	next.ServeHTTP(buf, r)
+
+	body := buf.body.Bytes()
+	if strings.EqualFold(buf.header.Get("Content-Encoding"), "gzip") {
+		if zr, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if b, err := io.ReadAll(zr); err == nil {
+				body = b
+			}
+		}
+	}
+
+	if f.Corrupt {
+		var zb bytes.Buffer
+		zw := gzip.NewWriter(&zb)
+		zw.Write(body)
+		zw.Close()
+		body = zb.Bytes()
+		// Flip the bytes after the 10 bytes of the gzip header so that the deflate stream or the checksum breaks.
+		for i := 10 + (len(body)-10)/2; i < len(body) && i < 10+(len(body)-10)/2+16; i++ {
+			body[i] ^= 0xff
+		}
+	}
+
+	buf.header.Set("Content-Encoding", "gzip")
+	buf.copyTo(w, body)
+}
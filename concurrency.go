@@ -0,0 +1,74 @@
+package fault
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Concurrency simulates an overloaded upstream which fails only under load.
+// It tracks the number of in-flight requests passing through it, and once the number exceeds Max,
+// the exceeding requests are responded 503 (or delayed) while the others are proxied to next as usual.
+// Since only the requests given to the fault are counted, it is usually used with the ratio 1.
+type Concurrency struct {
+	// inflight must be the first field to be 64-bit aligned for atomic operations.
+	inflight int64
+
+	// Max is the number of in-flight requests which can be served normally.
+	Max int
+	// Delay changes the behavior for the exceeding requests. If non-zero,
+	// they are delayed for the duration then proxied to next instead of responded an error.
+	Delay time.Duration
+	// StatusCode is the status code for the exceeding requests. If zero, 503 is used.
+	StatusCode int
+	// StatusText is used as HTTP response body. Optional but if empty, a placeholder message is used.
+	StatusText string
+}
+
+// Handle limits the concurrency of the given handler.
+func (f *Concurrency) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	n := atomic.AddInt64(&f.inflight, 1)
+	defer atomic.AddInt64(&f.inflight, -1)
+
+	if n <= int64(f.Max) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if f.Delay > 0 {
+		sleep(f.Delay)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	statusCode := f.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusServiceUnavailable
+	}
+	statusText := f.StatusText
+	if statusText == "" {
+		statusText = "fault: pseudo status text is injected"
+	}
+
+	w.WriteHeader(statusCode)
+	w.Write([]byte(statusText))
+}
+
+// Validate checks Max and Delay are not negative and StatusCode is zero or a valid HTTP status code.
+func (f *Concurrency) Validate() error {
+	if err := validateCount("max", f.Max); err != nil {
+		return err
+	}
+	if err := validateDuration("delay", f.Delay); err != nil {
+		return err
+	}
+	if f.StatusCode != 0 {
+		return validateStatus(f.StatusCode)
+	}
+	return nil
+}
+
+// InFlight returns the number of requests currently passing through the fault.
+func (f *Concurrency) InFlight() int {
+	return int(atomic.LoadInt64(&f.inflight))
+}
@@ -0,0 +1,79 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ConditionalError is how BadConditional mishandles the conditional requests.
+type ConditionalError int
+
+const (
+	// ConditionalStaleNotModified responds 304 Not Modified to every conditional request without calling
+	// the next handler, even when the content has changed.
+	ConditionalStaleNotModified ConditionalError = iota
+	// ConditionalChangedETag changes the ETag of the response of the next handler without changing the body,
+	// so that the clients never see the cache valid.
+	ConditionalChangedETag
+)
+
+// conditionalErrors is the names of ConditionalError, used by the effect.
+var conditionalErrors = map[string]ConditionalError{
+	"stale_not_modified": ConditionalStaleNotModified,
+	"changed_etag":       ConditionalChangedETag,
+}
+
+// BadConditional mishandles the conditional requests, such as the ones with If-None-Match and If-Modified-Since,
+// so that the cache validation of the clients can be tested.
+type BadConditional struct {
+	// Error is how the conditional requests are mishandled.
+	Error ConditionalError
+}
+
+// Handle mishandles the request.
+func (f *BadConditional) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	switch f.Error {
+	case ConditionalStaleNotModified:
+		inm, ims := r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since")
+		if inm == "" && ims == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The ETag the client has is echoed back, so that the client keeps using its stale cache.
+		if etag, _, _ := cutTrimmed(inm+",", ","); etag != "" && etag != "*" {
+			w.Header().Set("ETag", etag)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	case ConditionalChangedETag:
+		next.ServeHTTP(&headerWriter{ResponseWriter: w, rewrite: changeETag}, r)
+	default:
+		next.ServeHTTP(w, r)
+	}
+}
+
+// changeETag sets a new ETag, keeping the weak prefix of the original one.
+func changeETag(h http.Header) {
+	weak, etag := "", h.Get("ETag")
+	if strings.HasPrefix(etag, "W/") {
+		weak, etag = "W/", etag[2:]
+	}
+	tag := strings.Trim(etag, `"`)
+	if tag != "" {
+		tag += "-"
+	}
+	h.Set("ETag", weak+strconv.Quote(tag+strconv.FormatUint(uint64(randomIndex(1<<30)), 16)))
+}
+
+// parseConditionalError parses the name of a ConditionalError. An empty name is ConditionalStaleNotModified.
+func parseConditionalError(s string) (ConditionalError, error) {
+	if s == "" {
+		return ConditionalStaleNotModified, nil
+	}
+	e, ok := conditionalErrors[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid error %s", strconv.Quote(s))
+	}
+	return e, nil
+}
@@ -0,0 +1,428 @@
+package fault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConfigVersion is the version of the config schema this package understands.
+// Configs of older versions are migrated to it on load.
+const ConfigVersion = 1
+
+// Config describes a set of faults in JSON so that experiments can be kept in files.
+// Other formats, such as YAML, are read once they are registered by RegisterConfigFormat.
+//
+//	{
+//	  "version": 1,
+//	  "faults": [
+//	    {"name": "slow-api", "effect": "delay", "params": {"duration": "2s"}, "ratio": 0.1},
+//	    {"name": "broken-api", "effect": "error", "params": {"status": 503}, "ratio": 0.01}
+//	  ]
+//	}
+type Config struct {
+	// Version is the schema version of the config.
+	Version int `json:"version"`
+	// Faults is the list of faults. They are applied in order.
+	Faults []FaultConfig `json:"faults"`
+
+	// Warnings lists what should be noted about the loaded config, such as applied migrations.
+	// It is not a part of the schema.
+	Warnings []string `json:"-"`
+}
+
+// FaultConfig describes a single fault and how it is injected.
+type FaultConfig struct {
+	// Name identifies the fault. Required and must be unique in the config.
+	Name string `json:"name"`
+	// Effect is the name of a registered Effect. Required.
+	Effect string `json:"effect"`
+	// Params is passed to the Effect.
+	Params Params `json:"params,omitempty"`
+	// Ratio is the ratio of requests the fault is injected into, in [0, 1].
+	Ratio float64 `json:"ratio"`
+	// MethodRatios overrides the ratio per HTTP method, such as {"POST": 0.05}.
+	MethodRatios map[string]float64 `json:"method_ratios,omitempty"`
+	// Sampler is the name of a registered SamplerFactory. If empty, RandomSampler is used.
+	Sampler string `json:"sampler,omitempty"`
+	// SamplerParams is passed to the SamplerFactory.
+	SamplerParams Params `json:"sampler_params,omitempty"`
+	// Disabled makes the fault never injected, while keeping it in the config.
+	Disabled bool `json:"disabled,omitempty"`
+	// DryRun makes the fault decided but never injected.
+	DryRun bool `json:"dry_run,omitempty"`
+	// MarkerHeader is the name of the header marking the responses written by the fault.
+	MarkerHeader string `json:"marker_header,omitempty"`
+	// RequestIDHeader is the name of the header carrying the ID of the request.
+	RequestIDHeader string `json:"request_id_header,omitempty"`
+	// Budget caps the number of injections.
+	Budget *BudgetConfig `json:"budget,omitempty"`
+	// Start is when the fault starts being injected, in RFC 3339. If omitted, the fault starts when the config
+	// is loaded.
+	Start *time.Time `json:"start,omitempty"`
+	// TTL is how long the fault is injected after Start, or after the config is loaded.
+	TTL Duration `json:"ttl,omitempty"`
+	// Schedule is a cron expression limiting when the fault is injected. See ParseCron.
+	Schedule string `json:"schedule,omitempty"`
+	// TimeZone is the IANA time zone name the Schedule is evaluated in. If empty, the local time zone is used.
+	TimeZone string `json:"time_zone,omitempty"`
+	// Ramp changes the ratio gradually.
+	Ramp *RampConfig `json:"ramp,omitempty"`
+	// Flapping turns the fault on and off repeatedly. It cannot be used with Schedule.
+	Flapping *FlappingConfig `json:"flapping,omitempty"`
+	// ExcludePaths are the paths never injected. If omitted, DefaultExcludePaths is used.
+	ExcludePaths []string `json:"exclude_paths,omitempty"`
+}
+
+// RampConfig describes a Ramp.
+type RampConfig struct {
+	Up   Duration `json:"up,omitempty"`
+	Hold Duration `json:"hold,omitempty"`
+	Down Duration `json:"down,omitempty"`
+}
+
+// FlappingConfig describes a Flapping.
+type FlappingConfig struct {
+	On  Duration `json:"on"`
+	Off Duration `json:"off"`
+}
+
+// BudgetConfig describes a Budget.
+type BudgetConfig struct {
+	Rate     int      `json:"rate,omitempty"`
+	Interval Duration `json:"interval,omitempty"`
+	Total    int      `json:"total,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "1m30s" in JSON.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("fault: duration must be a string such as \"1m30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("fault: %w", err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Params is textual parameters of effects and samplers.
+// In JSON, numbers and booleans are accepted as well as strings.
+type Params map[string]string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Params) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	m := make(Params, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			m[k] = v
+		case float64:
+			m[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			m[k] = strconv.FormatBool(v)
+		default:
+			return fmt.Errorf("fault: parameter %q must be a string, number or boolean", k)
+		}
+	}
+	*p = m
+	return nil
+}
+
+// migration upgrades a raw config document from a version to the next one.
+// It returns a warning describing what was changed.
+type migration func(doc map[string]interface{}) (string, error)
+
+// migrations is keyed by the version to migrate from.
+// When the schema changes, ConfigVersion is incremented and the migration from the previous version is added here.
+var migrations = map[int]migration{
+	0: migrateV0,
+}
+
+// migrateV0 migrates a config of version 0, which has no version field and describes the ratio of each fault
+// by "percentage" in [0, 100], as the fault filter of Envoy does. The percentage is converted to "ratio".
+func migrateV0(doc map[string]interface{}) (string, error) {
+	faults, _ := doc["faults"].([]interface{})
+	converted := 0
+	for i, f := range faults {
+		fc, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		p, ok := fc["percentage"]
+		if !ok {
+			continue
+		}
+		if _, ok := fc["ratio"]; ok {
+			return "", fmt.Errorf("faults[%d]: percentage and ratio cannot be used together", i)
+		}
+		v, ok := p.(float64)
+		if !ok {
+			return "", fmt.Errorf("faults[%d]: percentage must be a number", i)
+		}
+		fc["ratio"] = v / 100
+		delete(fc, "percentage")
+		converted++
+	}
+	return fmt.Sprintf("percentage of %d faults converted to ratio", converted), nil
+}
+
+// ConfigFormat converts a config document in a format other than JSON, such as YAML, to JSON.
+type ConfigFormat func(b []byte) ([]byte, error)
+
+var (
+	configFormatsMu sync.RWMutex
+	configFormats   = map[string]ConfigFormat{}
+)
+
+// RegisterConfigFormat makes ParseConfig, and so LoadConfig and the Reloaders, read the config documents
+// in the format of the name, such as "yaml". The documents not starting with "{" are converted to JSON by
+// the registered formats, tried in the order of their names until one succeeds.
+// Package faultyaml registers "yaml" when it is imported:
+//
+//	import _ "github.com/hidetatz/fault/faultyaml"
+//
+// If RegisterConfigFormat is called twice with the same name or if f is nil, it panics.
+func RegisterConfigFormat(name string, f ConfigFormat) {
+	configFormatsMu.Lock()
+	defer configFormatsMu.Unlock()
+	if f == nil {
+		panic("fault: RegisterConfigFormat format is nil")
+	}
+	if _, dup := configFormats[name]; dup {
+		panic("fault: RegisterConfigFormat called twice for format " + name)
+	}
+	configFormats[name] = f
+}
+
+// toJSON converts the config document to JSON by the registered formats, unless it is JSON already.
+func toJSON(b []byte) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) == 0 || trimmed[0] == '{' {
+		return b, nil
+	}
+
+	configFormatsMu.RLock()
+	defer configFormatsMu.RUnlock()
+	if len(configFormats) == 0 {
+		return b, nil
+	}
+	var errs []string
+	for _, name := range sortedKeys(len(configFormats), func(f func(string)) {
+		for k := range configFormats {
+			f(k)
+		}
+	}) {
+		j, err := configFormats[name](b)
+		if err == nil {
+			return j, nil
+		}
+		errs = append(errs, name+": "+err.Error())
+	}
+	return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// LoadConfig reads the config file at the given path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(b)
+}
+
+// ParseConfig parses and validates the JSON config, or the config in a format registered by RegisterConfigFormat.
+// If the config is of an older version, it is migrated to ConfigVersion and the migrations are reported in Warnings.
+// A config without version is treated as version 0.
+func ParseConfig(b []byte) (*Config, error) {
+	b, err := toJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("fault: parse config: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("fault: parse config: %w", err)
+	}
+
+	var warnings []string
+	version := 0
+	switch v := doc["version"].(type) {
+	case nil:
+		warnings = append(warnings, "config has no version, assuming 0")
+	case float64:
+		version = int(v)
+		if float64(version) != v || version < 0 {
+			return nil, fmt.Errorf("fault: invalid config version %v", v)
+		}
+	default:
+		return nil, fmt.Errorf("fault: config version must be a number")
+	}
+	if version > ConfigVersion {
+		return nil, fmt.Errorf("fault: config version %d is newer than supported version %d", version, ConfigVersion)
+	}
+
+	for ; version < ConfigVersion; version++ {
+		m, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("fault: no migration from config version %d", version)
+		}
+		w, err := m(doc)
+		if err != nil {
+			return nil, fmt.Errorf("fault: migrate config from version %d: %w", version, err)
+		}
+		warnings = append(warnings, fmt.Sprintf("config migrated from version %d to %d: %s", version, version+1, w))
+	}
+	doc["version"] = ConfigVersion
+
+	b, err = json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("fault: parse config: %w", err)
+	}
+	c.Warnings = warnings
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Validate checks the config against the schema.
+// It makes sure every fault can be built.
+func (c *Config) Validate() error {
+	if c.Version != ConfigVersion {
+		return fmt.Errorf("fault: config version %d is not supported", c.Version)
+	}
+
+	seen := map[string]bool{}
+	for i, fc := range c.Faults {
+		if fc.Name == "" {
+			return fmt.Errorf("fault: faults[%d]: name is required", i)
+		}
+		if seen[fc.Name] {
+			return fmt.Errorf("fault: faults[%d]: duplicate name %q", i, fc.Name)
+		}
+		seen[fc.Name] = true
+
+		if _, err := fc.Build(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Build returns a Handler configured as described.
+func (fc FaultConfig) Build() (*Handler, error) {
+	h, err := fc.build()
+	if err != nil {
+		return nil, fmt.Errorf("fault: %s: %w", fc.Name, err)
+	}
+
+	return h, nil
+}
+
+func (fc FaultConfig) build() (*Handler, error) {
+	if fc.Effect == "" {
+		return nil, fmt.Errorf("effect is required")
+	}
+	e, ok := LookupEffect(fc.Effect)
+	if !ok {
+		return nil, fmt.Errorf("unknown effect %q", fc.Effect)
+	}
+	f, err := e(fc.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	h := New(f, fc.Ratio)
+	h.Name = fc.Name
+	h.MethodRatios = fc.MethodRatios
+	if fc.Disabled {
+		h.Disable()
+	}
+	h.DryRun = fc.DryRun
+	h.MarkerHeader = fc.MarkerHeader
+	h.RequestIDHeader = fc.RequestIDHeader
+	h.ExcludePaths = fc.ExcludePaths
+	if b := fc.Budget; b != nil {
+		h.Budget = &Budget{Rate: b.Rate, Interval: time.Duration(b.Interval), Total: b.Total}
+	}
+	if fc.Start != nil {
+		h.Start = *fc.Start
+	}
+	h.TTL = time.Duration(fc.TTL)
+	if rc := fc.Ramp; rc != nil {
+		h.Ramp = &Ramp{Up: time.Duration(rc.Up), Hold: time.Duration(rc.Hold), Down: time.Duration(rc.Down)}
+	}
+	if fc.Schedule != "" {
+		var loc *time.Location
+		if fc.TimeZone != "" {
+			if loc, err = time.LoadLocation(fc.TimeZone); err != nil {
+				return nil, err
+			}
+		}
+		if h.Schedule, err = ParseCron(fc.Schedule, loc); err != nil {
+			return nil, err
+		}
+	}
+	if fl := fc.Flapping; fl != nil {
+		if fc.Schedule != "" {
+			return nil, fmt.Errorf("schedule and flapping cannot be used together")
+		}
+		h.Schedule = &Flapping{On: time.Duration(fl.On), Off: time.Duration(fl.Off)}
+	}
+	if fc.Sampler != "" {
+		sf, ok := LookupSampler(fc.Sampler)
+		if !ok {
+			return nil, fmt.Errorf("unknown sampler %q", fc.Sampler)
+		}
+		if h.Sampler, err = sf(fc.SamplerParams); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Build returns a Chain of the faults in the config.
+func (c *Config) Build() (Chain, error) {
+	chain := make(Chain, 0, len(c.Faults))
+	for _, fc := range c.Faults {
+		h, err := fc.Build()
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, h)
+	}
+
+	return chain, nil
+}
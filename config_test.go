@@ -0,0 +1,61 @@
+package fault
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigMigratesV0(t *testing.T) {
+	c, err := ParseConfig([]byte(`{"faults": [{"name": "slow", "effect": "delay", "params": {"duration": "1s"}, "percentage": 25}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != ConfigVersion {
+		t.Errorf("version = %d, want %d", c.Version, ConfigVersion)
+	}
+	if got := c.Faults[0].Ratio; got != 0.25 {
+		t.Errorf("ratio = %v, want 0.25", got)
+	}
+	if len(c.Warnings) != 2 || !strings.Contains(c.Warnings[1], "migrated from version 0 to 1") {
+		t.Errorf("warnings = %q", c.Warnings)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"percentage with ratio": `{"faults": [{"name": "a", "effect": "delay", "percentage": 10, "ratio": 0.1}]}`,
+		"newer version":         `{"version": 99, "faults": []}`,
+		"negative version":      `{"version": -1, "faults": []}`,
+		"unknown field":         `{"version": 1, "faults": [{"name": "a", "effect": "delay", "percentage": 10}]}`,
+		"unknown effect":        `{"version": 1, "faults": [{"name": "a", "effect": "nope"}]}`,
+		"duplicate name":        `{"version": 1, "faults": [{"name": "a", "effect": "delay"}, {"name": "a", "effect": "delay"}]}`,
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseConfig([]byte(doc)); err == nil {
+				t.Error("ParseConfig succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestFaultConfigStartOmitted(t *testing.T) {
+	b, err := json.Marshal(FaultConfig{Name: "a", Effect: "delay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "start") {
+		t.Errorf("marshaled %s, want start omitted", b)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h, err := FaultConfig{Name: "a", Effect: "delay", Start: &start}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Start.Equal(start) {
+		t.Errorf("Start = %v, want %v", h.Start, start)
+	}
+}
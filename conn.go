@@ -0,0 +1,138 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// ConnFault injects faults into the reads and writes of network connections: latency, bandwidth caps and
+// connections closed in the middle of a stream.
+// It wraps the connections of clients by DialContext, and the ones of servers by Listener.Conn.
+//
+//	tr := &http.Transport{DialContext: (&fault.ConnFault{ReadBandwidth: 1024}).DialContext(nil)}
+//
+// The faults are not injected while the kill switch is on.
+type ConnFault struct {
+	// ReadLatency delays every Read.
+	ReadLatency time.Duration
+	// WriteLatency delays every Write.
+	WriteLatency time.Duration
+	// ReadBandwidth caps the reads in bytes per second. Zero means no cap.
+	ReadBandwidth int
+	// WriteBandwidth caps the writes in bytes per second. Zero means no cap.
+	WriteBandwidth int
+	// CloseRatio is the ratio of Read and Write calls which reset the connection instead, in [0, 1].
+	CloseRatio float64
+}
+
+// ErrConnClosed is returned by Read and Write of the connection closed by ConnFault.
+var ErrConnClosed = errors.New("fault: connection closed by fault")
+
+// Wrap returns the connection injected the faults.
+func (f *ConnFault) Wrap(c net.Conn) net.Conn {
+	return &faultConn{Conn: c, f: f}
+}
+
+// DialContext returns a dial function, such as for http.Transport.DialContext, which wraps the connections
+// made by dial. If dial is nil, net.Dialer's DialContext is used.
+func (f *ConnFault) DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return f.Wrap(c), nil
+	}
+}
+
+// faultConn is a net.Conn injected the faults of ConnFault.
+type faultConn struct {
+	net.Conn
+	f *ConnFault
+}
+
+func (c *faultConn) Read(b []byte) (int, error) {
+	if AllDisabled() {
+		return c.Conn.Read(b)
+	}
+	if err := c.before(c.f.ReadLatency); err != nil {
+		return 0, err
+	}
+
+	if bw := c.f.ReadBandwidth; bw > 0 {
+		b = b[:min(len(b), chunkSize(bw))]
+		n, err := c.Conn.Read(b)
+		throttle(n, bw)
+		return n, err
+	}
+
+	return c.Conn.Read(b)
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	if AllDisabled() {
+		return c.Conn.Write(b)
+	}
+	if err := c.before(c.f.WriteLatency); err != nil {
+		return 0, err
+	}
+
+	bw := c.f.WriteBandwidth
+	if bw <= 0 {
+		return c.Conn.Write(b)
+	}
+
+	written := 0
+	for len(b) > 0 {
+		chunk := b[:min(len(b), chunkSize(bw))]
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		throttle(n, bw)
+		b = b[n:]
+	}
+
+	return written, nil
+}
+
+// before delays the call, or resets the connection if it is chosen by CloseRatio.
+func (c *faultConn) before(latency time.Duration) error {
+	if defaultSampler.Sample(nil, c.f.CloseRatio) {
+		reset(c.Conn)
+		return ErrConnClosed
+	}
+	if latency > 0 {
+		sleep(latency)
+	}
+	return nil
+}
+
+// chunkSize returns the bytes transferred at once under the bandwidth, which is 100ms worth of it.
+func chunkSize(bw int) int {
+	if n := bw / 10; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// throttle sleeps for the time n bytes take to be transferred under the bandwidth.
+func throttle(n, bw int) {
+	if n > 0 {
+		sleep(time.Duration(n) * time.Second / time.Duration(bw))
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
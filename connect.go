@@ -0,0 +1,100 @@
+package fault
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ConnectError responds an error of the Connect protocol without calling the next handler, so that services built
+// on connect-go get the same faults as the others. Since connect-go handlers are http.Handlers, the other faults
+// such as Delay and Abort apply to them as they are.
+// The error is written in the protocol of the request: a JSON error for Connect unary calls, an end-of-stream
+// message for Connect streaming calls, and a gRPC status as GRPCError does for gRPC and gRPC-Web calls.
+// To return the error as *connect.Error seen by the other interceptors, use the interceptor of package connectfault.
+type ConnectError struct {
+	// Code is the gRPC status code, such as 14 for "unavailable". Required.
+	Code int
+	// Message is the error message. Optional.
+	Message string
+	// Details are attached to the error.
+	Details []GRPCDetail
+}
+
+// connectCodes is the names of the codes in the Connect protocol, indexed by the code.
+var connectCodes = []string{
+	"", "canceled", "unknown", "invalid_argument", "deadline_exceeded", "not_found", "already_exists",
+	"permission_denied", "resource_exhausted", "failed_precondition", "aborted", "out_of_range",
+	"unimplemented", "internal", "unavailable", "data_loss", "unauthenticated",
+}
+
+// connectStatuses is the HTTP status codes of the Connect unary errors, indexed by the code.
+var connectStatuses = []int{
+	200, 499, 500, 400, 504, 404, 409, 403, 429, 400, 409, 400, 501, 500, 503, 500, 401,
+}
+
+// Handle responds the error.
+func (f *ConnectError) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc"):
+		(&GRPCError{Code: f.Code, Message: f.Message, Details: f.Details}).Handle(w, r, next)
+	case strings.HasPrefix(contentType, "application/connect+"):
+		b, _ := json.Marshal(struct {
+			Error connectError `json:"error"`
+		}{f.body()})
+		env := make([]byte, 5, 5+len(b))
+		env[0] = 0x02 // end of stream
+		binary.BigEndian.PutUint32(env[1:], uint32(len(b)))
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(append(env, b...))
+	default:
+		status := http.StatusInternalServerError
+		if f.Code >= 0 && f.Code < len(connectStatuses) {
+			status = connectStatuses[f.Code]
+		}
+		b, _ := json.Marshal(f.body())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(b)
+	}
+}
+
+// Validate checks Code is a gRPC status code of an error.
+func (f *ConnectError) Validate() error {
+	if f.Code < 1 || f.Code > 16 {
+		return fmt.Errorf("code %d must be in [1, 16]", f.Code)
+	}
+	return nil
+}
+
+// connectError is the JSON representation of an error in the Connect protocol.
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+type connectDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func (f *ConnectError) body() connectError {
+	e := connectError{Code: "unknown", Message: f.Message}
+	if f.Code > 0 && f.Code < len(connectCodes) {
+		e.Code = connectCodes[f.Code]
+	}
+	for _, d := range f.Details {
+		typ := d.TypeURL
+		if i := strings.LastIndex(typ, "/"); i >= 0 {
+			typ = typ[i+1:]
+		}
+		e.Details = append(e.Details, connectDetail{Type: typ, Value: base64.RawStdEncoding.EncodeToString(d.Value)})
+	}
+	return e
+}
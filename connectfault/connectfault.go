@@ -0,0 +1,213 @@
+// Package connectfault adapts the faults of package fault to connect-go by an interceptor, so that the faults
+// are returned as *connect.Error as the handlers would return them.
+//
+//	h := fault.New(&fault.ConnectError{Code: 14, Message: "unavailable"}, 0.1)
+//	path, handler := greetv1connect.NewGreetServiceHandler(svc, connect.WithInterceptors(connectfault.Interceptor(h)))
+//
+// Since connect-go handlers are http.Handlers, the faults of package fault apply to them as they are;
+// the interceptor is for the faults decided per procedure after connect-go parses the request,
+// and for the errors observed by the other interceptors, such as the ones logging or retrying.
+//
+// The Handlers decide on the calls as usual; a call is converted to *http.Request to the procedure,
+// such as "/greet.v1.GreetService/Greet", with the request headers, for the Matchers, the Samplers and the Hooks.
+// Only Delay, Error, DelayWithError, Abort, DelayWithAbort, ConnectError and GRPCError are supported, including the
+// ones combined by fault.All, fault.Named and Override. The calls whose fault is not supported pass with the reason
+// "unsupported" and are not counted as injected. Error and DelayWithError are returned with the codes the clients report for the
+// HTTP status codes, such as unavailable for 503. Abort is returned as unavailable, as the clients report
+// the closed connection, unless its Value is set, in which case it panics with the Value.
+// The interceptor does nothing on the clients.
+package connectfault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/hidetatz/fault"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Interceptor returns a connect.Interceptor applying the faults of the Handlers to the unary and streaming calls,
+// in order. The faults are applied to a streaming call when it starts; a delay with Afterward is added after the
+// handler returns.
+func Interceptor(handlers ...*fault.Handler) connect.Interceptor {
+	return &interceptor{chain: fault.Chain(handlers)}
+}
+
+type interceptor struct {
+	chain fault.Chain
+}
+
+// WrapUnary applies the faults to the unary calls.
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		var res connect.AnyResponse
+		r := request(ctx, req.HTTPMethod(), req.Spec(), req.Peer(), req.Header())
+		err := i.apply(r, func() error {
+			var err error
+			res, err = next(ctx, req)
+			return err
+		})
+		return res, err
+	}
+}
+
+// WrapStreamingClient returns next as it is.
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler applies the faults to the streaming calls.
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		r := request(ctx, http.MethodPost, conn.Spec(), conn.Peer(), conn.RequestHeader())
+		return i.apply(r, func() error {
+			return next(ctx, conn)
+		})
+	}
+}
+
+// apply evaluates the Handlers on the call and calls call unless a fault ends the call.
+func (i *interceptor) apply(r *http.Request, call func() error) error {
+	if len(i.chain) == 0 {
+		return call()
+	}
+
+	ctx := r.Context()
+	var after []time.Duration
+	for _, h := range i.chain {
+		o := h.EvaluateSupported(r, supported)
+		if o.Decision != fault.Inject {
+			continue
+		}
+
+		for _, step := range fault.Steps(o.Fault) {
+			switch f := step.(type) {
+			case *fault.Delay:
+				if f.Afterward {
+					after = append(after, f.Duration)
+					continue
+				}
+				if err := sleep(ctx, f.Duration); err != nil {
+					return err
+				}
+			case *fault.Error:
+				return httpError(f.StatusCode, f.StatusText)
+			case *fault.DelayWithError:
+				if err := sleep(ctx, f.Duration); err != nil {
+					return err
+				}
+				return httpError(f.StatusCode, f.StatusText)
+			case *fault.Abort:
+				return abort(f.Value)
+			case *fault.DelayWithAbort:
+				if err := sleep(ctx, f.Duration); err != nil {
+					return err
+				}
+				return abort(f.Value)
+			case *fault.ConnectError:
+				return connectError(f.Code, f.Message, f.Details)
+			case *fault.GRPCError:
+				return connectError(f.Code, f.Message, f.Details)
+			}
+		}
+	}
+
+	err := call()
+	for _, d := range after {
+		fault.CurrentClock().Sleep(ctx, d)
+	}
+	return err
+}
+
+// supported reports whether every step of f can be applied to the calls.
+func supported(f fault.Fault) bool {
+	for _, step := range fault.Steps(f) {
+		switch step.(type) {
+		case *fault.Delay, *fault.Error, *fault.DelayWithError, *fault.Abort, *fault.DelayWithAbort,
+			*fault.GRPCError, *fault.ConnectError:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// request converts the call to *http.Request for the Handlers.
+func request(ctx context.Context, method string, spec connect.Spec, peer connect.Peer, header http.Header) *http.Request {
+	u := &url.URL{Path: spec.Procedure, RawQuery: peer.Query.Encode()}
+	return (&http.Request{
+		Method:     method,
+		URL:        u,
+		RequestURI: u.RequestURI(),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+		Body:       http.NoBody,
+		RemoteAddr: peer.Addr,
+	}).WithContext(ctx)
+}
+
+// sleep sleeps for d, returning the error of the context if the call ends first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := fault.CurrentClock().Sleep(ctx, d); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return connect.NewError(connect.CodeDeadlineExceeded, err)
+		}
+		return connect.NewError(connect.CodeCanceled, err)
+	}
+	return nil
+}
+
+// httpCodes is the codes the clients report for the HTTP status codes, see
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md. Others are reported as unknown.
+var httpCodes = map[int]connect.Code{
+	http.StatusBadRequest:         connect.CodeInternal,
+	http.StatusUnauthorized:       connect.CodeUnauthenticated,
+	http.StatusForbidden:          connect.CodePermissionDenied,
+	http.StatusNotFound:           connect.CodeUnimplemented,
+	http.StatusTooManyRequests:    connect.CodeUnavailable,
+	http.StatusBadGateway:         connect.CodeUnavailable,
+	http.StatusServiceUnavailable: connect.CodeUnavailable,
+	http.StatusGatewayTimeout:     connect.CodeUnavailable,
+}
+
+// httpError returns the error the clients report for the HTTP status code.
+func httpError(code int, text string) error {
+	c, ok := httpCodes[code]
+	if !ok {
+		c = connect.CodeUnknown
+	}
+	if text == "" {
+		text = http.StatusText(code)
+	}
+	return connect.NewError(c, fmt.Errorf("HTTP status %d %s", code, text))
+}
+
+// abort returns the error the clients report for the closed connection, or panics with v if it is set.
+func abort(v interface{}) error {
+	if v != nil {
+		panic(v)
+	}
+	return connect.NewError(connect.CodeUnavailable, io.EOF)
+}
+
+// connectError returns the error with the details.
+func connectError(code int, msg string, details []fault.GRPCDetail) error {
+	err := connect.NewError(connect.Code(code), errors.New(msg))
+	for _, d := range details {
+		// NewErrorDetail does not fail on Any.
+		detail, _ := connect.NewErrorDetail(&anypb.Any{TypeUrl: d.TypeURL, Value: d.Value})
+		err.AddDetail(detail)
+	}
+	return err
+}
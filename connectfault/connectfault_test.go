@@ -0,0 +1,147 @@
+package connectfault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/hidetatz/fault"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type clients struct {
+	unary  *connect.Client[wrapperspb.StringValue, wrapperspb.StringValue]
+	stream *connect.Client[wrapperspb.StringValue, wrapperspb.StringValue]
+}
+
+func newClients(t *testing.T, h *fault.Handler) clients {
+	t.Helper()
+	opt := connect.WithInterceptors(Interceptor(h))
+	mux := http.NewServeMux()
+	mux.Handle("/test.v1.TestService/Echo", connect.NewUnaryHandler("/test.v1.TestService/Echo",
+		func(_ context.Context, req *connect.Request[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+			return connect.NewResponse(req.Msg), nil
+		}, opt))
+	mux.Handle("/test.v1.TestService/Repeat", connect.NewServerStreamHandler("/test.v1.TestService/Repeat",
+		func(_ context.Context, req *connect.Request[wrapperspb.StringValue], s *connect.ServerStream[wrapperspb.StringValue]) error {
+			return s.Send(req.Msg)
+		}, opt))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return clients{
+		unary:  connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](srv.Client(), srv.URL+"/test.v1.TestService/Echo"),
+		stream: connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](srv.Client(), srv.URL+"/test.v1.TestService/Repeat"),
+	}
+}
+
+func (c clients) call() (unary, stream error) {
+	ctx := context.Background()
+	_, unary = c.unary.CallUnary(ctx, connect.NewRequest(wrapperspb.String("hi")))
+	s, err := c.stream.CallServerStream(ctx, connect.NewRequest(wrapperspb.String("hi")))
+	if err != nil {
+		return unary, err
+	}
+	defer s.Close()
+	for s.Receive() {
+	}
+	return unary, s.Err()
+}
+
+func TestInterceptor(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		f    fault.Fault
+		code connect.Code
+	}{
+		{"error", &fault.Error{StatusCode: 503}, connect.CodeUnavailable},
+		{"error unknown", &fault.Error{StatusCode: 418}, connect.CodeUnknown},
+		{"delay with error", &fault.DelayWithError{Duration: time.Millisecond, StatusCode: 403}, connect.CodePermissionDenied},
+		{"abort", &fault.Abort{}, connect.CodeUnavailable},
+		{"delay with abort", &fault.DelayWithAbort{Duration: time.Millisecond}, connect.CodeUnavailable},
+		{"connect error", &fault.ConnectError{Code: 8, Message: "quota"}, connect.CodeResourceExhausted},
+		{"grpc error", &fault.GRPCError{Code: 14, Message: "down"}, connect.CodeUnavailable},
+		{"delay", &fault.Delay{Duration: time.Millisecond}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			unary, stream := newClients(t, fault.New(tc.f, 1)).call()
+			for _, err := range []error{unary, stream} {
+				if tc.code == 0 {
+					if err != nil {
+						t.Errorf("err = %v, want nil", err)
+					}
+					continue
+				}
+				if got := connect.CodeOf(err); got != tc.code {
+					t.Errorf("code = %v, want %v: %v", got, tc.code, err)
+				}
+			}
+		})
+	}
+}
+
+func TestInterceptorDetails(t *testing.T) {
+	f := &fault.ConnectError{Code: 14, Message: "down", Details: []fault.GRPCDetail{fault.RetryInfo(2 * time.Second)}}
+	unary, _ := newClients(t, fault.New(f, 1)).call()
+	var ce *connect.Error
+	if !errors.As(unary, &ce) {
+		t.Fatalf("err = %v, want *connect.Error", unary)
+	}
+	if ce.Message() != "down" {
+		t.Errorf("message = %q, want %q", ce.Message(), "down")
+	}
+	if ds := ce.Details(); len(ds) != 1 || ds[0].Type() != "google.rpc.RetryInfo" {
+		t.Errorf("details = %v", ds)
+	}
+}
+
+func TestInterceptorMatchers(t *testing.T) {
+	h := fault.New(&fault.Error{StatusCode: 503}, 1)
+	h.Matchers = []fault.Matcher{fault.MatcherFunc(func(r *http.Request) bool {
+		return r.URL.Path == "/test.v1.TestService/Repeat"
+	})}
+	unary, stream := newClients(t, h).call()
+	if unary != nil {
+		t.Errorf("unary: err = %v, want nil", unary)
+	}
+	if connect.CodeOf(stream) != connect.CodeUnavailable {
+		t.Errorf("stream: err = %v, want unavailable", stream)
+	}
+}
+
+func TestInterceptorOverride(t *testing.T) {
+	h := fault.New(&fault.Delay{}, 0)
+	h.Override = &fault.Override{Secret: "s"}
+	c := newClients(t, h)
+
+	for _, tc := range []struct {
+		header string
+		code   connect.Code
+	}{
+		{"delay=1ms;connect_error(code=8,message=quota)", connect.CodeResourceExhausted},
+		{"delay=1ms;error=503", connect.CodeUnavailable},
+		{"delay=1ms;hang", 0},
+	} {
+		req := connect.NewRequest(wrapperspb.String("hi"))
+		req.Header().Set("X-Fault-Inject", tc.header)
+		req.Header().Set("X-Fault-Secret", "s")
+		_, err := c.unary.CallUnary(context.Background(), req)
+		if tc.code == 0 {
+			if err != nil {
+				t.Errorf("%s: err = %v, want nil", tc.header, err)
+			}
+			continue
+		}
+		if got := connect.CodeOf(err); got != tc.code {
+			t.Errorf("%s: code = %v, want %v: %v", tc.header, got, tc.code, err)
+		}
+	}
+	// The unsupported hang is not counted.
+	if n := h.Stats().Injected; n != 2 {
+		t.Errorf("injected = %d, want 2", n)
+	}
+}
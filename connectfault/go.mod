@@ -0,0 +1,11 @@
+module github.com/hidetatz/fault/connectfault
+
+go 1.24.0
+
+require (
+	connectrpc.com/connect v1.19.1
+	github.com/hidetatz/fault v0.0.0
+	google.golang.org/protobuf v1.36.9
+)
+
+replace github.com/hidetatz/fault => ../
@@ -0,0 +1,143 @@
+package fault
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConsulBackend is a ConfigBackend reading the config from a key in Consul's KV store.
+// Changes are watched by blocking queries of the HTTP API.
+type ConsulBackend struct {
+	// Address is the base URL of the Consul agent. If empty, "http://127.0.0.1:8500" is used.
+	Address string
+	// Key is the key the config is stored in. Required.
+	Key string
+	// Token is the ACL token. Optional.
+	Token string
+	// Datacenter is the datacenter to query. If empty, the agent's datacenter is used.
+	Datacenter string
+	// Client is used to call the API. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Wait is the maximum duration of a blocking query. If zero, 5 minutes is used.
+	Wait time.Duration
+}
+
+// String returns a description of the backend used in logs.
+func (c *ConsulBackend) String() string {
+	return "consul:" + c.Key
+}
+
+// Watch implements ConfigBackend.
+func (c *ConsulBackend) Watch(ctx context.Context, update func(doc []byte), onError func(err error)) {
+	var index uint64
+	retry := newBackoff()
+	for ctx.Err() == nil {
+		doc, next, err := c.get(ctx, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			onError(err)
+			retry.wait(ctx)
+			continue
+		}
+		retry.reset()
+
+		// The index can go backwards, such as after the key is recreated. Start over in that case.
+		if next < index {
+			index = 0
+			continue
+		}
+		if next == index {
+			continue
+		}
+		index = next
+		update(doc)
+	}
+}
+
+// get reads the key. If index is non-zero, it blocks until the key changes from the index.
+func (c *ConsulBackend) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	addr := c.Address
+	if addr == "" {
+		addr = "http://127.0.0.1:8500"
+	}
+	wait := c.Wait
+	if wait <= 0 {
+		wait = 5 * time.Minute
+	}
+
+	q := url.Values{}
+	q.Set("raw", "true")
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", strconv.Itoa(int(wait/time.Second))+"s")
+	}
+	if c.Datacenter != "" {
+		q.Set("dc", c.Datacenter)
+	}
+	u := strings.TrimSuffix(addr, "/") + "/v1/kv/" + strings.TrimPrefix(c.Key, "/") + "?" + q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, 0, fmt.Errorf("fault: consul key %q not found", c.Key)
+	default:
+		return nil, 0, fmt.Errorf("fault: consul: unexpected status %s", resp.Status)
+	}
+
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fault: consul: invalid X-Consul-Index: %w", err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return b, next, nil
+}
+
+// backoff waits exponentially longer on consecutive failures, up to 30 seconds.
+type backoff struct {
+	d time.Duration
+}
+
+func newBackoff() *backoff {
+	return &backoff{d: time.Second}
+}
+
+func (b *backoff) wait(ctx context.Context) {
+	sleepContext(ctx, b.d)
+	if b.d *= 2; b.d > 30*time.Second {
+		b.d = 30 * time.Second
+	}
+}
+
+func (b *backoff) reset() {
+	b.d = time.Second
+}
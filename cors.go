@@ -0,0 +1,85 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSError is how BadCORS breaks the CORS headers.
+type CORSError int
+
+const (
+	// CORSStrip removes the Access-Control-* headers.
+	CORSStrip CORSError = iota
+	// CORSWrongOrigin sets Access-Control-Allow-Origin to an origin other than the one of the request.
+	CORSWrongOrigin
+	// CORSWildcardCredentials sets Access-Control-Allow-Origin to "*" with Access-Control-Allow-Credentials,
+	// which the browsers reject for the requests with credentials.
+	CORSWildcardCredentials
+)
+
+// corsErrors is the names of CORSError, used by the effect.
+var corsErrors = map[string]CORSError{
+	"strip":                CORSStrip,
+	"wrong_origin":         CORSWrongOrigin,
+	"wildcard_credentials": CORSWildcardCredentials,
+}
+
+// corsWrongOrigin is the origin CORSWrongOrigin allows, which never matches a real origin.
+const corsWrongOrigin = "https://fault.invalid"
+
+// BadCORS breaks the CORS headers of the response of the next handler, and fails PreflightRatio of the preflight
+// requests with 403 Forbidden, so that the browser-side error handling of the single page applications can be
+// tested against the CORS breakage. The browsers hide the details of the CORS failures from the scripts,
+// so the applications see them as network errors.
+type BadCORS struct {
+	// Error is how the CORS headers are broken.
+	Error CORSError
+	// PreflightRatio is the ratio of the preflight requests responded 403 without calling the next handler,
+	// in [0, 1].
+	PreflightRatio float64
+}
+
+// Handle breaks the CORS of the request.
+func (f *BadCORS) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" && randomFloat() < f.PreflightRatio {
+		writeError(w, r, http.StatusForbidden, "fault: pseudo CORS preflight failure is injected", false)
+		return
+	}
+	next.ServeHTTP(&headerWriter{ResponseWriter: w, rewrite: f.rewrite}, r)
+}
+
+// Validate checks PreflightRatio is in [0, 1].
+func (f *BadCORS) Validate() error {
+	return validateRatio("preflight ratio", f.PreflightRatio)
+}
+
+func (f *BadCORS) rewrite(h http.Header) {
+	switch f.Error {
+	case CORSStrip:
+		for k := range h {
+			if strings.HasPrefix(k, "Access-Control-") {
+				delete(h, k)
+			}
+		}
+	case CORSWrongOrigin:
+		h.Set("Access-Control-Allow-Origin", corsWrongOrigin)
+	case CORSWildcardCredentials:
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// parseCORSError parses the name of a CORSError. An empty name is CORSStrip.
+func parseCORSError(s string) (CORSError, error) {
+	if s == "" {
+		return CORSStrip, nil
+	}
+	e, ok := corsErrors[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid error %s", strconv.Quote(s))
+	}
+	return e, nil
+}
@@ -0,0 +1,48 @@
+package fault
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// CounterSampler selects requests deterministically by their sequence number instead of the ratio,
+// which makes integration tests and demos predictable.
+// Requests are numbered from 1 in the order the sampler sees them. A request is selected if
+// it is a multiple of Every and is in the range [From, To].
+// For example, Every: 10 selects the 10th, 20th, ... requests, and From: 5, To: 15 selects the 5th to 15th requests.
+type CounterSampler struct {
+	// n must be the first field to be 64-bit aligned for atomic operations.
+	n uint64
+
+	// Every selects every Every-th request. Zero or one selects every request.
+	Every int
+	// From is the first request to select. Zero means no lower bound.
+	From int
+	// To is the last request to select. Zero means no upper bound.
+	To int
+}
+
+// Sample counts the request and returns whether it is selected. ratio is ignored.
+func (s *CounterSampler) Sample(_ *http.Request, _ float64) bool {
+	n := atomic.AddUint64(&s.n, 1)
+	if s.From > 0 && n < uint64(s.From) {
+		return false
+	}
+	if s.To > 0 && n > uint64(s.To) {
+		return false
+	}
+	if s.Every > 1 && n%uint64(s.Every) != 0 {
+		return false
+	}
+	return true
+}
+
+// Count returns the number of requests the sampler has seen.
+func (s *CounterSampler) Count() int {
+	return int(atomic.LoadUint64(&s.n))
+}
+
+// Reset restarts the numbering from 1.
+func (s *CounterSampler) Reset() {
+	atomic.StoreUint64(&s.n, 0)
+}
@@ -0,0 +1,61 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// CPU burns CPU in busy loops to simulate the contention by noisy neighbors, so that timeouts and queueing
+// under compute starvation can be tested.
+// By default, the request itself burns CPU for Duration before it is proxied to next, which is like a request
+// starved by the others. If Goroutines is set, that many goroutines burn CPU in background for Duration while
+// the request is proxied to next as usual, which slows down every request in the process.
+type CPU struct {
+	// Duration is how long CPU is burned.
+	Duration time.Duration
+	// Goroutines is the number of goroutines burning CPU in background. If zero, the request burns CPU itself.
+	Goroutines int
+}
+
+// Handle burns CPU and proxies the request to next.
+func (f *CPU) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if f.Goroutines <= 0 {
+		burn(r.Context(), f.Duration)
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	for i := 0; i < f.Goroutines; i++ {
+		go burn(context.Background(), f.Duration)
+	}
+	next.ServeHTTP(w, r)
+}
+
+// Validate checks Duration and Goroutines are not negative.
+func (f *CPU) Validate() error {
+	if err := validateDuration("duration", f.Duration); err != nil {
+		return err
+	}
+	return validateCount("goroutines", f.Goroutines)
+}
+
+// burn keeps a CPU busy for d, or until ctx is done.
+func burn(ctx context.Context, d time.Duration) {
+	deadline := time.Now().Add(d)
+	x := uint64(1)
+	for i := 0; ; i++ {
+		// Check the time and ctx only once in a while so that most of the time is spent for the computation.
+		if i%1000 == 0 {
+			if !time.Now().Before(deadline) || ctx.Err() != nil {
+				break
+			}
+		}
+		x = mix64(x)
+	}
+	atomic.AddUint64(&burnSink, x)
+}
+
+// burnSink keeps the computation in burn from being optimized away.
+var burnSink uint64
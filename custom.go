@@ -0,0 +1,44 @@
+package fault
+
+import "net/http"
+
+// FaultFunc is an adapter to allow the use of ordinary functions as Fault, so that a one-off fault can be written
+// inline. It is applied by a Handler in the same way as the built-in faults, so Chain, Matchers, Schedule,
+// Budget, the stats and the Hooks work with it as they are. For example, a fault corrupting the response:
+//
+//	f := fault.FaultFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+//		w.Header().Set("Content-Type", "application/json")
+//		w.Write([]byte(`{"broken":`))
+//	})
+//	h := fault.New(fault.Named("truncated_json", f), 0.1)
+//
+// FaultName reports a FaultFunc as "fault_func"; give it a name by Named.
+// To make a custom fault available in Config and Override, register it by RegisterEffect.
+type FaultFunc func(w http.ResponseWriter, r *http.Request, next http.Handler)
+
+// Handle calls f(w, r, next).
+func (f FaultFunc) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	f(w, r, next)
+}
+
+// Named returns the Fault applying f, which FaultName reports as name, such as in the marker header and the stats.
+func Named(name string, f Fault) Fault {
+	return &namedFault{f: f, name: name}
+}
+
+type namedFault struct {
+	f    Fault
+	name string
+}
+
+func (f *namedFault) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	f.f.Handle(w, r, next)
+}
+
+func (f *namedFault) Name() string {
+	return f.name
+}
+
+func (f *namedFault) Validate() error {
+	return validateFault(f.f)
+}
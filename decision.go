@@ -0,0 +1,207 @@
+package fault
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Handler takes every request through the same pipeline:
+//
+//  1. Matchers: every Matcher must accept the request, otherwise it is passed through.
+//  2. Sampler: the Sampler decides whether the matched request is selected, given the ratio.
+//  3. Effect: the Fault is applied to the selected request.
+//
+// Each evaluation results in an Outcome, which is handed to the Hooks.
+// Matcher, Sampler, Effect, Outcome and Hook are stable extension points;
+// third party modules can implement them and register effects and samplers by name
+// using RegisterEffect and RegisterSampler.
+
+// Decision is the verdict a Handler reached for a single request.
+type Decision int
+
+const (
+	// Pass means the request is proxied to the next handler untouched.
+	Pass Decision = iota
+	// Inject means the fault is applied to the request.
+	Inject
+	// DryRun means the fault would have been applied, but the request is proxied untouched
+	// because the Handler is in dry-run mode.
+	DryRun
+)
+
+// String returns the lower-cased name of the decision.
+func (d Decision) String() string {
+	switch d {
+	case Pass:
+		return "pass"
+	case Inject:
+		return "inject"
+	case DryRun:
+		return "dry_run"
+	default:
+		return fmt.Sprintf("decision(%d)", int(d))
+	}
+}
+
+// Outcome describes how a Handler treated a single request.
+type Outcome struct {
+	// Fault is the fault to be applied. It is the fault the Handler is configured with,
+	// unless the request overrides it.
+	Fault Fault
+	// Decision is the verdict for the request.
+	Decision Decision
+	// Reason tells why the Decision was reached.
+	// Built-in reasons are "kill switch", "disabled", "not started", "expired", "off schedule", "excluded", "bypassed", "override", "envoy", "unsupported", "unmatched", "unsampled", "budget exhausted" and "sampled".
+	Reason string
+	// Request is the evaluated request. Hooks must not modify it.
+	Request *http.Request
+	// RequestID is the ID of the request in the header named by RequestIDHeader of the Handler.
+	// Empty if the header is not set or the request does not have it.
+	RequestID string
+	// Time is when the decision was made.
+	Time time.Time
+	// Err is the error found in the decision, such as an invalid Override header.
+	Err error
+}
+
+// Hook is called with the Outcome of every request evaluated by a Handler.
+// Hooks are called synchronously before the fault is applied, so they must not block.
+type Hook func(o Outcome)
+
+// Matcher tells whether a request is a target of the fault.
+type Matcher interface {
+	Match(r *http.Request) bool
+}
+
+// MatcherFunc is an adapter to allow the use of ordinary functions as Matcher.
+type MatcherFunc func(r *http.Request) bool
+
+// Match calls f(r).
+func (f MatcherFunc) Match(r *http.Request) bool {
+	return f(r)
+}
+
+// Sampler decides whether a matched request is selected for injection.
+// ratio is the configured injection ratio in [0, 1]; a Sampler is free to ignore it.
+// Samplers are called concurrently, so they must be safe for concurrent use.
+type Sampler interface {
+	Sample(r *http.Request, ratio float64) bool
+}
+
+// SamplerFunc is an adapter to allow the use of ordinary functions as Sampler.
+type SamplerFunc func(r *http.Request, ratio float64) bool
+
+// Sample calls f(r, ratio).
+func (f SamplerFunc) Sample(r *http.Request, ratio float64) bool {
+	return f(r, ratio)
+}
+
+// RandomSampler selects requests at random; the probability to be selected equals to the ratio.
+// This is the default Sampler of the Handler.
+type RandomSampler struct {
+	r  *rand.Rand
+	mu sync.Mutex
+}
+
+// NewRandomSampler returns a RandomSampler seeded with the current time.
+func NewRandomSampler() *RandomSampler {
+	return &RandomSampler{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Sample returns true with the probability of ratio.
+func (s *RandomSampler) Sample(_ *http.Request, ratio float64) bool {
+	s.mu.Lock()
+	f := s.r.Float64()
+	s.mu.Unlock()
+	return f < ratio
+}
+
+// Effect builds a Fault from textual parameters.
+// Effects are registered by name so that faults can be described by text, such as config files.
+type Effect func(params map[string]string) (Fault, error)
+
+// SamplerFactory builds a Sampler from textual parameters.
+type SamplerFactory func(params map[string]string) (Sampler, error)
+
+var (
+	registryMu sync.RWMutex
+	effects    = map[string]Effect{}
+	samplers   = map[string]SamplerFactory{}
+)
+
+// RegisterEffect makes an Effect available by the given name.
+// If RegisterEffect is called twice with the same name or if e is nil, it panics.
+func RegisterEffect(name string, e Effect) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if e == nil {
+		panic("fault: RegisterEffect effect is nil")
+	}
+	if _, dup := effects[name]; dup {
+		panic("fault: RegisterEffect called twice for effect " + name)
+	}
+	effects[name] = e
+}
+
+// LookupEffect returns the Effect registered by the name.
+func LookupEffect(name string) (Effect, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	e, ok := effects[name]
+	return e, ok
+}
+
+// Effects returns a sorted list of the names of the registered effects.
+func Effects() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return sortedKeys(len(effects), func(f func(string)) {
+		for k := range effects {
+			f(k)
+		}
+	})
+}
+
+// RegisterSampler makes a SamplerFactory available by the given name.
+// If RegisterSampler is called twice with the same name or if s is nil, it panics.
+func RegisterSampler(name string, s SamplerFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if s == nil {
+		panic("fault: RegisterSampler sampler is nil")
+	}
+	if _, dup := samplers[name]; dup {
+		panic("fault: RegisterSampler called twice for sampler " + name)
+	}
+	samplers[name] = s
+}
+
+// LookupSampler returns the SamplerFactory registered by the name.
+func LookupSampler(name string) (SamplerFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	s, ok := samplers[name]
+	return s, ok
+}
+
+// Samplers returns a sorted list of the names of the registered samplers.
+func Samplers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return sortedKeys(len(samplers), func(f func(string)) {
+		for k := range samplers {
+			f(k)
+		}
+	})
+}
+
+func sortedKeys(n int, each func(func(string))) []string {
+	keys := make([]string, 0, n)
+	each(func(k string) { keys = append(keys, k) })
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,77 @@
+package fault
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+)
+
+// StatusDistribution responds an error as Error does, with the status code sampled per injection from
+// a weighted distribution, to simulate the mix of errors a flaky upstream responds, such as:
+//
+//	&fault.StatusDistribution{Weights: map[int]float64{500: 60, 502: 20, 503: 15, 429: 5}}
+type StatusDistribution struct {
+	// Weights are the relative weights of the status codes. They need not sum up to 1 or 100. Required.
+	Weights map[int]float64
+	// StatusText is used as HTTP response body. The same as the one in Error.
+	StatusText string
+	// XML formats the body in XML. The same as the one in Error.
+	XML bool
+}
+
+// Handle responds the error of the sampled status code.
+func (f *StatusDistribution) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	writeError(w, r, f.sample(), f.StatusText, f.XML)
+}
+
+// Validate checks the status codes are valid, and the weights are not negative and not all zero.
+func (f *StatusDistribution) Validate() error {
+	var total float64
+	for code, w := range f.Weights {
+		if err := validateStatus(code); err != nil {
+			return err
+		}
+		if math.IsNaN(w) || w < 0 {
+			return fmt.Errorf("weight %v of status code %d must not be negative", w, code)
+		}
+		total += w
+	}
+	if total == 0 {
+		return fmt.Errorf("weights are required")
+	}
+	return nil
+}
+
+// sample returns a status code at random by the weights.
+func (f *StatusDistribution) sample() int {
+	// The codes are sorted so that the same random number always gives the same code.
+	codes := make([]int, 0, len(f.Weights))
+	var total float64
+	for code, w := range f.Weights {
+		if w > 0 {
+			codes = append(codes, code)
+			total += w
+		}
+	}
+	if len(codes) == 0 {
+		return http.StatusInternalServerError
+	}
+	sort.Ints(codes)
+
+	x := randomFloat() * total
+	for _, code := range codes {
+		x -= f.Weights[code]
+		if x < 0 {
+			return code
+		}
+	}
+	return codes[len(codes)-1]
+}
+
+// randomFloat returns a random number in [0, 1).
+func randomFloat() float64 {
+	defaultSampler.mu.Lock()
+	defer defaultSampler.mu.Unlock()
+	return defaultSampler.r.Float64()
+}
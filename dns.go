@@ -0,0 +1,126 @@
+package fault
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNSFault injects faults into the name resolution of the configured hosts: slow lookups, NXDOMAIN and timeouts,
+// so that the client code caching or retrying lookups can be tested.
+// It is used either in place of net.Resolver by its Lookup methods, or in front of a dialer by DialContext.
+//
+//	tr := &http.Transport{DialContext: (&fault.DNSFault{Hosts: []string{"api.example.com"}, NotFoundRatio: 0.5}).DialContext(nil)}
+//
+// The faults are not injected while the kill switch is on.
+type DNSFault struct {
+	// Hosts are the hostnames whose lookups are injected. A name starting with "*." matches the subdomains.
+	// If empty, every host is injected. IP addresses are never injected.
+	Hosts []string
+	// Delay slows down every lookup.
+	Delay time.Duration
+	// NotFoundRatio is the ratio of lookups failing with NXDOMAIN, in [0, 1].
+	NotFoundRatio float64
+	// TimeoutRatio is the ratio of lookups timing out, in [0, 1].
+	TimeoutRatio float64
+	// Timeout is how long the timing out lookups hang before failing, unless the context is done earlier.
+	// If zero, they fail immediately.
+	Timeout time.Duration
+	// Resolver resolves the names which are not failed. If nil, net.DefaultResolver is used.
+	Resolver *net.Resolver
+}
+
+func (f *DNSFault) resolver() *net.Resolver {
+	if f.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return f.Resolver
+}
+
+// LookupHost looks up the host as net.Resolver does, with the faults injected.
+func (f *DNSFault) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if err := f.inject(ctx, host); err != nil {
+		return nil, err
+	}
+	return f.resolver().LookupHost(ctx, host)
+}
+
+// LookupIPAddr looks up the host as net.Resolver does, with the faults injected.
+func (f *DNSFault) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if err := f.inject(ctx, host); err != nil {
+		return nil, err
+	}
+	return f.resolver().LookupIPAddr(ctx, host)
+}
+
+// DialContext returns a dial function, such as for http.Transport.DialContext, which injects the faults
+// into the lookup of the address before calling dial. If dial is nil, net.Dialer's DialContext is used.
+// Failed lookups are returned as *net.OpError wrapping *net.DNSError, as the dialer does.
+func (f *DNSFault) DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{Resolver: f.Resolver}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if err := f.inject(ctx, host); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// inject delays the lookup of the host, or returns the error it fails with.
+func (f *DNSFault) inject(ctx context.Context, host string) error {
+	if AllDisabled() || !f.match(host) {
+		return nil
+	}
+
+	if f.Delay > 0 {
+		if err := sleepContext(ctx, f.Delay); err != nil {
+			return &net.DNSError{Err: err.Error(), Name: host}
+		}
+	}
+
+	if defaultSampler.Sample(nil, f.NotFoundRatio) {
+		return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	if defaultSampler.Sample(nil, f.TimeoutRatio) {
+		if f.Timeout > 0 {
+			sleepContext(ctx, f.Timeout)
+		}
+		return &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+	}
+
+	return nil
+}
+
+func (f *DNSFault) match(host string) bool {
+	if net.ParseIP(host) != nil {
+		return false
+	}
+	if len(f.Hosts) == 0 {
+		return true
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, h := range f.Hosts {
+		h = strings.ToLower(strings.TrimSuffix(h, "."))
+		if strings.HasPrefix(h, "*.") {
+			if strings.HasSuffix(host, h[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == h {
+			return true
+		}
+	}
+
+	return false
+}
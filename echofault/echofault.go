@@ -0,0 +1,51 @@
+// Package echofault adapts the faults of package fault to Echo.
+//
+//	e.Use(echofault.Middleware(fault.New(&fault.Error{StatusCode: 503}, 0.1)))
+//
+// Echo's route pattern of the request, such as "/users/:id", is available to fault.Route,
+// so the faults can target the routes:
+//
+//	h := fault.New(&fault.Delay{Duration: time.Second}, 0.5)
+//	h.Matchers = []fault.Matcher{fault.Route("/users/:id")}
+package echofault
+
+import (
+	"net/http"
+
+	"github.com/hidetatz/fault"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware returns an echo.MiddlewareFunc applying the faults of m, such as *fault.Handler, fault.Chain
+// and *fault.Reloader, to the requests.
+// If a fault wraps the response writer, such as SSE, the handlers write through it. The errors of the handlers
+// are handled by Echo's HTTPErrorHandler through the writer too, so that the faults see the error responses.
+func Middleware(m fault.Middleware) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			res := c.Response()
+			var wrapped *echo.Response
+			h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				if w != http.ResponseWriter(res) {
+					wrapped = echo.NewResponse(w, c.Echo())
+					c.SetResponse(wrapped)
+					defer c.SetResponse(res)
+				}
+				if err := next(c); err != nil {
+					c.Error(err)
+				}
+			}))
+
+			r := c.Request()
+			h.ServeHTTP(res, r.WithContext(fault.WithRoute(r.Context(), c.Path())))
+			// The fault writes what the handlers wrote to res, unless it drops the response.
+			// Mark res committed anyway, so that Echo does not write the response again.
+			if wrapped != nil && wrapped.Committed && !res.Committed {
+				res.Committed = true
+				res.Status = wrapped.Status
+			}
+			return nil
+		}
+	}
+}
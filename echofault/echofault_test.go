@@ -0,0 +1,86 @@
+package echofault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hidetatz/fault"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddlewareHandlerError(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		f      fault.Fault
+		marker string
+		ratio  float64
+	}{
+		{"not injected", &fault.Delay{Duration: time.Millisecond}, "", 0},
+		{"injected", &fault.Delay{Duration: time.Millisecond}, "", 1},
+		// The marker header wraps the writer.
+		{"wrapped", &fault.Delay{Duration: time.Millisecond}, "X-Fault", 1},
+		// The fault writes the response after the handler returns.
+		{"buffered", &fault.LatencyAmplification{Factor: 0.1}, "", 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := fault.New(tc.f, tc.ratio)
+			h.MarkerHeader = tc.marker
+			e := echo.New()
+			e.Use(Middleware(h))
+			e.GET("/", func(c echo.Context) error { return echo.NewHTTPError(http.StatusTeapot, "teapot") })
+
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != http.StatusTeapot {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusTeapot)
+			}
+			if want := `{"message":"teapot"}` + "\n"; w.Body.String() != want {
+				t.Errorf("body = %q, want %q", w.Body.String(), want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRoute(t *testing.T) {
+	h := fault.New(&fault.Error{StatusCode: http.StatusServiceUnavailable}, 1)
+	h.Matchers = []fault.Matcher{fault.Route("/users/:id")}
+	e := echo.New()
+	e.Use(Middleware(h))
+	e.GET("/users/:id", func(c echo.Context) error { return c.String(http.StatusOK, c.Param("id")) })
+	e.GET("/groups/:id", func(c echo.Context) error { return c.String(http.StatusOK, c.Param("id")) })
+
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/users/1", http.StatusServiceUnavailable},
+		{"/groups/1", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.path, w.Code, tc.status)
+		}
+	}
+	if n := h.Stats().Injected; n != 1 {
+		t.Errorf("injected = %d, want 1", n)
+	}
+}
+
+func TestMiddlewareDroppedResponse(t *testing.T) {
+	// The fault drops what the handler wrote, so Echo must not write the error response instead.
+	drop := fault.FaultFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		next.ServeHTTP(httptest.NewRecorder(), r)
+	})
+	e := echo.New()
+	e.Use(Middleware(fault.New(drop, 1)))
+	e.GET("/", func(c echo.Context) error { return echo.NewHTTPError(http.StatusTeapot) })
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want %d with no body", w.Code, w.Body.String(), http.StatusOK)
+	}
+}
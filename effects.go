@@ -0,0 +1,661 @@
+package fault
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+func init() {
+	RegisterEffect("delay", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &Delay{}
+		var err error
+		if f.Duration, err = ps.duration("duration"); err != nil {
+			return nil, err
+		}
+		if f.Afterward, err = ps.bool("afterward"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("error", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &Error{}
+		var err error
+		if f.StatusCode, err = ps.int("status"); err != nil {
+			return nil, err
+		}
+		f.StatusText = ps.string("text")
+		if f.XML, err = ps.bool("xml"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("delay_with_error", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &DelayWithError{}
+		var err error
+		if f.Duration, err = ps.duration("duration"); err != nil {
+			return nil, err
+		}
+		if f.StatusCode, err = ps.int("status"); err != nil {
+			return nil, err
+		}
+		f.StatusText = ps.string("text")
+		if f.XML, err = ps.bool("xml"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("abort", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &Abort{}
+		if v := ps.string("panic"); v != "" {
+			f.Value = RuntimeError(v)
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("delay_with_abort", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &DelayWithAbort{}
+		var err error
+		if f.Duration, err = ps.duration("duration"); err != nil {
+			return nil, err
+		}
+		if v := ps.string("panic"); v != "" {
+			f.Value = RuntimeError(v)
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("rate_limit", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &RateLimit{}
+		var err error
+		if f.Limit, err = ps.int("limit"); err != nil {
+			return nil, err
+		}
+		if f.Window, err = ps.duration("window"); err != nil {
+			return nil, err
+		}
+		if f.ResetAfter, err = ps.bool("reset_after"); err != nil {
+			return nil, err
+		}
+		f.StatusText = ps.string("text")
+		return f, ps.done()
+	})
+	RegisterEffect("concurrency", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &Concurrency{}
+		var err error
+		if f.Max, err = ps.int("max"); err != nil {
+			return nil, err
+		}
+		if f.Delay, err = ps.duration("delay"); err != nil {
+			return nil, err
+		}
+		if f.StatusCode, err = ps.int("status"); err != nil {
+			return nil, err
+		}
+		f.StatusText = ps.string("text")
+		return f, ps.done()
+	})
+	RegisterEffect("cpu", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &CPU{}
+		var err error
+		if f.Duration, err = ps.duration("duration"); err != nil {
+			return nil, err
+		}
+		if f.Goroutines, err = ps.int("goroutines"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("sse", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &SSE{}
+		var err error
+		if f.Stall, err = ps.duration("stall"); err != nil {
+			return nil, err
+		}
+		if f.DropRatio, err = ps.float("drop_ratio"); err != nil {
+			return nil, err
+		}
+		if f.MaxEvents, err = ps.int("max_events"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("grpc_error", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &GRPCError{Message: ps.string("message")}
+		if p["code"] == "" {
+			return nil, fmt.Errorf("code is required")
+		}
+		var err error
+		if f.Code, err = ps.int("code"); err != nil {
+			return nil, err
+		}
+		retryDelay, err := ps.duration("retry_delay")
+		if err != nil {
+			return nil, err
+		}
+		if retryDelay > 0 {
+			f.Details = append(f.Details, RetryInfo(retryDelay))
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("connect_error", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &ConnectError{Message: ps.string("message")}
+		var err error
+		if f.Code, err = ps.int("code"); err != nil {
+			return nil, err
+		}
+		retryDelay, err := ps.duration("retry_delay")
+		if err != nil {
+			return nil, err
+		}
+		if retryDelay > 0 {
+			f.Details = append(f.Details, RetryInfo(retryDelay))
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("graphql_error", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &GraphQLError{Message: ps.string("message"), Code: ps.string("code")}
+		var err error
+		if f.FieldRatio, err = ps.float("field_ratio"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("hang", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &Hang{}
+		var err error
+		if f.Max, err = ps.duration("max"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("partial_response", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &PartialResponse{}
+		var err error
+		if f.Fraction, err = ps.float("fraction"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("malformed_chunked", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &MalformedChunked{}
+		var err error
+		if f.Error, err = parseChunkedError(ps.string("error")); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("content_length_mismatch", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &ContentLengthMismatch{}
+		var err error
+		if f.Delta, err = ps.int("delta"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("bad_compression", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &BadCompression{}
+		var err error
+		if f.Corrupt, err = ps.bool("corrupt"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("large_header", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &LargeHeader{Name: ps.string("name")}
+		var err error
+		if f.KB, err = ps.int("kb"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("large_body", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &LargeBody{}
+		var err error
+		if f.MB, err = ps.int("mb"); err != nil {
+			return nil, err
+		}
+		if f.Replace, err = ps.bool("replace"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("empty_success", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &EmptySuccess{ContentType: ps.string("content_type")}
+		var err error
+		if f.OmitContentLength, err = ps.bool("omit_content_length"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("auth_failure", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &AuthFailure{Scheme: ps.string("scheme"), Realm: ps.string("realm")}
+		var err error
+		if f.Error, err = parseAuthError(ps.string("error")); err != nil {
+			return nil, err
+		}
+		if f.Body, err = parseAuthBody(ps.string("body")); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("mock", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		path := ps.string("file")
+		if err := ps.done(); err != nil {
+			return nil, err
+		}
+		if path == "" {
+			return nil, fmt.Errorf("file is required")
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseMock(b)
+	})
+	RegisterEffect("openapi", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		path := ps.string("file")
+		status, err := ps.int("status")
+		if err != nil {
+			return nil, err
+		}
+		if err := ps.done(); err != nil {
+			return nil, err
+		}
+		if path == "" {
+			return nil, fmt.Errorf("file is required")
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parseOpenAPI(b)
+		if err != nil {
+			return nil, err
+		}
+		if status != 0 {
+			f.Statuses = []int{status}
+		}
+		return f, nil
+	})
+	RegisterEffect("status_distribution", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &StatusDistribution{StatusText: ps.string("text"), Weights: map[int]float64{}}
+		var err error
+		if f.XML, err = ps.bool("xml"); err != nil {
+			return nil, err
+		}
+		// The other parameters are the weights keyed by the status codes, such as "500=60".
+		for k := range ps {
+			code, err := strconv.Atoi(k)
+			if err != nil {
+				continue
+			}
+			if f.Weights[code], err = ps.float(k); err != nil {
+				return nil, err
+			}
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("fail_first", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &FailFirst{}
+		var err error
+		if f.Attempts, err = ps.int("attempts"); err != nil {
+			return nil, err
+		}
+		if f.TTL, err = ps.duration("ttl"); err != nil {
+			return nil, err
+		}
+		if h := ps.string("header"); h != "" {
+			f.Key = HeaderKey(h)
+		}
+		status, err := ps.int("status")
+		if err != nil {
+			return nil, err
+		}
+		if status != 0 {
+			f.Fault = &Error{StatusCode: status}
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("circuit_breaker", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &CircuitBreaker{}
+		var err error
+		if f.ErrorRatio, err = ps.float("error_ratio"); err != nil {
+			return nil, err
+		}
+		if f.Threshold, err = ps.int("threshold"); err != nil {
+			return nil, err
+		}
+		if f.Cooldown, err = ps.duration("cooldown"); err != nil {
+			return nil, err
+		}
+		if f.HalfOpenRequests, err = ps.int("half_open"); err != nil {
+			return nil, err
+		}
+		status, err := ps.int("status")
+		if err != nil {
+			return nil, err
+		}
+		if status != 0 {
+			f.Fault = &Error{StatusCode: status}
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("load_shed", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &LoadShed{Header: ps.string("header")}
+		if v := ps.string("priority"); v != "" {
+			f.Priorities = []string{v}
+		}
+		var err error
+		if f.RetryAfter, err = ps.duration("retry_after"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("clock_skew", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &ClockSkew{}
+		var err error
+		if f.Skew, err = ps.duration("skew"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("session_expiry", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &SessionExpiry{}
+		var err error
+		if f.After, err = ps.duration("after"); err != nil {
+			return nil, err
+		}
+		header, cookie, claim := ps.string("header"), ps.string("cookie"), ps.string("claim")
+		switch {
+		case header != "" && cookie == "" && claim == "":
+			f.Session = HeaderKey(header)
+		case header == "" && cookie != "" && claim == "":
+			f.Session = CookieKey(cookie)
+		case header == "" && cookie == "" && claim != "":
+			f.Session = JWTClaimKey(claim)
+		default:
+			return nil, fmt.Errorf("exactly one of header, cookie or claim is required")
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("bad_conditional", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &BadConditional{}
+		var err error
+		if f.Error, err = parseConditionalError(ps.string("error")); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("bad_cors", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &BadCORS{}
+		var err error
+		if f.Error, err = parseCORSError(ps.string("error")); err != nil {
+			return nil, err
+		}
+		if f.PreflightRatio, err = ps.float("preflight_ratio"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("bad_expect", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &BadExpect{}
+		var err error
+		if f.Error, err = parseExpectError(ps.string("error")); err != nil {
+			return nil, err
+		}
+		if f.Delay, err = ps.duration("delay"); err != nil {
+			return nil, err
+		}
+		if f.StatusCode, err = ps.int("status"); err != nil {
+			return nil, err
+		}
+		if f.XML, err = ps.bool("xml"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("bad_trailer", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &BadTrailer{}
+		if v := ps.string("name"); v != "" {
+			f.Names = []string{v}
+		}
+		var err error
+		if f.Error, err = parseTrailerError(ps.string("error")); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("transport_error", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &TransportError{}
+		var err error
+		if f.Error, err = parseNetError(ps.string("error")); err != nil {
+			return nil, err
+		}
+		if f.Timeout, err = ps.duration("timeout"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("slow_upload", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &SlowUpload{}
+		var err error
+		if f.Bandwidth, err = ps.int("bandwidth"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("fuzzed_body", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &FuzzedBody{}
+		var err error
+		if f.Charset, err = parseFuzzCharset(ps.string("charset")); err != nil {
+			return nil, err
+		}
+		if f.Size, err = ps.int("size"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("baseline_delay", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		name := ps.string("baseline")
+		if name == "" {
+			return nil, fmt.Errorf("baseline is required")
+		}
+		b, ok := LookupBaseline(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown baseline %q", name)
+		}
+		f := &BaselineDelay{Baseline: b}
+		var err error
+		if f.Percentile, err = ps.float("percentile"); err != nil {
+			return nil, err
+		}
+		if f.Factor, err = ps.float("factor"); err != nil {
+			return nil, err
+		}
+		if f.Fallback, err = ps.duration("fallback"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+	RegisterEffect("latency_amplification", func(p map[string]string) (Fault, error) {
+		ps := newParams(p)
+		f := &LatencyAmplification{}
+		var err error
+		if f.Factor, err = ps.float("factor"); err != nil {
+			return nil, err
+		}
+		if f.Max, err = ps.duration("max"); err != nil {
+			return nil, err
+		}
+		return f, ps.done()
+	})
+
+	RegisterSampler("random", func(p map[string]string) (Sampler, error) {
+		return NewRandomSampler(), newParams(p).done()
+	})
+	RegisterSampler("counter", func(p map[string]string) (Sampler, error) {
+		ps := newParams(p)
+		s := &CounterSampler{}
+		var err error
+		if s.Every, err = ps.int("every"); err != nil {
+			return nil, err
+		}
+		if s.From, err = ps.int("from"); err != nil {
+			return nil, err
+		}
+		if s.To, err = ps.int("to"); err != nil {
+			return nil, err
+		}
+		if s.Every < 0 || s.From < 0 || s.To < 0 {
+			return nil, fmt.Errorf("every, from and to must not be negative")
+		}
+		return s, ps.done()
+	})
+	RegisterSampler("redis", func(p map[string]string) (Sampler, error) {
+		ps := newParams(p)
+		c := &RedisCounter{Addr: ps.string("addr"), Password: ps.string("password")}
+		s := &GlobalSampler{Counter: c, Key: ps.string("key")}
+		var err error
+		if c.DB, err = ps.int("db"); err != nil {
+			return nil, err
+		}
+		if s.Timeout, err = ps.duration("timeout"); err != nil {
+			return nil, err
+		}
+		if c.Addr == "" || s.Key == "" {
+			return nil, fmt.Errorf("addr and key are required")
+		}
+		return s, ps.done()
+	})
+	RegisterSampler("sticky", func(p map[string]string) (Sampler, error) {
+		ps := newParams(p)
+		s := &StickySampler{Salt: ps.string("salt")}
+		header, cookie := ps.string("header"), ps.string("cookie")
+		clientIP, err := ps.bool("client_ip")
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case header != "" && cookie == "" && !clientIP:
+			s.Key = HeaderKey(header)
+		case header == "" && cookie != "" && !clientIP:
+			s.Key = CookieKey(cookie)
+		case header == "" && cookie == "" && clientIP:
+			s.Key = ClientIPKey
+		default:
+			return nil, fmt.Errorf("exactly one of header, cookie or client_ip is required")
+		}
+		return s, ps.done()
+	})
+}
+
+// params helps effects and samplers to parse their textual parameters.
+// Every read parameter is removed so that done can report unknown ones.
+type params map[string]string
+
+func newParams(m map[string]string) params {
+	p := make(params, len(m))
+	for k, v := range m {
+		p[k] = v
+	}
+	return p
+}
+
+func (p params) string(key string) string {
+	v := p[key]
+	delete(p, key)
+	return v
+}
+
+func (p params) duration(key string) (time.Duration, error) {
+	v := p.string(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return d, nil
+}
+
+func (p params) int(key string) (int, error) {
+	v := p.string(key)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return i, nil
+}
+
+func (p params) float(key string) (float64, error) {
+	v := p.string(key)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return f, nil
+}
+
+func (p params) bool(key string) (bool, error) {
+	v := p.string(key)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return b, nil
+}
+
+// done returns an error if any parameter is left unread.
+func (p params) done() error {
+	for k := range p {
+		return fmt.Errorf("unknown parameter %q", k)
+	}
+	return nil
+}
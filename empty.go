@@ -0,0 +1,34 @@
+package fault
+
+import (
+	"net/http"
+)
+
+// EmptySuccess responds 200 OK with an empty body without calling the next handler, since the "successful" but
+// empty responses break the clients more often than the errors do, such as a JSON decoder failing on EOF.
+// By default, the response has "Content-Length: 0". If OmitContentLength is true, it has no Content-Length,
+// and the empty body is sent in the chunked encoding on HTTP/1.1.
+type EmptySuccess struct {
+	// ContentType is the Content-Type of the response, such as "application/json". Optional.
+	ContentType string
+	// OmitContentLength sends the response without Content-Length.
+	OmitContentLength bool
+}
+
+// Handle responds the empty success.
+func (f *EmptySuccess) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if f.ContentType != "" {
+		w.Header().Set("Content-Type", f.ContentType)
+	}
+	if !f.OmitContentLength {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	// Flushing before the handler returns keeps net/http from adding "Content-Length: 0".
+	if fl, ok := w.(http.Flusher); ok {
+		fl.Flush()
+	}
+}
@@ -0,0 +1,64 @@
+package fault
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Envoy honors the request headers of Envoy's fault filter, so test suites written against Envoy
+// work unchanged against services using this package. The supported headers are:
+//
+//	x-envoy-fault-delay-request: the delay in milliseconds.
+//	x-envoy-fault-delay-request-percentage: the percentage of requests to delay. Defaults to 100.
+//	x-envoy-fault-abort-request: the HTTP status code to respond, in [200, 600).
+//	x-envoy-fault-abort-request-percentage: the percentage of requests to abort. Defaults to 100.
+//
+// Invalid values are ignored as Envoy does. gRPC aborts and throughput limits are not supported.
+// If both delay and abort are requested, the request is delayed then aborted.
+type Envoy struct {
+	// Allow is called to check whether the request is allowed to inject faults by the headers.
+	// If nil, every request is allowed, which is how Envoy behaves when header faults are enabled.
+	Allow func(r *http.Request) bool
+}
+
+func (e *Envoy) fault(r *http.Request) Fault {
+	if e.Allow != nil && !e.Allow(r) {
+		return nil
+	}
+
+	var fs sequence
+	if ms, err := strconv.ParseInt(r.Header.Get("x-envoy-fault-delay-request"), 10, 64); err == nil && ms > 0 {
+		if envoyPercentage(r, "x-envoy-fault-delay-request-percentage") {
+			fs = append(fs, &Delay{Duration: time.Duration(ms) * time.Millisecond})
+		}
+	}
+	if code, err := strconv.Atoi(r.Header.Get("x-envoy-fault-abort-request")); err == nil && code >= 200 && code < 600 {
+		if envoyPercentage(r, "x-envoy-fault-abort-request-percentage") {
+			fs = append(fs, &Error{StatusCode: code, StatusText: "fault filter abort"})
+		}
+	}
+
+	switch len(fs) {
+	case 0:
+		return nil
+	case 1:
+		return fs[0]
+	default:
+		return fs
+	}
+}
+
+// envoyPercentage flips a coin by the percentage in the header.
+func envoyPercentage(r *http.Request, header string) bool {
+	v := r.Header.Get(header)
+	if v == "" {
+		return true
+	}
+	pct, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return true
+	}
+
+	return defaultSampler.Sample(r, float64(pct)/100)
+}
@@ -0,0 +1,194 @@
+package fault
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EtcdBackend is a ConfigBackend reading the config from a key in etcd v3.
+// It talks to the JSON gRPC gateway of etcd, so no etcd client library is required.
+// Changes are watched by the watch API.
+type EtcdBackend struct {
+	// Endpoint is the base URL of etcd. If empty, "http://127.0.0.1:2379" is used.
+	Endpoint string
+	// Key is the key the config is stored in. Required.
+	Key string
+	// Token is the auth token sent in the Authorization header. Optional.
+	Token string
+	// Client is used to call the API. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// String returns a description of the backend used in logs.
+func (e *EtcdBackend) String() string {
+	return "etcd:" + e.Key
+}
+
+type etcdKV struct {
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+// Watch implements ConfigBackend.
+func (e *EtcdBackend) Watch(ctx context.Context, update func(doc []byte), onError func(err error)) {
+	retry := newBackoff()
+	var rev int64
+	for ctx.Err() == nil {
+		// Read the current value first, so that nothing is missed while the watch is not running.
+		doc, cur, err := e.get(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			onError(err)
+			retry.wait(ctx)
+			continue
+		}
+		if cur != rev {
+			rev = cur
+			update(doc)
+		}
+
+		err = e.watch(ctx, rev+1, func(doc []byte, modRev int64) {
+			retry.reset()
+			rev = modRev
+			update(doc)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			onError(err)
+		}
+		retry.wait(ctx)
+	}
+}
+
+// get reads the key and returns its value and mod revision.
+func (e *EtcdBackend) get(ctx context.Context) ([]byte, int64, error) {
+	var res struct {
+		Kvs []etcdKV `json:"kvs"`
+	}
+	body := map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(e.Key))}
+	resp, err := e.post(ctx, "/v3/kv/range", body)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, 0, fmt.Errorf("fault: etcd: %w", err)
+	}
+	if len(res.Kvs) == 0 {
+		return nil, 0, fmt.Errorf("fault: etcd key %q not found", e.Key)
+	}
+
+	return decodeEtcdKV(res.Kvs[0])
+}
+
+// watch streams the changes of the key since the revision until the stream breaks or ctx is done.
+func (e *EtcdBackend) watch(ctx context.Context, since int64, f func(doc []byte, modRev int64)) error {
+	body := map[string]interface{}{
+		"create_request": map[string]string{
+			"key":            base64.StdEncoding.EncodeToString([]byte(e.Key)),
+			"start_revision": strconv.FormatInt(since, 10),
+		},
+	}
+	resp, err := e.post(ctx, "/v3/watch", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The response is a stream of JSON objects.
+	dec := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var msg struct {
+			Result struct {
+				Canceled     bool   `json:"canceled"`
+				CancelReason string `json:"cancel_reason"`
+				Events       []struct {
+					Type string `json:"type"`
+					Kv   etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			return fmt.Errorf("fault: etcd watch: %w", err)
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("fault: etcd watch: %s", msg.Error.Message)
+		}
+		if msg.Result.Canceled {
+			return fmt.Errorf("fault: etcd watch canceled: %s", msg.Result.CancelReason)
+		}
+
+		for _, ev := range msg.Result.Events {
+			if ev.Type == "DELETE" {
+				return fmt.Errorf("fault: etcd key %q deleted", e.Key)
+			}
+			doc, modRev, err := decodeEtcdKV(ev.Kv)
+			if err != nil {
+				return err
+			}
+			f(doc, modRev)
+		}
+	}
+}
+
+func (e *EtcdBackend) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := e.Endpoint
+	if endpoint == "" {
+		endpoint = "http://127.0.0.1:2379"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.Token != "" {
+		req.Header.Set("Authorization", e.Token)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fault: etcd: unexpected status %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+func decodeEtcdKV(kv etcdKV) ([]byte, int64, error) {
+	doc, err := base64.StdEncoding.DecodeString(kv.Value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fault: etcd: %w", err)
+	}
+	rev, err := strconv.ParseInt(kv.ModRevision, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fault: etcd: invalid mod_revision: %w", err)
+	}
+
+	return doc, rev, nil
+}
@@ -0,0 +1,95 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpectError is how BadExpect mishandles the requests sending "Expect: 100-continue".
+type ExpectError int
+
+const (
+	// ExpectWithhold withholds the 100 Continue interim response, so that the client waits for it before
+	// sending the body, or sends the body anyway after its own timeout.
+	ExpectWithhold ExpectError = iota
+	// ExpectEarlyFinal responds a final status without reading the body, so the body the client may have
+	// started sending is never consumed.
+	ExpectEarlyFinal
+)
+
+// expectErrors is the names of ExpectError, used by the effect.
+var expectErrors = map[string]ExpectError{
+	"withhold":    ExpectWithhold,
+	"early_final": ExpectEarlyFinal,
+}
+
+// BadExpect mishandles the requests sending "Expect: 100-continue", so that the clients uploading large bodies
+// can be tested against the servers not following the expectation. The other requests are passed to the next
+// handler as they are.
+//
+// The 100 Continue interim response is written by net/http when the next handler first reads the body,
+// so withholding it delays the call to the next handler.
+type BadExpect struct {
+	// Error is how the expectation is mishandled.
+	Error ExpectError
+	// Delay is how long ExpectWithhold withholds the interim response. If zero, it is withheld until
+	// the request context is done, and the next handler is never called.
+	Delay time.Duration
+	// StatusCode is the final status ExpectEarlyFinal responds. If zero, 417 Expectation Failed is used.
+	StatusCode int
+	// XML formats the body in XML to the clients accepting XML. See ErrorBody.
+	XML bool
+}
+
+// Handle mishandles the expectation of the request.
+func (f *BadExpect) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if !strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	switch f.Error {
+	case ExpectWithhold:
+		if f.Delay <= 0 {
+			<-r.Context().Done()
+			return
+		}
+		if err := sleepContext(r.Context(), f.Delay); err != nil {
+			return
+		}
+		next.ServeHTTP(w, r)
+	case ExpectEarlyFinal:
+		code := f.StatusCode
+		if code == 0 {
+			code = http.StatusExpectationFailed
+		}
+		// The body is unread, so net/http closes the connection after the response instead of draining it.
+		writeError(w, r, code, "fault: pseudo final response before the body is injected", f.XML)
+	}
+}
+
+// Validate checks Delay is not negative and StatusCode is a valid HTTP status code if set.
+func (f *BadExpect) Validate() error {
+	if err := validateDuration("delay", f.Delay); err != nil {
+		return err
+	}
+	if f.StatusCode != 0 {
+		return validateStatus(f.StatusCode)
+	}
+	return nil
+}
+
+// parseExpectError parses the name of an ExpectError. An empty name is ExpectWithhold.
+func parseExpectError(s string) (ExpectError, error) {
+	if s == "" {
+		return ExpectWithhold, nil
+	}
+	e, ok := expectErrors[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid error %s", strconv.Quote(s))
+	}
+	return e, nil
+}
@@ -1,14 +1,24 @@
 package fault
 
 import (
-	"math/rand"
+	"fmt"
+	"math"
 	"net/http"
-	"sync"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Fault is a fault injected into a request.
+// Handle serves the request in place of next: it may delay, fail or abort the request without calling next,
+// or call next with a modified request or ResponseWriter. It is called only for the requests the Handler has decided
+// to inject, so a Fault itself never deals with the ratio, matchers and the other conditions.
+//
+// Custom faults implement Handle, or are written as a FaultFunc. A Fault may also implement Name() string to be
+// reported by the name, see FaultName, and Validator to be checked on setup.
+// A Fault is shared by the concurrent requests, so Handle must be safe for concurrent use.
 type Fault interface {
-	Handler(next http.Handler) http.Handler
+	Handle(w http.ResponseWriter, r *http.Request, next http.Handler)
 }
 
 var _ []Fault = []Fault{
@@ -17,35 +27,431 @@ var _ []Fault = []Fault{
 	&DelayWithError{},
 	&Abort{},
 	&DelayWithAbort{},
+	&RateLimit{},
+	&Concurrency{},
+	&CPU{},
+	&SSE{},
+	&GRPCError{},
+	&ConnectError{},
+	&GraphQLError{},
+	&Hang{},
+	&PartialResponse{},
+	&MalformedChunked{},
+	&ContentLengthMismatch{},
+	&BadCompression{},
+	&LargeHeader{},
+	&LargeBody{},
+	&EmptySuccess{},
+	&AuthFailure{},
+	&Mock{},
+	&OpenAPIError{},
+	&StatusDistribution{},
+	&FailFirst{},
+	&Script{},
+	&CircuitBreaker{},
+	&LoadShed{},
+	&ClockSkew{},
+	&SessionExpiry{},
+	&BadConditional{},
+	&BadCORS{},
+	&BadExpect{},
+	&BadTrailer{},
+	&TransportError{},
+	&SlowUpload{},
+	&FuzzedBody{},
+	&BaselineDelay{},
+	&LatencyAmplification{},
 }
 
+// Handler injects the fault into requests at the given ratio.
+// The exported fields can be set after New, but must not be modified once the Handler serves requests.
+// The ratio can be changed by SetRatio at any time.
 type Handler struct {
-	f           Fault
+	// ratioBits is the ratio set by SetRatio in the bits of float64, which is valid when ratioSet is 1.
+	// It is the first field so that it is 64-bit aligned for the atomic operations on 32-bit platforms.
+	ratioBits uint64
+	ratioSet  uint32
+	f         Fault
+	disabled  uint32
+	created   time.Time
+	// started holds the time.Time set by SetStart, which overrides Start.
+	started atomic.Value
+	stats   *stats
+	// Name optionally identifies the Handler, such as in a Config.
+	Name string
+	// RandomRatio is the ratio of requests the fault is injected into, in [0, 1].
+	// It is the initial ratio; use SetRatio to change the ratio while the Handler serves requests.
 	RandomRatio float64
-
-	r  *rand.Rand
-	mu sync.Mutex
+	// MethodRatios overrides the ratio per HTTP method, since the write paths are usually what to stress;
+	// e.g. {"GET": 0, "POST": 0.05, "DELETE": 0.2}. The methods not in it use the ratio of the Handler.
+	// SetRatio does not change them, while the Ramp applies to them as well.
+	MethodRatios map[string]float64
+	// Matchers narrows down the target requests. A request is a target only if every Matcher matches.
+	// If empty, every request is a target.
+	Matchers []Matcher
+	// Sampler decides whether a target request is injected. If nil, RandomSampler is used.
+	Sampler Sampler
+	// Hooks are called with the Outcome of every request.
+	Hooks []Hook
+	// MaxOverhead guards the overhead the Handler adds to a request. If nil, there is no guard.
+	MaxOverhead *MaxOverhead
+	// SteadyState disables the Handler when the service leaves the steady state while injecting.
+	// If nil, there is no guard.
+	SteadyState *SteadyState
+	// Override allows requests to force a fault by a header. If nil, overrides are not honored.
+	Override *Override
+	// Envoy honors the fault headers of Envoy's fault filter. If nil, they are ignored.
+	Envoy *Envoy
+	// Bypass lets requests opt out of the injection by a secret header. If nil, no request bypasses.
+	Bypass *Bypass
+	// DryRun makes the Handler decide as usual, but never inject. The requests which would have been injected
+	// are reported to the Hooks with the DryRun decision. See also SetDryRun.
+	DryRun bool
+	// MarkerHeader is the name of the header added to the responses written by the fault itself, such as Error,
+	// so that synthetic failures can be distinguished from real ones; e.g. "X-Fault-Injected: error;ratio=0.1".
+	// Responses written by the next handler are not marked. If empty, no header is added.
+	MarkerHeader string
+	// RequestIDHeader is the name of the header carrying the ID of the request, such as "X-Request-Id".
+	// The ID is set to the Outcome, so that the Hooks, the Recorder and the Webhook report it,
+	// and added to the marker header, so that a synthetic failure in a bug report can be told apart.
+	RequestIDHeader string
+	// Budget caps the number of injections. If nil, there is no cap.
+	// Requests forced by Override or Envoy headers are not counted.
+	Budget *Budget
+	// Start is when the Handler starts injecting. If zero, it starts as soon as it is created.
+	Start time.Time
+	// TTL is how long the Handler keeps injecting after Start, or after it is created if Start is zero.
+	// After that, the Handler expires and never injects again, so that a forgotten experiment does not last forever.
+	// Zero means no expiry.
+	TTL time.Duration
+	// Schedule limits when the Handler injects. If nil, it is always active.
+	Schedule Schedule
+	// Ramp changes the ratio gradually since the Handler starts. If nil, RandomRatio is used as is.
+	Ramp *Ramp
+	// ExcludePaths are the paths never injected, so that the faults never fail the health checks and the probes
+	// of Kubernetes, which would get the pod killed in the middle of an experiment. A path ending with "/"
+	// excludes the paths under it as well. If nil, DefaultExcludePaths is used; set an empty slice to exclude nothing.
+	ExcludePaths []string
 }
 
+// DefaultExcludePaths are the paths excluded from the injection by default, which are conventionally used for
+// the probes of Kubernetes.
+var DefaultExcludePaths = []string{"/healthz", "/readyz", "/livez"}
+
+// New returns a Handler injecting f into requests at the ratio.
+// Invalid arguments are not reported here; use Validate to check them.
 func New(f Fault, randomRatio float64) *Handler {
 	return &Handler{
 		f:           f,
+		created:     CurrentClock().Now(),
+		stats:       &stats{},
 		RandomRatio: randomRatio,
-		r:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		Sampler:     NewRandomSampler(),
 	}
 }
 
 func (h *Handler) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if h.r.Float64() < h.RandomRatio {
+		o := h.Evaluate(r)
+		if o.Decision != Inject {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		h.f.Handler(next).ServeHTTP(w, r)
+		h.inject(o, w, r, next)
 	})
 }
 
+// Evaluate decides on the request, then records and reports the Outcome, as Handler does before injecting.
+// It is for the adapters of frameworks not based on net/http, which apply o.Fault on their own
+// when o.Decision is Inject. Adapters supporting only some faults should use EvaluateSupported instead.
+func (h *Handler) Evaluate(r *http.Request) Outcome {
+	return h.EvaluateSupported(r, nil)
+}
+
+// EvaluateSupported is Evaluate for the adapters which can apply only some faults. If the fault to be injected,
+// such as the one of the Handler or the one requested by Override, is not supported, the request passes
+// with the reason "unsupported" before the Sampler and the Budget are consulted, so that the stats and
+// the Hooks do not count the faults which are not applied. Use Steps to support the faults applying others.
+// If supported is nil, every fault is supported.
+func (h *Handler) EvaluateSupported(r *http.Request, supported func(f Fault) bool) Outcome {
+	// The overhead is measured in the real time, as it is of the real CPU.
+	start := time.Now()
+	o := h.decide(r, supported)
+	if o.Decision == Inject && h.dryRun() {
+		o.Decision = DryRun
+	}
+	h.stats.record(o)
+	for _, hook := range h.Hooks {
+		hook(o)
+	}
+	if h.MaxOverhead != nil {
+		h.MaxOverhead.check(h, time.Since(start))
+	}
+	if h.SteadyState != nil && o.Decision == Inject {
+		h.SteadyState.poll(h, o.Time)
+	}
+
+	return o
+}
+
+// inject applies the fault of the Outcome to the request.
+func (h *Handler) inject(o Outcome, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if h.MarkerHeader != "" {
+		mw := &markerWriter{ResponseWriter: w, name: h.MarkerHeader, value: markerValue(o, h.ratio(r, o.Time))}
+		proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mw.proxied = true
+			next.ServeHTTP(w, r)
+		})
+		o.Fault.Handle(mw, r, proxy)
+		return
+	}
+
+	o.Fault.Handle(w, r, next)
+}
+
+// decide runs the matchers and the sampler against the request.
+func (h *Handler) decide(r *http.Request, supported func(f Fault) bool) Outcome {
+	o := Outcome{Fault: h.f, Decision: Pass, Request: r, Time: CurrentClock().Now()}
+	if h.RequestIDHeader != "" {
+		o.RequestID = r.Header.Get(h.RequestIDHeader)
+	}
+	if AllDisabled() {
+		o.Reason = "kill switch"
+		return o
+	}
+
+	if h.Disabled() {
+		o.Reason = "disabled"
+		return o
+	}
+
+	if reason := h.timeBox(o.Time); reason != "" {
+		o.Reason = reason
+		return o
+	}
+
+	if h.excluded(r) {
+		o.Reason = "excluded"
+		return o
+	}
+
+	if h.Bypass != nil && h.Bypass.bypassed(r) {
+		o.Reason = "bypassed"
+		return o
+	}
+
+	if h.Override != nil {
+		f, err := h.Override.fault(r)
+		if err != nil {
+			f = &Error{StatusCode: http.StatusBadRequest, StatusText: "invalid fault override"}
+			o.Err = err
+		}
+		if f != nil {
+			o.Fault = f
+			if supported != nil && !supported(f) {
+				o.Reason = "unsupported"
+				return o
+			}
+			o.Decision = Inject
+			o.Reason = "override"
+			return o
+		}
+	}
+
+	if h.Envoy != nil {
+		if f := h.Envoy.fault(r); f != nil {
+			o.Fault = f
+			if supported != nil && !supported(f) {
+				o.Reason = "unsupported"
+				return o
+			}
+			o.Decision = Inject
+			o.Reason = "envoy"
+			return o
+		}
+	}
+
+	if supported != nil && !supported(h.f) {
+		o.Reason = "unsupported"
+		return o
+	}
+
+	for _, m := range h.Matchers {
+		if !m.Match(r) {
+			o.Reason = "unmatched"
+			return o
+		}
+	}
+
+	s := h.Sampler
+	if s == nil {
+		s = defaultSampler
+	}
+	if !s.Sample(r, h.ratio(r, o.Time)) {
+		o.Reason = "unsampled"
+		return o
+	}
+
+	if h.Budget != nil && !h.Budget.take(o.Time) {
+		o.Reason = "budget exhausted"
+		return o
+	}
+
+	o.Decision = Inject
+	o.Reason = "sampled"
+	return o
+}
+
+var defaultSampler = NewRandomSampler()
+
+// ratio returns the ratio in effect for the request at now. If r is nil, MethodRatios is not applied.
+func (h *Handler) ratio(r *http.Request, now time.Time) float64 {
+	ratio := h.Ratio()
+	if r != nil {
+		if mr, ok := h.MethodRatios[r.Method]; ok {
+			ratio = mr
+		}
+	}
+	if h.Ramp == nil {
+		return ratio
+	}
+	return ratio * h.Ramp.factor(now.Sub(h.start()))
+}
+
+// SetRatio changes the ratio of requests the fault is injected into, in [0, 1].
+// It is safe to call it while the Handler serves requests, such as from an admin endpoint.
+func (h *Handler) SetRatio(ratio float64) {
+	atomic.StoreUint64(&h.ratioBits, math.Float64bits(ratio))
+	atomic.StoreUint32(&h.ratioSet, 1)
+}
+
+// Ratio returns the ratio set by SetRatio, or RandomRatio if SetRatio has never been called.
+// The Ramp is not applied; see Snapshot for the ratio in effect.
+func (h *Handler) Ratio() float64 {
+	if atomic.LoadUint32(&h.ratioSet) == 0 {
+		return h.RandomRatio
+	}
+	return math.Float64frombits(atomic.LoadUint64(&h.ratioBits))
+}
+
+// SetStart changes when the Handler starts injecting, overriding Start, so that the Ramp and the TTL count from t.
+// It is safe to call it while the Handler serves requests, unlike setting Start.
+func (h *Handler) SetStart(t time.Time) {
+	h.started.Store(t)
+}
+
+// start returns when the Handler starts.
+func (h *Handler) start() time.Time {
+	if t, ok := h.started.Load().(time.Time); ok {
+		return t
+	}
+	if h.Start.IsZero() {
+		return h.created
+	}
+	return h.Start
+}
+
+// timeBox returns "not started", "expired" or "off schedule" if the Handler is not active at now.
+func (h *Handler) timeBox(now time.Time) string {
+	start := h.start()
+	if now.Before(start) {
+		return "not started"
+	}
+	if h.TTL > 0 && !now.Before(start.Add(h.TTL)) {
+		return "expired"
+	}
+	if h.Schedule != nil && !h.Schedule.Active(now) {
+		return "off schedule"
+	}
+	return ""
+}
+
+// excluded reports whether the path of the request is excluded from the injection.
+func (h *Handler) excluded(r *http.Request) bool {
+	paths := h.ExcludePaths
+	if paths == nil {
+		paths = DefaultExcludePaths
+	}
+	for _, p := range paths {
+		if r.URL.Path == p || strings.HasSuffix(p, "/") && strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) dryRun() bool {
+	return h.DryRun || atomic.LoadUint32(&globalDryRun) == 1
+}
+
+var globalDryRun uint32
+
+// SetDryRun turns on or off the dry-run mode of every Handler in the process.
+// While it is on, every Handler behaves as if its DryRun is true.
+func SetDryRun(on bool) {
+	var v uint32
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&globalDryRun, v)
+}
+
+// Disable stops the Handler from injecting the fault. Every request is proxied to next.
+// It is safe to call it while the Handler serves requests.
+func (h *Handler) Disable() {
+	atomic.StoreUint32(&h.disabled, 1)
+}
+
+// Enable resumes the Handler disabled by Disable or by a guard such as MaxOverhead and SteadyState.
+func (h *Handler) Enable() {
+	atomic.StoreUint32(&h.disabled, 0)
+}
+
+// Disabled reports whether the Handler is disabled.
+func (h *Handler) Disabled() bool {
+	return atomic.LoadUint32(&h.disabled) == 1
+}
+
+// Validate checks the Handler and its fault are configured properly: the ratio is in [0, 1], the durations and
+// the counts are not negative, and the fault passes its own Validate if it is a Validator.
+// Config and Override validate the Handlers and the faults they build.
+func (h *Handler) Validate() error {
+	if err := h.validate(); err != nil {
+		return fmt.Errorf("fault: %w", err)
+	}
+	return nil
+}
+
+func (h *Handler) validate() error {
+	if h.f == nil {
+		return fmt.Errorf("fault is required")
+	}
+	if err := validateRatio("ratio", h.Ratio()); err != nil {
+		return err
+	}
+	for m, r := range h.MethodRatios {
+		if err := validateRatio("ratio of "+m, r); err != nil {
+			return err
+		}
+	}
+	if b := h.Budget; b != nil && (b.Rate < 0 || b.Total < 0 || b.Interval < 0) {
+		return fmt.Errorf("budget must not be negative")
+	}
+	if h.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	if r := h.Ramp; r != nil && (r.Up < 0 || r.Hold < 0 || r.Down < 0) {
+		return fmt.Errorf("ramp must not be negative")
+	}
+	if v, ok := h.Schedule.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateFault(h.f)
+}
+
 // Delay injects delay in the server call.
 // This can be used to simulate slow network.
 // You must initialize the struct before in use properly; If you use it with zero values,
@@ -61,20 +467,23 @@ type Delay struct {
 	Afterward bool
 }
 
-// Handler adds delay to the given handler.
-func (f *Delay) Handler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// If Afterward is true, proxy -> sleep
-		if f.Afterward {
-			next.ServeHTTP(w, r)
-			time.Sleep(f.Duration)
-			return
-		}
-
-		// else, sleep -> proxy
-		time.Sleep(f.Duration)
+// Handle adds delay to the given handler.
+func (f *Delay) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	// If Afterward is true, proxy -> sleep
+	if f.Afterward {
 		next.ServeHTTP(w, r)
-	})
+		sleep(f.Duration)
+		return
+	}
+
+	// else, sleep -> proxy
+	sleep(f.Duration)
+	next.ServeHTTP(w, r)
+}
+
+// Validate checks Duration is not negative.
+func (f *Delay) Validate() error {
+	return validateDuration("duration", f.Duration)
 }
 
 // Error injects arbitrary status code in the server call.
@@ -88,20 +497,21 @@ type Error struct {
 	// While this struct is named Error, but technically setting 2xx code is OK and will work well.
 	StatusCode int
 	// StatusText is used as HTTP response body. Optional but if empty, a placeholder message is used.
+	// The body is formatted by the Accept header of the request: in a JSON envelope to the clients accepting JSON,
+	// and as it is in plain text otherwise. See ErrorBody.
 	StatusText string
+	// XML formats the body in XML to the clients preferring XML. Optional.
+	XML bool
 }
 
-// Handler injects error to the given handler.
-func (f *Error) Handler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		statusText := f.StatusText
-		if statusText == "" {
-			statusText = "fault: pseudo status text is injected"
-		}
+// Handle injects error to the given handler.
+func (f *Error) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	writeError(w, r, f.StatusCode, f.StatusText, f.XML)
+}
 
-		w.WriteHeader(f.StatusCode)
-		w.Write([]byte(statusText))
-	})
+// Validate checks StatusCode is a valid HTTP status code.
+func (f *Error) Validate() error {
+	return validateStatus(f.StatusCode)
 }
 
 // DelayWithError combines Delay and Error into one.
@@ -115,35 +525,40 @@ type DelayWithError struct {
 	StatusCode int
 	// StatusText is the injected status text. The same as the one in Error.
 	StatusText string
+	// XML formats the body in XML. The same as the one in Error.
+	XML bool
 }
 
-// Handler injects delay and error into the given handler
-func (f *DelayWithError) Handler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		statusText := f.StatusText
-		if statusText == "" {
-			statusText = "fault: pseudo status text is injected"
-		}
+// Handle injects delay and error into the given handler
+func (f *DelayWithError) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	sleep(f.Duration)
+	writeError(w, r, f.StatusCode, f.StatusText, f.XML)
+}
 
-		time.Sleep(f.Duration)
-		w.WriteHeader(f.StatusCode)
-		w.Write([]byte(statusText))
-	})
+// Validate checks Duration is not negative and StatusCode is a valid HTTP status code.
+func (f *DelayWithError) Validate() error {
+	if err := validateDuration("duration", f.Duration); err != nil {
+		return err
+	}
+	return validateStatus(f.StatusCode)
 }
 
 // Abort aborts the request.
 // Internally it panics, and if it panics in Go, the HTTP request is interrupted and
 // an empty response is returned.
 // While it panics, stacktrace logging aren't shown in the server log.
-type Abort struct{}
+type Abort struct {
+	// Value is the value it panics with. If nil, http.ErrAbortHandler is used.
+	// Other values, such as an error or a RuntimeError, are recovered and logged with the stacktrace by net/http,
+	// so they can be used to verify the recovery middleware, the alerting and the logging of panics.
+	Value interface{}
+}
 
-// Handler aborts the request
-func (f *Abort) Handler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// If it panics with ErrAbortHandler in http handler, the server stacktrace logging will be suppressed.
-		// https://pkg.go.dev/net/http#Handler
-		panic(http.ErrAbortHandler)
-	})
+// Handle aborts the request
+func (f *Abort) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	// If it panics with ErrAbortHandler in http handler, the server stacktrace logging will be suppressed.
+	// https://pkg.go.dev/net/http#Handler
+	panic(panicValue(f.Value))
 }
 
 // DelayWithAbort aborts the request in the same way as Abort,
@@ -152,13 +567,36 @@ func (f *Abort) Handler(next http.Handler) http.Handler {
 type DelayWithAbort struct {
 	// Duration defines how long the delay should be injected.
 	Duration time.Duration
+	// Value is the value it panics with. The same as the one in Abort.
+	Value interface{}
 }
 
-// Handler adds delay and abort in the given handler
-func (f *DelayWithAbort) Handler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time.Sleep(f.Duration)
-		// https://pkg.go.dev/net/http#Handler
-		panic(http.ErrAbortHandler)
-	})
+// Handle adds delay and abort in the given handler
+func (f *DelayWithAbort) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	sleep(f.Duration)
+	// https://pkg.go.dev/net/http#Handler
+	panic(panicValue(f.Value))
+}
+
+// Validate checks Duration is not negative.
+func (f *DelayWithAbort) Validate() error {
+	return validateDuration("duration", f.Duration)
+}
+
+func panicValue(v interface{}) interface{} {
+	if v == nil {
+		return http.ErrAbortHandler
+	}
+	return v
 }
+
+// RuntimeError is a runtime.Error to panic with by Abort, which looks like the panics by the runtime,
+// such as RuntimeError("invalid memory address or nil pointer dereference").
+type RuntimeError string
+
+func (e RuntimeError) Error() string {
+	return "runtime error: " + string(e)
+}
+
+// RuntimeError implements runtime.Error.
+func (e RuntimeError) RuntimeError() {}
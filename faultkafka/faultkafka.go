@@ -0,0 +1,151 @@
+// Package faultkafka injects faults into producing and consuming messages of Kafka: delayed delivery, dropped
+// and duplicated messages, and produce errors, so that consumer idempotency and producer retries can be tested.
+// It does not depend on a Kafka client; the send and handle functions of the application are wrapped instead.
+// For example, with github.com/twmb/franz-go:
+//
+//	produce := faults.WrapProduce(func(ctx context.Context, m faultkafka.Message) error {
+//		return client.ProduceSync(ctx, m.Raw.(*kgo.Record)).FirstErr()
+//	})
+//	produce(ctx, faultkafka.Message{Topic: r.Topic, Key: r.Key, Value: r.Value, Raw: r})
+//
+// The faults are not injected while the kill switch of package fault is on.
+package faultkafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/hidetatz/fault"
+)
+
+// Message is a message being produced or consumed.
+type Message struct {
+	Topic string
+	Key   []byte
+	Value []byte
+	// Raw is the message of the client, which the wrapped functions use.
+	Raw interface{}
+}
+
+// Matcher decides whether a message is a target of the Fault.
+type Matcher interface {
+	Match(m Message) bool
+}
+
+// MatcherFunc is an adapter to allow the use of ordinary functions as Matcher.
+type MatcherFunc func(m Message) bool
+
+// Match calls f(m).
+func (f MatcherFunc) Match(m Message) bool {
+	return f(m)
+}
+
+// Fault delays, drops, duplicates or fails the messages at the given ratio.
+// When producing, a dropped message is reported as sent without being sent, and a duplicated one is sent again.
+// When consuming, a dropped message is not given to the handler, as if it is lost, and a duplicated one is
+// given again, as if it is redelivered.
+type Fault struct {
+	// Ratio is the ratio of target messages the fault is injected into, in [0, 1].
+	Ratio float64
+	// Topics narrows down the target messages by their topic. If empty, every topic is a target.
+	Topics []string
+	// Matchers narrows down the target messages. A message is a target only if every Matcher matches.
+	Matchers []Matcher
+	// Sampler decides whether a target message is injected. If nil, fault.RandomSampler is used.
+	// It is called with a nil request, so Samplers looking into the request cannot be used.
+	Sampler fault.Sampler
+	// Delay delays the message. It is cut short if the context is done.
+	Delay time.Duration
+	// Drop drops the message.
+	Drop bool
+	// Duplicate is the number of extra times the message is delivered.
+	Duplicate int
+	// Err is returned instead of producing or handling the message.
+	Err error
+}
+
+var defaultSampler = fault.NewRandomSampler()
+
+func (f *Fault) target(m Message) bool {
+	if len(f.Topics) > 0 {
+		found := false
+		for _, t := range f.Topics {
+			if t == m.Topic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, mt := range f.Matchers {
+		if !mt.Match(m) {
+			return false
+		}
+	}
+
+	s := f.Sampler
+	if s == nil {
+		s = defaultSampler
+	}
+	return s.Sample(nil, f.Ratio)
+}
+
+// Faults applies several Faults to a message in order.
+type Faults []*Fault
+
+// ProduceFunc sends a message to Kafka.
+type ProduceFunc func(ctx context.Context, m Message) error
+
+// HandleFunc processes a message consumed from Kafka.
+type HandleFunc func(ctx context.Context, m Message) error
+
+// WrapProduce returns the ProduceFunc injecting the faults into the messages sent by next.
+func (fs Faults) WrapProduce(next ProduceFunc) ProduceFunc {
+	return func(ctx context.Context, m Message) error {
+		return fs.apply(ctx, m, next)
+	}
+}
+
+// WrapHandle returns the HandleFunc injecting the faults into the messages given to next.
+func (fs Faults) WrapHandle(next HandleFunc) HandleFunc {
+	return func(ctx context.Context, m Message) error {
+		return fs.apply(ctx, m, next)
+	}
+}
+
+// apply injects the faults into the message, then gives it to next as many times as it is delivered.
+func (fs Faults) apply(ctx context.Context, m Message, next func(ctx context.Context, m Message) error) error {
+	deliveries := 1
+	if !fault.AllDisabled() {
+		for _, f := range fs {
+			if !f.target(m) {
+				continue
+			}
+
+			if f.Delay > 0 {
+				if err := fault.CurrentClock().Sleep(ctx, f.Delay); err != nil {
+					return err
+				}
+			}
+
+			if f.Err != nil {
+				return f.Err
+			}
+			if f.Drop {
+				return nil
+			}
+			deliveries += f.Duplicate
+		}
+	}
+
+	for i := 0; i < deliveries; i++ {
+		if err := next(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package faultredis
+
+import "strconv"
+
+// Error is an error reply of Redis, such as "CLUSTERDOWN The cluster is down".
+// Like the error type of go-redis, it has the RedisError method to tell the replies from network errors.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// RedisError marks the error as a reply of the server.
+func (e Error) RedisError() {}
+
+const (
+	// ErrClusterDown is replied when the cluster is not able to serve.
+	ErrClusterDown = Error("CLUSTERDOWN The cluster is down")
+	// ErrLoading is replied while the server loads the dataset.
+	ErrLoading = Error("LOADING Redis is loading the dataset in memory")
+	// ErrReadOnly is replied to write commands sent to a replica.
+	ErrReadOnly = Error("READONLY You can't write against a read only replica.")
+	// ErrTryAgain is replied while the slot of a multi-key command is being migrated.
+	ErrTryAgain = Error("TRYAGAIN Multiple keys request during rehashing of slot")
+	// ErrOOM is replied to write commands when the memory limit is reached.
+	ErrOOM = Error("OOM command not allowed when used memory > 'maxmemory'.")
+)
+
+// Moved returns the MOVED redirection to addr for the slot, replied in a cluster when the slot is served by another node.
+func Moved(slot int, addr string) Error {
+	return Error("MOVED " + strconv.Itoa(slot) + " " + addr)
+}
+
+// Ask returns the ASK redirection to addr for the slot, replied in a cluster while the slot is being migrated.
+func Ask(slot int, addr string) Error {
+	return Error("ASK " + strconv.Itoa(slot) + " " + addr)
+}
+
+// ErrTimeout is the error of a command whose reply does not arrive in time.
+// It is a net.Error reporting Timeout, as the clients return when the read deadline is exceeded.
+var ErrTimeout error = &timeoutError{}
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string {
+	return "read tcp: i/o timeout"
+}
+
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
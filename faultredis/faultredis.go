@@ -0,0 +1,142 @@
+// Package faultredis injects faults into Redis commands: latency, timeouts and error replies such as MOVED and
+// CLUSTERDOWN. It does not depend on a Redis client; Faults.Inject is called from the client's extension point.
+// For example, as a hook of github.com/redis/go-redis:
+//
+//	type hook struct{ faults faultredis.Faults }
+//
+//	func (h hook) DialHook(next redis.DialHook) redis.DialHook { return next }
+//
+//	func (h hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+//		return func(ctx context.Context, cmd redis.Cmder) error {
+//			if err := h.faults.Inject(ctx, cmd.Name(), cmd.Args()[1:]...); err != nil {
+//				cmd.SetErr(err)
+//				return err
+//			}
+//			return next(ctx, cmd)
+//		}
+//	}
+//
+// The faults are not injected while the kill switch of package fault is on.
+package faultredis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hidetatz/fault"
+)
+
+// Cmd is a Redis command a Fault may be injected into.
+type Cmd struct {
+	// Name is the name of the command in lower case, such as "get".
+	Name string
+	// Args is the arguments following the name.
+	Args []interface{}
+}
+
+// Key returns the first argument as a string, which is the key for most commands.
+func (c Cmd) Key() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return fmt.Sprint(c.Args[0])
+}
+
+// Matcher decides whether a command is a target of the Fault.
+type Matcher interface {
+	Match(cmd Cmd) bool
+}
+
+// MatcherFunc is an adapter to allow the use of ordinary functions as Matcher.
+type MatcherFunc func(cmd Cmd) bool
+
+// Match calls f(cmd).
+func (f MatcherFunc) Match(cmd Cmd) bool {
+	return f(cmd)
+}
+
+// KeyPrefix returns a Matcher matching the commands whose key starts with the prefix.
+func KeyPrefix(prefix string) Matcher {
+	return MatcherFunc(func(cmd Cmd) bool {
+		return strings.HasPrefix(cmd.Key(), prefix)
+	})
+}
+
+// Fault delays the Redis commands and makes them fail at the given ratio.
+type Fault struct {
+	// Ratio is the ratio of target commands the fault is injected into, in [0, 1].
+	Ratio float64
+	// Commands narrows down the target commands by their name, ignoring case. If empty, every command is a target.
+	Commands []string
+	// Matchers narrows down the target commands. A command is a target only if every Matcher matches.
+	Matchers []Matcher
+	// Sampler decides whether a target command is injected. If nil, fault.RandomSampler is used.
+	// It is called with a nil request, so Samplers looking into the request cannot be used.
+	Sampler fault.Sampler
+	// Delay delays the command. It is cut short if the context of the command is done.
+	Delay time.Duration
+	// Err is returned from the command after Delay, such as ErrClusterDown or ErrTimeout.
+	// If nil, the command is performed as usual after Delay.
+	Err error
+}
+
+var defaultSampler = fault.NewRandomSampler()
+
+func (f *Fault) target(cmd Cmd) bool {
+	if len(f.Commands) > 0 {
+		found := false
+		for _, c := range f.Commands {
+			if strings.EqualFold(c, cmd.Name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, m := range f.Matchers {
+		if !m.Match(cmd) {
+			return false
+		}
+	}
+
+	s := f.Sampler
+	if s == nil {
+		s = defaultSampler
+	}
+	return s.Sample(nil, f.Ratio)
+}
+
+// Faults applies several Faults to a command in order.
+type Faults []*Fault
+
+// Inject applies the faults to the command, and returns the error the command fails with.
+// If nil is returned, the command should be performed as usual.
+func (fs Faults) Inject(ctx context.Context, name string, args ...interface{}) error {
+	if fault.AllDisabled() {
+		return nil
+	}
+
+	cmd := Cmd{Name: strings.ToLower(name), Args: args}
+	for _, f := range fs {
+		if !f.target(cmd) {
+			continue
+		}
+
+		if f.Delay > 0 {
+			if err := fault.CurrentClock().Sleep(ctx, f.Delay); err != nil {
+				return err
+			}
+		}
+
+		if f.Err != nil {
+			return f.Err
+		}
+	}
+
+	return nil
+}
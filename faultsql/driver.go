@@ -0,0 +1,343 @@
+package faultsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+// Driver wraps a driver.Driver to inject the faults into the connections it opens.
+// It is registered by sql.Register, such as:
+//
+//	sql.Register("faultpostgres", &faultsql.Driver{Driver: &pq.Driver{}, Faults: faults})
+type Driver struct {
+	// Driver is the underlying driver. Required.
+	Driver driver.Driver
+	// Faults are applied to every operation in order.
+	Faults []*Fault
+}
+
+var (
+	_ driver.Driver        = &Driver{}
+	_ driver.DriverContext = &Driver{}
+)
+
+// Open opens a connection by the underlying driver, unless a fault fails it.
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	if err := inject(context.Background(), d.Faults, Op{Kind: OpConnect}); err != nil {
+		return nil, err
+	}
+
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, faults: d.Faults}, nil
+}
+
+// OpenConnector returns a Connector of the name, which is used by sql.Open.
+func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Connector{Connector: c, Faults: d.Faults}, nil
+	}
+
+	return &Connector{Connector: dsnConnector{name: name, driver: d.Driver}, Faults: d.Faults}, nil
+}
+
+// Connector wraps a driver.Connector to inject the faults into the connections it makes.
+// It is used by sql.OpenDB.
+type Connector struct {
+	// Connector is the underlying connector. Required.
+	Connector driver.Connector
+	// Faults are applied to every operation in order.
+	Faults []*Fault
+}
+
+var _ driver.Connector = &Connector{}
+
+// Connect makes a connection by the underlying connector, unless a fault fails it.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := inject(ctx, c.Faults, Op{Kind: OpConnect}); err != nil {
+		return nil, err
+	}
+
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, faults: c.Faults}, nil
+}
+
+// Driver returns the underlying driver wrapped with the faults.
+func (c *Connector) Driver() driver.Driver {
+	return &Driver{Driver: c.Connector.Driver(), Faults: c.Faults}
+}
+
+// Close closes the underlying connector if it is an io.Closer.
+func (c *Connector) Close() error {
+	if cl, ok := c.Connector.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// dsnConnector is the Connector of the drivers not implementing driver.DriverContext.
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// conn is a driver.Conn injecting the faults.
+// The optional interfaces not implemented by the underlying connection are emulated as database/sql does.
+type conn struct {
+	driver.Conn
+	faults []*Fault
+}
+
+var (
+	_ driver.ConnPrepareContext = &conn{}
+	_ driver.ConnBeginTx        = &conn{}
+	_ driver.ExecerContext      = &conn{}
+	_ driver.QueryerContext     = &conn{}
+	_ driver.Pinger             = &conn{}
+	_ driver.SessionResetter    = &conn{}
+	_ driver.Validator          = &conn{}
+	_ driver.NamedValueChecker  = &conn{}
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := inject(ctx, c.faults, Op{Kind: OpPrepare, Query: query}); err != nil {
+		return nil, err
+	}
+
+	var s driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: s, query: query, faults: c.faults}, nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := inject(ctx, c.faults, Op{Kind: OpBegin}); err != nil {
+		return nil, err
+	}
+
+	var t driver.Tx
+	var err error
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		t, err = bt.BeginTx(ctx, opts)
+	} else {
+		if opts.Isolation != 0 {
+			return nil, errors.New("sql: driver does not support non-default isolation level")
+		}
+		if opts.ReadOnly {
+			return nil, errors.New("sql: driver does not support read-only transactions")
+		}
+		t, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: t, faults: c.faults}, nil
+}
+
+// ExecContext executes the query on the connection. If the underlying connection cannot, driver.ErrSkip is returned
+// so that database/sql prepares a statement instead, which is also injected.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	switch e := c.Conn.(type) {
+	case driver.ExecerContext:
+		if err := inject(ctx, c.faults, Op{Kind: OpExec, Query: query, Args: args}); err != nil {
+			return nil, err
+		}
+		return e.ExecContext(ctx, query, args)
+	case driver.Execer:
+		vs, err := values(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := inject(ctx, c.faults, Op{Kind: OpExec, Query: query, Args: args}); err != nil {
+			return nil, err
+		}
+		return e.Exec(query, vs)
+	}
+
+	return nil, driver.ErrSkip
+}
+
+// QueryContext runs the query on the connection. If the underlying connection cannot, driver.ErrSkip is returned
+// so that database/sql prepares a statement instead, which is also injected.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch q := c.Conn.(type) {
+	case driver.QueryerContext:
+		if err := inject(ctx, c.faults, Op{Kind: OpQuery, Query: query, Args: args}); err != nil {
+			return nil, err
+		}
+		return q.QueryContext(ctx, query, args)
+	case driver.Queryer:
+		vs, err := values(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := inject(ctx, c.faults, Op{Kind: OpQuery, Query: query, Args: args}); err != nil {
+			return nil, err
+		}
+		return q.Query(query, vs)
+	}
+
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// stmt is a driver.Stmt injecting the faults.
+type stmt struct {
+	driver.Stmt
+	query  string
+	faults []*Fault
+}
+
+var (
+	_ driver.StmtExecContext   = &stmt{}
+	_ driver.StmtQueryContext  = &stmt{}
+	_ driver.NamedValueChecker = &stmt{}
+)
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := inject(ctx, s.faults, Op{Kind: OpExec, Query: s.query, Args: args}); err != nil {
+		return nil, err
+	}
+
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	vs, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(vs)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := inject(ctx, s.faults, Op{Kind: OpQuery, Query: s.query, Args: args}); err != nil {
+		return nil, err
+	}
+
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	vs, err := values(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(vs)
+}
+
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tx is a driver.Tx injecting the faults.
+// When a fault fails Commit or Rollback, the transaction is rolled back so that it does not remain on the connection.
+type tx struct {
+	driver.Tx
+	faults []*Fault
+}
+
+func (t *tx) Commit() error {
+	if err := inject(context.Background(), t.faults, Op{Kind: OpCommit}); err != nil {
+		t.Tx.Rollback()
+		return err
+	}
+	return t.Tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	if err := inject(context.Background(), t.faults, Op{Kind: OpRollback}); err != nil {
+		t.Tx.Rollback()
+		return err
+	}
+	return t.Tx.Rollback()
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nvs
+}
+
+func values(args []driver.NamedValue) ([]driver.Value, error) {
+	vs := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		vs[i] = a.Value
+	}
+	return vs, nil
+}
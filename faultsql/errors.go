@@ -0,0 +1,87 @@
+package faultsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// MySQLError mimics the errors of MySQL as github.com/go-sql-driver/mysql reports them.
+// The driver's own type cannot be used without depending on it, so the application code matching the errors
+// by errors.As with *mysql.MySQLError does not catch this. Code matching the message, or the SQLSTATE
+// by the SQLState method, does.
+type MySQLError struct {
+	Number  uint16
+	State   string
+	Message string
+}
+
+func (e *MySQLError) Error() string {
+	if e.State != "" {
+		return fmt.Sprintf("Error %d (%s): %s", e.Number, e.State, e.Message)
+	}
+	return fmt.Sprintf("Error %d: %s", e.Number, e.Message)
+}
+
+// SQLState returns the SQLSTATE code of the error.
+func (e *MySQLError) SQLState() string {
+	return e.State
+}
+
+// PostgresError mimics the errors of PostgreSQL as github.com/jackc/pgx reports them.
+// As with MySQLError, errors.As with the driver's type does not catch this, but the SQLState method
+// which pgx's error also has does.
+type PostgresError struct {
+	Severity string
+	Code     string
+	Message  string
+}
+
+func (e *PostgresError) Error() string {
+	return fmt.Sprintf("%s: %s (SQLSTATE %s)", e.Severity, e.Message, e.Code)
+}
+
+// SQLState returns the SQLSTATE code of the error.
+func (e *PostgresError) SQLState() string {
+	return e.Code
+}
+
+var (
+	// ErrMySQLDeadlock is MySQL's error 1213, returned when a transaction is chosen as a deadlock victim.
+	ErrMySQLDeadlock = &MySQLError{Number: 1213, State: "40001", Message: "Deadlock found when trying to get lock; try restarting transaction"}
+	// ErrMySQLLockWaitTimeout is MySQL's error 1205, returned when a lock is not acquired in time.
+	ErrMySQLLockWaitTimeout = &MySQLError{Number: 1205, State: "HY000", Message: "Lock wait timeout exceeded; try restarting transaction"}
+	// ErrPostgresSerializationFailure is PostgreSQL's serialization_failure (40001).
+	ErrPostgresSerializationFailure = &PostgresError{Severity: "ERROR", Code: "40001", Message: "could not serialize access due to concurrent update"}
+	// ErrPostgresDeadlock is PostgreSQL's deadlock_detected (40P01).
+	ErrPostgresDeadlock = &PostgresError{Severity: "ERROR", Code: "40P01", Message: "deadlock detected"}
+)
+
+// statements is the kinds of the operations running statements in a transaction, which a deadlock or
+// a serialization failure happens at.
+var statements = []OpKind{OpExec, OpQuery, OpCommit}
+
+// MySQLDeadlock returns a Fault failing the statements with ErrMySQLDeadlock at the ratio.
+func MySQLDeadlock(ratio float64) *Fault {
+	return &Fault{Ratio: ratio, Ops: statements, Err: ErrMySQLDeadlock}
+}
+
+// PostgresSerializationFailure returns a Fault failing the statements with ErrPostgresSerializationFailure at the ratio.
+func PostgresSerializationFailure(ratio float64) *Fault {
+	return &Fault{Ratio: ratio, Ops: statements, Err: ErrPostgresSerializationFailure}
+}
+
+// BadConn returns a Fault failing the operations on connections with driver.ErrBadConn at the ratio,
+// as if the connection is broken. database/sql discards the connection and retries a few times on other ones,
+// so the application sees the error only when the retries also fail.
+func BadConn(ratio float64) *Fault {
+	return &Fault{Ratio: ratio, Ops: []OpKind{OpBegin, OpPrepare, OpExec, OpQuery}, Err: driver.ErrBadConn}
+}
+
+// PoolExhausted returns a Fault making new connections wait for wait, then fail with context.DeadlineExceeded
+// at the ratio, as a pool which has no connection to spare does. If the context of the operation is done earlier,
+// its error is returned instead.
+func PoolExhausted(ratio float64, wait time.Duration) *Fault {
+	return &Fault{Ratio: ratio, Ops: []OpKind{OpConnect}, Delay: wait, Err: context.DeadlineExceeded}
+}
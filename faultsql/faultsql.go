@@ -0,0 +1,140 @@
+// Package faultsql injects faults into database/sql by wrapping the driver: query latency, errors returned from
+// the operations, and connection failures.
+//
+//	db := sql.OpenDB(&faultsql.Connector{
+//		Connector: connector,
+//		Faults: []*faultsql.Fault{
+//			{Ratio: 0.1, Delay: 200 * time.Millisecond},
+//			{Ratio: 0.01, Ops: []faultsql.OpKind{faultsql.OpConnect}, Err: driver.ErrBadConn},
+//		},
+//	})
+//
+// The faults are not injected while the kill switch of package fault is on.
+package faultsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/hidetatz/fault"
+)
+
+// OpKind is the kind of a database operation.
+type OpKind string
+
+const (
+	OpConnect  OpKind = "connect"
+	OpBegin    OpKind = "begin"
+	OpPrepare  OpKind = "prepare"
+	OpExec     OpKind = "exec"
+	OpQuery    OpKind = "query"
+	OpCommit   OpKind = "commit"
+	OpRollback OpKind = "rollback"
+)
+
+// Op is a database operation a Fault may be injected into.
+type Op struct {
+	Kind OpKind
+	// Query is the SQL of OpPrepare, OpExec and OpQuery. Empty for the others.
+	Query string
+	// Args is the arguments of OpExec and OpQuery.
+	Args []driver.NamedValue
+}
+
+// Matcher decides whether an operation is a target of the Fault.
+type Matcher interface {
+	Match(op Op) bool
+}
+
+// MatcherFunc is an adapter to allow the use of ordinary functions as Matcher.
+type MatcherFunc func(op Op) bool
+
+// Match calls f(op).
+func (f MatcherFunc) Match(op Op) bool {
+	return f(op)
+}
+
+// QueryContains returns a Matcher matching the operations whose query contains s, ignoring case.
+func QueryContains(s string) Matcher {
+	s = strings.ToLower(s)
+	return MatcherFunc(func(op Op) bool {
+		return strings.Contains(strings.ToLower(op.Query), s)
+	})
+}
+
+// Fault delays the database operations and makes them fail at the given ratio.
+type Fault struct {
+	// Ratio is the ratio of target operations the fault is injected into, in [0, 1].
+	Ratio float64
+	// Ops narrows down the target operations by their kind. If empty, every kind is a target.
+	// Note that database/sql prepares a statement to run a query if the driver cannot run it directly,
+	// in which case both OpPrepare and OpExec (or OpQuery) of the query are performed.
+	Ops []OpKind
+	// Matchers narrows down the target operations. An operation is a target only if every Matcher matches.
+	Matchers []Matcher
+	// Sampler decides whether a target operation is injected. If nil, fault.RandomSampler is used.
+	// It is called with a nil request, so Samplers looking into the request, such as fault.StickySampler,
+	// cannot be used.
+	Sampler fault.Sampler
+	// Delay delays the operation. It is cut short if the context of the operation is done.
+	Delay time.Duration
+	// Err is returned from the operation after Delay. If nil, the operation is performed as usual after Delay.
+	// driver.ErrBadConn makes database/sql discard the connection and retry on another one.
+	Err error
+}
+
+var defaultSampler = fault.NewRandomSampler()
+
+func (f *Fault) target(op Op) bool {
+	if len(f.Ops) > 0 {
+		found := false
+		for _, k := range f.Ops {
+			if k == op.Kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, m := range f.Matchers {
+		if !m.Match(op) {
+			return false
+		}
+	}
+
+	s := f.Sampler
+	if s == nil {
+		s = defaultSampler
+	}
+	return s.Sample(nil, f.Ratio)
+}
+
+// inject applies the faults to the operation in order, and returns the error the operation fails with.
+func inject(ctx context.Context, faults []*Fault, op Op) error {
+	if fault.AllDisabled() {
+		return nil
+	}
+
+	for _, f := range faults {
+		if !f.target(op) {
+			continue
+		}
+
+		if f.Delay > 0 {
+			if err := fault.CurrentClock().Sleep(ctx, f.Delay); err != nil {
+				return err
+			}
+		}
+
+		if f.Err != nil {
+			return f.Err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package faultsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hidetatz/fault"
+)
+
+func TestInjectDelay(t *testing.T) {
+	clk := fault.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	fault.SetClock(clk)
+	defer fault.SetClock(nil)
+
+	faults := []*Fault{{Ratio: 1, Delay: time.Second, Err: driver.ErrBadConn}}
+	op := Op{Kind: OpQuery, Query: "SELECT 1"}
+
+	done := make(chan error, 1)
+	go func() { done <- inject(context.Background(), faults, op) }()
+	for clk.Sleepers() == 0 {
+		runtime.Gosched()
+	}
+	clk.Add(time.Second)
+	if err := <-done; err != driver.ErrBadConn {
+		t.Errorf("inject() = %v, want %v", err, driver.ErrBadConn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- inject(ctx, faults, op) }()
+	for clk.Sleepers() == 0 {
+		runtime.Gosched()
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("inject() = %v, want %v", err, context.Canceled)
+	}
+}
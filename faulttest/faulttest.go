@@ -0,0 +1,93 @@
+// Package faulttest provides the helpers of the tests driven by the faults of package fault, such as the table-driven
+// resilience tests of the clients against a server injecting the faults:
+//
+//	srv := faulttest.NewServer(api, &fault.Error{StatusCode: 503})
+//	defer srv.Close()
+//	srv.Chain[0].Sampler = faulttest.Decisions(true, true, false)
+//
+//	resp, err := client.Get(srv.URL) // retries twice, then succeeds
+//	srv.AssertInjected(t, 2)
+package faulttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hidetatz/fault"
+)
+
+// Server is an httptest.Server injecting the faults into the requests.
+type Server struct {
+	*httptest.Server
+	// Chain is the Handlers injecting the faults, one per fault given to NewServer, in order.
+	// They inject into every request by default; configure them, such as their Samplers and Matchers,
+	// before sending the requests.
+	Chain fault.Chain
+}
+
+// NewServer starts a Server serving the requests by next, with every fault injected into every request in order.
+// The caller should call Close when finished.
+func NewServer(next http.Handler, faults ...fault.Fault) *Server {
+	s := &Server{Chain: newChain(faults)}
+	s.Server = httptest.NewServer(s.Chain.Handler(next))
+	return s
+}
+
+// NewTLSServer is NewServer with TLS.
+func NewTLSServer(next http.Handler, faults ...fault.Fault) *Server {
+	s := &Server{Chain: newChain(faults)}
+	s.Server = httptest.NewTLSServer(s.Chain.Handler(next))
+	return s
+}
+
+func newChain(faults []fault.Fault) fault.Chain {
+	c := make(fault.Chain, len(faults))
+	for i, f := range faults {
+		c[i] = fault.New(f, 1)
+		// The paths excluded by default, such as /healthz, are often the targets of the tests.
+		c[i].ExcludePaths = []string{}
+	}
+	return c
+}
+
+// Injected returns the number of the requests the faults were injected into, summed over the Handlers.
+func (s *Server) Injected() int {
+	n := 0
+	for _, h := range s.Chain {
+		n += h.Stats().Injected
+	}
+	return n
+}
+
+// AssertInjected fails the test unless the faults were injected into n requests, summed over the Handlers.
+func (s *Server) AssertInjected(t testing.TB, n int) {
+	t.Helper()
+	if got := s.Injected(); got != n {
+		t.Errorf("faulttest: faults injected into %d requests, want %d", got, n)
+	}
+}
+
+// AssertNotInjected fails the test if the faults were injected into any request.
+func (s *Server) AssertNotInjected(t testing.TB) {
+	t.Helper()
+	s.AssertInjected(t, 0)
+}
+
+// Decisions returns a fault.Sampler deciding on the requests by the decisions in order, ignoring the ratio:
+// the n-th request it sees is selected if the n-th decision is true. The requests after the decisions run out
+// are not selected.
+func Decisions(decisions ...bool) fault.Sampler {
+	var mu sync.Mutex
+	n := 0
+	return fault.SamplerFunc(func(_ *http.Request, _ float64) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if n >= len(decisions) {
+			return false
+		}
+		n++
+		return decisions[n-1]
+	})
+}
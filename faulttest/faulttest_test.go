@@ -0,0 +1,92 @@
+package faulttest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hidetatz/fault"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func get(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	srv := NewServer(ok, &fault.Error{StatusCode: http.StatusServiceUnavailable})
+	defer srv.Close()
+	srv.Chain[0].Sampler = Decisions(true, true, false)
+
+	var got []int
+	for i := 0; i < 4; i++ {
+		got = append(got, get(t, srv.Client(), srv.URL+"/healthz"))
+	}
+	if want := []int{503, 503, 200, 200}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if n := srv.Injected(); n != 2 {
+		t.Errorf("Injected() = %d, want 2", n)
+	}
+	srv.AssertInjected(t, 2)
+}
+
+func TestTLSServer(t *testing.T) {
+	srv := NewTLSServer(ok, &fault.Delay{}, &fault.Error{StatusCode: http.StatusTooManyRequests})
+	defer srv.Close()
+
+	if code := get(t, srv.Client(), srv.URL); code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	// Both the Handlers injected into the request.
+	srv.AssertInjected(t, 2)
+}
+
+func TestAssertNotInjected(t *testing.T) {
+	srv := NewServer(ok, &fault.Error{StatusCode: http.StatusServiceUnavailable})
+	defer srv.Close()
+	srv.Chain[0].Sampler = Decisions()
+
+	if code := get(t, srv.Client(), srv.URL); code != http.StatusOK {
+		t.Errorf("status = %d, want %d", code, http.StatusOK)
+	}
+	srv.AssertNotInjected(t)
+
+	srv.Chain[0].Sampler = Decisions(true)
+	get(t, srv.Client(), srv.URL)
+	rec := &recorder{TB: t}
+	srv.AssertNotInjected(rec)
+	if !rec.failed {
+		t.Error("AssertNotInjected did not fail after an injection")
+	}
+}
+
+// recorder is a testing.TB recording the failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
+
+func TestDecisions(t *testing.T) {
+	s := Decisions(true, false, true)
+	var got []bool
+	for i := 0; i < 5; i++ {
+		got = append(got, s.Sample(nil, 0))
+	}
+	if want := []bool{true, false, true, false, false}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("decisions = %v, want %v", got, want)
+	}
+}
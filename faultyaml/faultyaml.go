@@ -0,0 +1,85 @@
+// Package faultyaml reads the configs of package fault written in YAML. Importing it registers the format,
+// so fault.ParseConfig, fault.LoadConfig and the Reloaders accept YAML as well as JSON:
+//
+//	import _ "github.com/hidetatz/fault/faultyaml"
+//
+//	c, err := fault.LoadConfig("faults.yaml")
+//
+// The YAML document has the same fields as the JSON one:
+//
+//	version: 1
+//	faults:
+//	  - name: slow-api
+//	    effect: delay
+//	    params: {duration: 2s}
+//	    ratio: 0.1
+package faultyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hidetatz/fault"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	fault.RegisterConfigFormat("yaml", ToJSON)
+}
+
+// ToJSON converts the YAML document to JSON.
+func ToJSON(b []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	v, err := jsonValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// jsonValue converts the value decoded from YAML to the one encoded as JSON.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			ev, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = ev
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v must be a string", k)
+			}
+			ev, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = ev
+		}
+		return m, nil
+	case []interface{}:
+		for i, e := range v {
+			ev, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = ev
+		}
+		return v, nil
+	case time.Time:
+		// An unquoted timestamp, such as the start of a fault.
+		return v.Format(time.RFC3339Nano), nil
+	default:
+		return v, nil
+	}
+}
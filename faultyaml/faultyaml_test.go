@@ -0,0 +1,42 @@
+package faultyaml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hidetatz/fault"
+)
+
+func TestParseConfig(t *testing.T) {
+	c, err := fault.ParseConfig([]byte(`
+version: 1
+faults:
+  - name: slow-api
+    effect: delay
+    params: {duration: 2s}
+    ratio: 0.1
+    start: 2024-01-01T00:00:00Z
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := c.Faults[0]
+	if fc.Name != "slow-api" || fc.Ratio != 0.1 || fc.Params["duration"] != "2s" {
+		t.Errorf("fault = %+v", fc)
+	}
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); fc.Start == nil || !fc.Start.Equal(want) {
+		t.Errorf("start = %v, want %v", fc.Start, want)
+	}
+}
+
+func TestParseConfigJSON(t *testing.T) {
+	if _, err := fault.ParseConfig([]byte(`{"version": 1, "faults": []}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	if _, err := fault.ParseConfig([]byte("faults: [")); err == nil {
+		t.Error("ParseConfig succeeded, want error")
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/hidetatz/fault/faultyaml
+
+go 1.20
+
+require (
+	github.com/hidetatz/fault v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/hidetatz/fault => ../
@@ -0,0 +1,110 @@
+// Package fiberfault adapts the faults of package fault to Fiber, which is not based on net/http.
+//
+//	app.Use(fiberfault.Middleware(fault.New(&fault.Error{StatusCode: 503}, 0.1)))
+//
+// The Handlers decide on the requests as usual; the request is converted to *http.Request for the Matchers,
+// the Samplers and the Hooks. The faults are applied natively on Fiber, and only Delay, Error, DelayWithError,
+// Abort and DelayWithAbort are supported, including the ones combined by fault.All, fault.Named and Override.
+// The requests whose fault is not supported pass with the reason "unsupported" and are not counted as injected.
+package fiberfault
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/hidetatz/fault"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// Middleware returns a fiber.Handler applying the faults of the Handlers to the requests, in order.
+func Middleware(handlers ...*fault.Handler) fiber.Handler {
+	chain := fault.Chain(handlers)
+	return middleware(func() fault.Chain { return chain })
+}
+
+// Reloader returns a fiber.Handler applying the faults in effect of the Reloader to the requests.
+func Reloader(r *fault.Reloader) fiber.Handler {
+	return middleware(r.Chain)
+}
+
+func middleware(chain func() fault.Chain) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hs := chain()
+		if len(hs) == 0 {
+			return c.Next()
+		}
+
+		var r http.Request
+		if err := fasthttpadaptor.ConvertRequest(c.Context(), &r, true); err != nil {
+			return err
+		}
+		req := r.WithContext(c.UserContext())
+
+		var after []time.Duration
+		for _, h := range hs {
+			o := h.EvaluateSupported(req, supported)
+			if o.Decision != fault.Inject {
+				continue
+			}
+
+			for _, step := range fault.Steps(o.Fault) {
+				switch f := step.(type) {
+				case *fault.Delay:
+					if f.Afterward {
+						after = append(after, f.Duration)
+						continue
+					}
+					fault.CurrentClock().Sleep(req.Context(), f.Duration)
+				case *fault.Error:
+					return sendError(c, req, f.StatusCode, f.StatusText, f.XML)
+				case *fault.DelayWithError:
+					fault.CurrentClock().Sleep(req.Context(), f.Duration)
+					return sendError(c, req, f.StatusCode, f.StatusText, f.XML)
+				case *fault.Abort:
+					return abort(c, f.Value)
+				case *fault.DelayWithAbort:
+					fault.CurrentClock().Sleep(req.Context(), f.Duration)
+					return abort(c, f.Value)
+				}
+			}
+		}
+
+		err := c.Next()
+		for _, d := range after {
+			fault.CurrentClock().Sleep(req.Context(), d)
+		}
+		return err
+	}
+}
+
+// supported reports whether every step of f can be applied on Fiber.
+func supported(f fault.Fault) bool {
+	for _, step := range fault.Steps(f) {
+		switch step.(type) {
+		case *fault.Delay, *fault.Error, *fault.DelayWithError, *fault.Abort, *fault.DelayWithAbort:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// sendError responds the error in the format negotiated by the Accept header, as fault.Error does.
+func sendError(c *fiber.Ctx, r *http.Request, code int, text string, xml bool) error {
+	contentType, body := fault.ErrorBody(r, code, text, xml)
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Status(code).Send(body)
+}
+
+// abort closes the connection without sending a response, as the panic of fault.Abort does on net/http.
+// If the panic value is set, it panics with the value instead, which the recover middleware of Fiber handles.
+func abort(c *fiber.Ctx, v interface{}) error {
+	if v != nil {
+		panic(v)
+	}
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(net.Conn) {})
+	return nil
+}
@@ -0,0 +1,78 @@
+package fiberfault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/hidetatz/fault"
+)
+
+func serve(t *testing.T, h *fault.Handler, r *http.Request) int {
+	t.Helper()
+	app := fiber.New()
+	app.Use(Middleware(h))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	resp, err := app.Test(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		f        fault.Fault
+		status   int
+		injected int
+	}{
+		{"error", &fault.Error{StatusCode: 503}, 503, 1},
+		{"delay", &fault.Delay{Duration: time.Millisecond}, 200, 1},
+		{"all", fault.All(&fault.Delay{Duration: time.Millisecond}, &fault.Error{StatusCode: 502}), 502, 1},
+		{"named", fault.Named("down", &fault.Error{StatusCode: 503}), 503, 1},
+		{"unsupported", &fault.Hang{}, 200, 0},
+		{"partly unsupported", fault.All(&fault.Error{StatusCode: 503}, &fault.Hang{}), 200, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := fault.New(tc.f, 1)
+			if got := serve(t, h, httptest.NewRequest("GET", "/", nil)); got != tc.status {
+				t.Errorf("status = %d, want %d", got, tc.status)
+			}
+			if got := h.Stats().Injected; got != tc.injected {
+				t.Errorf("injected = %d, want %d", got, tc.injected)
+			}
+		})
+	}
+}
+
+func TestMiddlewareOverride(t *testing.T) {
+	var reasons []string
+	h := fault.New(&fault.Delay{}, 0)
+	h.Override = &fault.Override{Secret: "s"}
+	h.Hooks = []fault.Hook{func(o fault.Outcome) { reasons = append(reasons, o.Reason) }}
+
+	for _, tc := range []struct {
+		header string
+		status int
+	}{
+		{"delay=1ms;error=503", 503},
+		{"delay=1ms;hang", 200},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Fault-Inject", tc.header)
+		r.Header.Set("X-Fault-Secret", "s")
+		if got := serve(t, h, r); got != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.header, got, tc.status)
+		}
+	}
+	if got := h.Stats().Injected; got != 1 {
+		t.Errorf("injected = %d, want 1", got)
+	}
+	if want := []string{"override", "unsupported"}; len(reasons) != 2 || reasons[0] != want[0] || reasons[1] != want[1] {
+		t.Errorf("reasons = %q, want %q", reasons, want)
+	}
+}
@@ -0,0 +1,54 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+)
+
+// FlagFunc evaluates a boolean feature flag, so that faults can be controlled by the existing feature flag
+// service, such as LaunchDarkly and Unleash, instead of a separate control plane.
+// ctx is the context of the request, so the flag can be targeted by what the context carries.
+// For example, with the OpenFeature client:
+//
+//	func(ctx context.Context, key string) (bool, error) {
+//		return client.BooleanValue(ctx, key, false, openfeature.EvaluationContext{})
+//	}
+type FlagFunc func(ctx context.Context, key string) (bool, error)
+
+// RatioFlagFunc evaluates a numeric feature flag holding a ratio in [0, 1].
+// For example, with the OpenFeature client:
+//
+//	func(ctx context.Context, key string) (float64, error) {
+//		return client.FloatValue(ctx, key, 0, openfeature.EvaluationContext{})
+//	}
+type RatioFlagFunc func(ctx context.Context, key string) (float64, error)
+
+// Flag returns a Matcher matching the requests for which the flag is on.
+// If the flag cannot be evaluated, the request does not match, so that an outage of the flag service never
+// turns the faults on.
+func Flag(key string, f FlagFunc) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		on, err := f(r.Context(), key)
+		return err == nil && on
+	})
+}
+
+// FlagRatio returns a Sampler sampling the requests by s at the ratio the flag holds, instead of the ratio
+// of the Handler. If s is nil, RandomSampler is used.
+// If the flag cannot be evaluated, the request is not sampled.
+func FlagRatio(key string, f RatioFlagFunc, s Sampler) Sampler {
+	if s == nil {
+		s = NewRandomSampler()
+	}
+	return SamplerFunc(func(r *http.Request, _ float64) bool {
+		ctx := context.Background()
+		if r != nil {
+			ctx = r.Context()
+		}
+		ratio, err := f(ctx, key)
+		if err != nil {
+			return false
+		}
+		return s.Sample(r, ratio)
+	})
+}
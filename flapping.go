@@ -0,0 +1,43 @@
+package fault
+
+import (
+	"fmt"
+	"time"
+)
+
+// Flapping is a Schedule turning the fault on for On, then off for Off, repeatedly, as a flapping dependency does.
+// Flapping stresses the circuit breakers and the health checks very differently than a constant error rate.
+// With the ratio 1, the fault is fully on and fully off in turn.
+type Flapping struct {
+	// On is how long the fault is on in a cycle. Must be positive.
+	On time.Duration
+	// Off is how long the fault is off in a cycle. Must be positive.
+	Off time.Duration
+	// Since is when the first cycle starts. If zero, the cycles are aligned to the Unix epoch,
+	// so that the processes of a service flap together.
+	Since time.Time
+}
+
+// Active returns true if t is in the on period of a cycle.
+func (f *Flapping) Active(t time.Time) bool {
+	cycle := f.On + f.Off
+	if cycle <= 0 {
+		return false
+	}
+	elapsed := t.Sub(f.Since)
+	if f.Since.IsZero() {
+		elapsed = time.Duration(t.UnixNano())
+	}
+	if elapsed < 0 {
+		return false
+	}
+	return elapsed%cycle < f.On
+}
+
+// Validate checks On and Off are positive.
+func (f *Flapping) Validate() error {
+	if f.On <= 0 || f.Off <= 0 {
+		return fmt.Errorf("flapping on %v and off %v must be positive", f.On, f.Off)
+	}
+	return nil
+}
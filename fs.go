@@ -0,0 +1,157 @@
+package fault
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// FS injects faults into an fs.FS: failing Open and Read, and slow reads, so that the code handling disk failures
+// can be tested. The functions of package fs, such as fs.ReadFile and fs.ReadDir, go through Open and Read, so they
+// are also injected.
+//
+//	fsys := &fault.FS{FS: os.DirFS("data"), OpenRatio: 0.1, OpenErr: fs.ErrPermission}
+//
+// The faults are not injected while the kill switch is on.
+type FS struct {
+	// FS is the underlying file system. Required.
+	FS fs.FS
+	// OpenRatio is the ratio of Open calls failing with OpenErr, in [0, 1].
+	OpenRatio float64
+	// OpenErr is the error failing Open, such as fs.ErrPermission. If nil, fs.ErrNotExist is used.
+	OpenErr error
+	// ReadRatio is the ratio of Read calls failing with ReadErr, in [0, 1].
+	ReadRatio float64
+	// ReadErr is the error failing Read, such as syscall.EIO. If nil, an I/O error is used.
+	ReadErr error
+	// ReadDelay delays every Read.
+	ReadDelay time.Duration
+}
+
+var errIO = errors.New("input/output error")
+
+// Open opens the named file of the underlying file system, unless it fails by the fault.
+// The errors are returned as *fs.PathError.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !AllDisabled() && defaultSampler.Sample(nil, f.OpenRatio) {
+		err := f.OpenErr
+		if err == nil {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return (&faultFile{File: file, name: name, fs: f}).wrap(), nil
+}
+
+// faultFile is an fs.File injected the faults of FS.
+type faultFile struct {
+	fs.File
+	name string
+	fs   *FS
+}
+
+// wrap returns f implementing the optional interfaces the underlying file implements, such as io.Seeker,
+// so that the callers checking them see the same file as without the faults.
+func (f *faultFile) wrap() fs.File {
+	_, ra := f.File.(io.ReaderAt)
+	_, s := f.File.(io.Seeker)
+	_, d := f.File.(fs.ReadDirFile)
+	switch {
+	case ra && s && d:
+		return struct {
+			*faultFile
+			fileReaderAt
+			fileSeeker
+			fileReadDir
+		}{f, fileReaderAt{f}, fileSeeker{f}, fileReadDir{f}}
+	case ra && s:
+		return struct {
+			*faultFile
+			fileReaderAt
+			fileSeeker
+		}{f, fileReaderAt{f}, fileSeeker{f}}
+	case ra && d:
+		return struct {
+			*faultFile
+			fileReaderAt
+			fileReadDir
+		}{f, fileReaderAt{f}, fileReadDir{f}}
+	case s && d:
+		return struct {
+			*faultFile
+			fileSeeker
+			fileReadDir
+		}{f, fileSeeker{f}, fileReadDir{f}}
+	case ra:
+		return struct {
+			*faultFile
+			fileReaderAt
+		}{f, fileReaderAt{f}}
+	case s:
+		return struct {
+			*faultFile
+			fileSeeker
+		}{f, fileSeeker{f}}
+	case d:
+		return struct {
+			*faultFile
+			fileReadDir
+		}{f, fileReadDir{f}}
+	}
+	return f
+}
+
+func (f *faultFile) Read(b []byte) (int, error) {
+	if err := f.inject("read"); err != nil {
+		return 0, err
+	}
+	return f.File.Read(b)
+}
+
+// fileReaderAt implements io.ReaderAt of a faultFile whose underlying file implements it.
+type fileReaderAt struct{ f *faultFile }
+
+func (r fileReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if err := r.f.inject("readat"); err != nil {
+		return 0, err
+	}
+	return r.f.File.(io.ReaderAt).ReadAt(b, off)
+}
+
+// fileSeeker implements io.Seeker of a faultFile whose underlying file implements it.
+type fileSeeker struct{ f *faultFile }
+
+func (s fileSeeker) Seek(offset int64, whence int) (int64, error) {
+	return s.f.File.(io.Seeker).Seek(offset, whence)
+}
+
+// fileReadDir implements fs.ReadDirFile of a faultFile whose underlying file implements it.
+type fileReadDir struct{ f *faultFile }
+
+func (d fileReadDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	return d.f.File.(fs.ReadDirFile).ReadDir(n)
+}
+
+// inject delays the read, or returns the error it fails with.
+func (f *faultFile) inject(op string) error {
+	if AllDisabled() {
+		return nil
+	}
+	if f.fs.ReadDelay > 0 {
+		sleep(f.fs.ReadDelay)
+	}
+	if defaultSampler.Sample(nil, f.fs.ReadRatio) {
+		err := f.fs.ReadErr
+		if err == nil {
+			err = errIO
+		}
+		return &fs.PathError{Op: op, Path: f.name, Err: err}
+	}
+	return nil
+}
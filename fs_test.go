@@ -0,0 +1,66 @@
+package fault
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// plainFS opens files implementing none of the optional interfaces.
+type plainFS struct{ fs.FS }
+
+func (p plainFS) Open(name string) (fs.File, error) {
+	f, err := p.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{f}, nil
+}
+
+func TestFSOptionalInterfaces(t *testing.T) {
+	mfs := fstest.MapFS{"dir/a.txt": {Data: []byte("hello")}}
+	for _, tc := range []struct {
+		name                  string
+		fsys                  fs.FS
+		path                  string
+		readerAt, seeker, dir bool
+	}{
+		{"file", mfs, "dir/a.txt", true, true, false},
+		{"dir", mfs, "dir", false, false, true},
+		{"plain", plainFS{mfs}, "dir/a.txt", false, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := (&FS{FS: tc.fsys}).Open(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, ok := f.(io.ReaderAt); ok != tc.readerAt {
+				t.Errorf("io.ReaderAt = %v, want %v", ok, tc.readerAt)
+			}
+			if _, ok := f.(io.Seeker); ok != tc.seeker {
+				t.Errorf("io.Seeker = %v, want %v", ok, tc.seeker)
+			}
+			if _, ok := f.(fs.ReadDirFile); ok != tc.dir {
+				t.Errorf("fs.ReadDirFile = %v, want %v", ok, tc.dir)
+			}
+		})
+	}
+
+	if err := fstest.TestFS(&FS{FS: mfs}, "dir/a.txt"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFSReadAt(t *testing.T) {
+	fsys := &FS{FS: fstest.MapFS{"a.txt": {Data: []byte("hello")}}, ReadRatio: 1}
+	f, err := fsys.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.(io.ReaderAt).ReadAt(make([]byte, 2), 1); err == nil {
+		t.Error("ReadAt() = nil error, want the injected error")
+	}
+}
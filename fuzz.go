@@ -0,0 +1,213 @@
+package fault
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// FuzzCharset is the kind of the garbage FuzzedBody generates.
+type FuzzCharset int
+
+const (
+	// FuzzBytes is random bytes.
+	FuzzBytes FuzzCharset = iota
+	// FuzzUTF8 is random valid UTF-8 text, mixing the characters of every length in bytes.
+	FuzzUTF8
+	// FuzzJSON is almost-valid JSON: a random JSON document with one error, such as a truncation,
+	// a trailing comma, a single-quoted string or a missing colon.
+	FuzzJSON
+)
+
+// fuzzCharsets is the names of FuzzCharset, used by the effect.
+var fuzzCharsets = map[string]FuzzCharset{
+	"bytes": FuzzBytes,
+	"utf8":  FuzzUTF8,
+	"json":  FuzzJSON,
+}
+
+// FuzzedBody replaces the body of the response of the next handler with generated garbage, keeping its status
+// code and headers, so that the parsers of the client SDKs can be tested for robustness.
+// FuzzJSON sets the Content-Type to application/json, so the clients parse it as JSON.
+type FuzzedBody struct {
+	// Charset is the kind of the garbage.
+	Charset FuzzCharset
+	// Size is the size of the garbage in bytes, which is approximate for FuzzJSON.
+	// If zero, the size of the original body is used, or 1 KiB if it is empty.
+	Size int
+}
+
+// Handle proxies the request to next, then replaces the body of the response.
+func (f *FuzzedBody) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	next.ServeHTTP(buf, r)
+
+	size := f.Size
+	if size <= 0 {
+		size = buf.body.Len()
+	}
+	if size <= 0 {
+		size = 1024
+	}
+
+	// The garbage is generated by its own source, not to hold the lock of the shared one for long.
+	rng := rand.New(rand.NewSource(int64(randomIndex(math.MaxInt32))))
+	var body []byte
+	switch f.Charset {
+	case FuzzBytes:
+		body = make([]byte, size)
+		rng.Read(body)
+	case FuzzUTF8:
+		body = fuzzUTF8(rng, size)
+	case FuzzJSON:
+		body = fuzzJSON(rng, size)
+		buf.header.Set("Content-Type", "application/json")
+	}
+
+	// The garbage is not encoded, so the clients see it as it is.
+	buf.header.Del("Content-Encoding")
+	buf.header.Set("Content-Length", strconv.Itoa(len(body)))
+	buf.copyTo(w, body)
+}
+
+// Validate checks Size is not negative.
+func (f *FuzzedBody) Validate() error {
+	return validateCount("size", f.Size)
+}
+
+// fuzzUTF8 returns random valid UTF-8 text of size bytes.
+func fuzzUTF8(rng *rand.Rand, size int) []byte {
+	b := make([]byte, 0, size)
+	for len(b) < size {
+		var c rune
+		switch n := rng.Intn(10); {
+		case n < 5:
+			c = rune(0x20 + rng.Intn(0x5f))
+		case n < 7:
+			c = rune(0x80 + rng.Intn(0x800-0x80))
+		case n < 9:
+			c = rune(0x800 + rng.Intn(0x10000-0x800))
+			if c >= 0xd800 && c <= 0xdfff {
+				// Surrogates are not valid in UTF-8.
+				c -= 0x800
+			}
+		default:
+			c = rune(0x10000 + rng.Intn(utf8.MaxRune+1-0x10000))
+		}
+		if len(b)+utf8.RuneLen(c) > size {
+			c = rune(0x20 + rng.Intn(0x5f))
+		}
+		b = append(b, string(c)...)
+	}
+	return b
+}
+
+// fuzzJSON returns almost-valid JSON of about size bytes.
+func fuzzJSON(rng *rand.Rand, size int) []byte {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for b.Len() < size-1 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		writeFuzzValue(&b, rng, 0)
+	}
+	b.WriteByte(']')
+	doc := b.Bytes()
+
+	switch rng.Intn(4) {
+	case 0:
+		// Truncated in the middle, as a cut connection does.
+		return doc[:len(doc)/2+rng.Intn(len(doc)/2)]
+	case 1:
+		return append(doc[:len(doc)-1], ",]"...)
+	case 2:
+		if i := randomByteIndex(rng, doc, '"'); i >= 0 {
+			doc[i] = '\''
+		}
+	case 3:
+		if i := randomByteIndex(rng, doc, ':'); i >= 0 {
+			doc = append(doc[:i], doc[i+1:]...)
+		}
+	}
+	return doc
+}
+
+// fuzzWords are the keys and the string values of fuzzJSON.
+var fuzzWords = []string{"id", "name", "value", "items", "data", "error", "status", "created_at", "type", "count"}
+
+// writeFuzzValue writes a random JSON value nested up to 3 levels.
+func writeFuzzValue(b *bytes.Buffer, rng *rand.Rand, depth int) {
+	n := 6
+	if depth < 3 {
+		n = 8
+	}
+	switch rng.Intn(n) {
+	case 0:
+		b.WriteString(strconv.Quote(fuzzWords[rng.Intn(len(fuzzWords))]))
+	case 1:
+		b.WriteString(strconv.Itoa(rng.Intn(1 << 20)))
+	case 2:
+		b.WriteString(strconv.FormatFloat(rng.NormFloat64()*1000, 'g', -1, 64))
+	case 3:
+		b.WriteString("true")
+	case 4:
+		b.WriteString("false")
+	case 5:
+		b.WriteString("null")
+	case 6:
+		b.WriteByte('{')
+		for i, m := 0, 1+rng.Intn(4); i < m; i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(fuzzWords[rng.Intn(len(fuzzWords))]))
+			b.WriteByte(':')
+			writeFuzzValue(b, rng, depth+1)
+		}
+		b.WriteByte('}')
+	case 7:
+		b.WriteByte('[')
+		for i, m := 0, 1+rng.Intn(4); i < m; i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeFuzzValue(b, rng, depth+1)
+		}
+		b.WriteByte(']')
+	}
+}
+
+// randomByteIndex returns the index of a random occurrence of c in b, or -1 if c does not occur.
+func randomByteIndex(rng *rand.Rand, b []byte, c byte) int {
+	n := bytes.Count(b, []byte{c})
+	if n == 0 {
+		return -1
+	}
+	k := rng.Intn(n)
+	for i, v := range b {
+		if v == c {
+			if k == 0 {
+				return i
+			}
+			k--
+		}
+	}
+	return -1
+}
+
+// parseFuzzCharset parses the name of a FuzzCharset. An empty name is FuzzBytes.
+func parseFuzzCharset(s string) (FuzzCharset, error) {
+	if s == "" {
+		return FuzzBytes, nil
+	}
+	c, ok := fuzzCharsets[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid charset %s", strconv.Quote(s))
+	}
+	return c, nil
+}
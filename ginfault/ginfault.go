@@ -0,0 +1,98 @@
+// Package ginfault adapts the faults of package fault to Gin.
+//
+//	r.Use(ginfault.Middleware(fault.New(&fault.Error{StatusCode: 503}, 0.1)))
+//
+// Gin's route pattern of the request, such as "/users/:id", is available to fault.Route,
+// so the faults can target the routes:
+//
+//	h := fault.New(&fault.Delay{Duration: time.Second}, 0.5)
+//	h.Matchers = []fault.Matcher{fault.Route("/users/:id")}
+//
+// gin.Recovery turns the panic of fault.Abort into a 500 response, so use the middleware before gin.Recovery
+// to abort the requests.
+package ginfault
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hidetatz/fault"
+)
+
+// Middleware returns a gin.HandlerFunc applying the faults of m, such as *fault.Handler, fault.Chain
+// and *fault.Reloader, to the requests.
+// If a fault does not proxy the request, such as Error, the rest of the handlers are aborted.
+// If a fault wraps the response writer, such as SSE, the handlers write through it.
+func Middleware(m fault.Middleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orig := c.Writer
+		proxied := false
+		h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = true
+			c.Request = r
+			if w != http.ResponseWriter(orig) {
+				rw := &responseWriter{ResponseWriter: orig, w: w}
+				c.Writer = rw
+				defer func() {
+					// Gin writes the pending status after the handlers, such as of c.Status without a body,
+					// so does the writer before it is unwound.
+					if rw.status != 0 {
+						rw.WriteHeaderNow()
+					}
+					c.Writer = orig
+				}()
+			}
+			c.Next()
+		}))
+
+		r := c.Request
+		h.ServeHTTP(orig, r.WithContext(fault.WithRoute(r.Context(), c.FullPath())))
+		if !proxied {
+			c.Abort()
+		}
+	}
+}
+
+// responseWriter is a gin.ResponseWriter writing through the writer of a fault.
+// The state such as Status and Size is taken from the original writer, which the fault writes to in the end.
+type responseWriter struct {
+	gin.ResponseWriter
+	w           http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.status = code
+}
+
+func (rw *responseWriter) WriteHeaderNow() {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		rw.w.WriteHeader(status)
+	}
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.WriteHeaderNow()
+	return rw.w.Write(b)
+}
+
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	return rw.Write([]byte(s))
+}
+
+func (rw *responseWriter) Flush() {
+	rw.WriteHeaderNow()
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,62 @@
+package ginfault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hidetatz/fault"
+)
+
+func TestMiddlewareStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name   string
+		marker string
+		ratio  float64
+	}{
+		{"not injected", "", 0},
+		{"injected", "", 1},
+		{"wrapped", "X-Fault", 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := fault.New(&fault.Delay{Duration: time.Millisecond}, tc.ratio)
+			h.MarkerHeader = tc.marker
+			r := gin.New()
+			r.Use(Middleware(h))
+			r.DELETE("/users/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+			r.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusCreated, "ok") })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("DELETE", "/users/1", nil))
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status without body = %d, want %d", w.Code, http.StatusNoContent)
+			}
+
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/users/1", nil))
+			if w.Code != http.StatusCreated || w.Body.String() != "ok" {
+				t.Errorf("response = %d %q, want %d %q", w.Code, w.Body.String(), http.StatusCreated, "ok")
+			}
+		})
+	}
+}
+
+func TestMiddlewareError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	called := false
+	r := gin.New()
+	r.Use(Middleware(fault.New(&fault.Error{StatusCode: http.StatusServiceUnavailable}, 1)))
+	r.GET("/", func(c *gin.Context) { called = true })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if called {
+		t.Error("the handler is called")
+	}
+}
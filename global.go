@@ -0,0 +1,54 @@
+package fault
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Counter is a counter shared among the replicas of a service, such as RedisCounter.
+type Counter interface {
+	// Incr increments the counter of the key and returns the new value.
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// GlobalSampler selects requests so that the fleet of replicas collectively injects the ratio,
+// even when the traffic is unevenly balanced among them.
+// Every request increments the shared counter, and the n-th request of the fleet is selected
+// when floor(n * ratio) increases; e.g. with the ratio 0.01, exactly every 100th request in the fleet is selected.
+// Since a round trip to the counter is added to every target request, narrow down the targets by Matchers if possible.
+type GlobalSampler struct {
+	// Counter is the shared counter. Required.
+	Counter Counter
+	// Key is the key of the counter. Use a distinct key per experiment. Required.
+	Key string
+	// Timeout is the timeout of Incr. If zero, 50 milliseconds is used.
+	Timeout time.Duration
+	// Fallback decides when the counter is unavailable. If nil, the request is not selected.
+	Fallback Sampler
+}
+
+// Sample increments the shared counter and returns whether the request is selected.
+func (s *GlobalSampler) Sample(r *http.Request, ratio float64) bool {
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 50 * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	n, err := s.Counter.Incr(ctx, s.Key)
+	if err != nil {
+		if s.Fallback != nil {
+			return s.Fallback.Sample(r, ratio)
+		}
+		return false
+	}
+
+	return math.Floor(float64(n)*ratio) > math.Floor(float64(n-1)*ratio)
+}
@@ -0,0 +1,178 @@
+package fault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// GraphQLError makes GraphQL responses partially fail, as GraphQL failures present to the clients: instead of
+// an HTTP error, some fields of the data are nulled and the errors for them are added to the response.
+// The request is proxied to next, and the successful JSON response is rewritten; the other responses are sent
+// as they are. The fields are chosen from the top-level fields of the data.
+type GraphQLError struct {
+	// FieldRatio is the ratio of the fields which fail, in [0, 1]. If zero, one field chosen at random fails.
+	FieldRatio float64
+	// Message is the message of the errors. If empty, a placeholder message is used.
+	Message string
+	// Code is set to the "code" of the extensions of the errors, such as "INTERNAL_SERVER_ERROR". Optional.
+	Code string
+}
+
+// Handle rewrites the response of the given handler.
+func (f *GraphQLError) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	next.ServeHTTP(buf, r)
+
+	body := buf.body.Bytes()
+	mt, _, _ := mime.ParseMediaType(buf.header.Get("Content-Type"))
+	if buf.code == http.StatusOK && (mt == "application/json" || mt == "application/graphql-response+json") {
+		if b, ok := f.rewrite(body); ok {
+			body = b
+		}
+	}
+	buf.copyTo(w, body)
+}
+
+// Validate checks FieldRatio is in [0, 1].
+func (f *GraphQLError) Validate() error {
+	return validateRatio("field ratio", f.FieldRatio)
+}
+
+// graphQLFieldError is an entry of the errors in a GraphQL response.
+type graphQLFieldError struct {
+	Message    string            `json:"message"`
+	Path       []string          `json:"path"`
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+// rewrite nulls the fields of the data in the response, and adds the errors for them.
+// It reports false if the response is not a GraphQL response with data.
+func (f *GraphQLError) rewrite(body []byte) ([]byte, bool) {
+	resp, err := parseObject(body)
+	if err != nil {
+		return nil, false
+	}
+	raw, ok := resp.get("data")
+	if !ok {
+		return nil, false
+	}
+	data, err := parseObject(raw)
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+
+	var failed []int
+	if f.FieldRatio > 0 {
+		for i := range data {
+			if defaultSampler.Sample(nil, f.FieldRatio) {
+				failed = append(failed, i)
+			}
+		}
+	} else {
+		failed = []int{randomIndex(len(data))}
+	}
+	if len(failed) == 0 {
+		return nil, false
+	}
+
+	message := f.Message
+	if message == "" {
+		message = "fault: pseudo field error is injected"
+	}
+	var errs []json.RawMessage
+	if raw, ok := resp.get("errors"); ok {
+		json.Unmarshal(raw, &errs)
+	}
+	for _, i := range failed {
+		data[i].value = json.RawMessage("null")
+		e := graphQLFieldError{Message: message, Path: []string{data[i].key}}
+		if f.Code != "" {
+			e.Extensions = map[string]string{"code": f.Code}
+		}
+		b, _ := json.Marshal(e)
+		errs = append(errs, b)
+	}
+
+	eb, _ := json.Marshal(errs)
+	resp.set("errors", eb)
+	resp.set("data", data.marshal())
+	return resp.marshal(), true
+}
+
+// randomIndex returns a random index of a slice of the length n.
+func randomIndex(n int) int {
+	defaultSampler.mu.Lock()
+	defer defaultSampler.mu.Unlock()
+	return defaultSampler.r.Intn(n)
+}
+
+// object is a JSON object keeping the order of its members.
+type object []member
+
+type member struct {
+	key   string
+	value json.RawMessage
+}
+
+// parseObject parses the JSON object.
+func parseObject(b []byte) (object, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, errors.New("fault: not a JSON object")
+	}
+	var o object
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var m member
+		m.key, _ = t.(string)
+		if err := dec.Decode(&m.value); err != nil {
+			return nil, err
+		}
+		o = append(o, m)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o object) get(key string) (json.RawMessage, bool) {
+	for _, m := range o {
+		if m.key == key {
+			return m.value, true
+		}
+	}
+	return nil, false
+}
+
+func (o *object) set(key string, value json.RawMessage) {
+	for i, m := range *o {
+		if m.key == key {
+			(*o)[i].value = value
+			return
+		}
+	}
+	*o = append(*o, member{key: key, value: value})
+}
+
+func (o object) marshal() []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(m.key)
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(m.value)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
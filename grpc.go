@@ -0,0 +1,173 @@
+package fault
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GRPCError responds a gRPC error status without calling the next handler, as real Google APIs fail:
+// a status code, a message and details such as RetryInfo and QuotaFailure.
+// It works at the HTTP level, so it is used in front of gRPC servers served as an http.Handler,
+// such as by grpc.Server's ServeHTTP, or in a proxy. The response is a "trailers-only" response of gRPC over HTTP/2.
+// For the servers served by grpc.Server itself, the interceptors of package grpcfault return the status.
+type GRPCError struct {
+	// Code is the gRPC status code, such as 14 for UNAVAILABLE. Required.
+	Code int
+	// Message is the status message. Optional.
+	Message string
+	// Details are attached to the status in the grpc-status-details-bin trailer, which the clients
+	// decode as the details of google.rpc.Status.
+	Details []GRPCDetail
+}
+
+// GRPCDetail is a detail of a gRPC status, which is a protobuf message packed in google.protobuf.Any.
+// Other than the ones by RetryInfo, QuotaFailure and ErrorInfo, any message can be attached
+// by its type URL and the serialized message.
+type GRPCDetail struct {
+	// TypeURL is the type URL of the message, such as "type.googleapis.com/google.rpc.RetryInfo".
+	TypeURL string
+	// Value is the serialized message.
+	Value []byte
+}
+
+// RetryInfo returns google.rpc.RetryInfo telling the clients to retry after the delay.
+func RetryInfo(delay time.Duration) GRPCDetail {
+	var d protoBuf
+	d.varint(1, uint64(delay/time.Second))
+	d.varint(2, uint64(delay%time.Second))
+	var m protoBuf
+	m.bytes(1, d)
+	return GRPCDetail{TypeURL: "type.googleapis.com/google.rpc.RetryInfo", Value: m}
+}
+
+// QuotaViolation is a violation of google.rpc.QuotaFailure.
+type QuotaViolation struct {
+	// Subject is on which the quota check failed, such as "project:my-project".
+	Subject string
+	// Description is how the quota check failed.
+	Description string
+}
+
+// QuotaFailure returns google.rpc.QuotaFailure telling the clients which quota check failed.
+func QuotaFailure(violations ...QuotaViolation) GRPCDetail {
+	var m protoBuf
+	for _, v := range violations {
+		var vb protoBuf
+		vb.string(1, v.Subject)
+		vb.string(2, v.Description)
+		m.bytes(1, vb)
+	}
+	return GRPCDetail{TypeURL: "type.googleapis.com/google.rpc.QuotaFailure", Value: m}
+}
+
+// ErrorInfo returns google.rpc.ErrorInfo describing the cause of the error.
+func ErrorInfo(reason, domain string, metadata map[string]string) GRPCDetail {
+	var m protoBuf
+	m.string(1, reason)
+	m.string(2, domain)
+	for _, k := range sortedKeys(len(metadata), func(f func(string)) {
+		for k := range metadata {
+			f(k)
+		}
+	}) {
+		var e protoBuf
+		e.string(1, k)
+		e.string(2, metadata[k])
+		m.bytes(3, e)
+	}
+	return GRPCDetail{TypeURL: "type.googleapis.com/google.rpc.ErrorInfo", Value: m}
+}
+
+// Handle responds the gRPC error status.
+func (f *GRPCError) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/grpc") {
+		contentType = "application/grpc"
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Grpc-Status", strconv.Itoa(f.Code))
+	if f.Message != "" {
+		h.Set("Grpc-Message", encodeGRPCMessage(f.Message))
+	}
+	if len(f.Details) > 0 {
+		h.Set("Grpc-Status-Details-Bin", base64.RawStdEncoding.EncodeToString(f.status()))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Validate checks Code is a gRPC status code of an error.
+func (f *GRPCError) Validate() error {
+	if f.Code < 1 || f.Code > 16 {
+		return fmt.Errorf("grpc code %d must be in [1, 16]", f.Code)
+	}
+	return nil
+}
+
+// status returns the serialized google.rpc.Status.
+func (f *GRPCError) status() []byte {
+	var s protoBuf
+	s.varint(1, uint64(f.Code))
+	s.string(2, f.Message)
+	for _, d := range f.Details {
+		var a protoBuf
+		a.string(1, d.TypeURL)
+		a.bytes(2, d.Value)
+		s.bytes(3, a)
+	}
+	return s
+}
+
+// encodeGRPCMessage percent-encodes the message as the gRPC protocol requires.
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// protoBuf is a serialized protobuf message. Only the wire types required for the details are supported.
+type protoBuf []byte
+
+func (b *protoBuf) rawVarint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+// varint appends the varint field. Zero is omitted as proto3 does.
+func (b *protoBuf) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.rawVarint(uint64(field)<<3 | 0)
+	b.rawVarint(v)
+}
+
+// bytes appends the length-delimited field.
+func (b *protoBuf) bytes(field int, v []byte) {
+	b.rawVarint(uint64(field)<<3 | 2)
+	b.rawVarint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+// string appends the string field. An empty string is omitted as proto3 does.
+func (b *protoBuf) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	b.bytes(field, []byte(v))
+}
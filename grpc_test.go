@@ -0,0 +1,87 @@
+package fault
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestGRPCDetails(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		detail  GRPCDetail
+		typeURL string
+		value   string
+	}{
+		{"retry info", RetryInfo(2 * time.Second), "type.googleapis.com/google.rpc.RetryInfo", "0a020802"},
+		{"retry info with nanos", RetryInfo(1500 * time.Millisecond), "type.googleapis.com/google.rpc.RetryInfo", "0a0808011080cab5ee01"},
+		{"retry info zero", RetryInfo(0), "type.googleapis.com/google.rpc.RetryInfo", "0a00"},
+		{"quota failure", QuotaFailure(QuotaViolation{Subject: "a", Description: "b"}), "type.googleapis.com/google.rpc.QuotaFailure", "0a060a0161120162"},
+		{"quota failure empty violation", QuotaFailure(QuotaViolation{}), "type.googleapis.com/google.rpc.QuotaFailure", "0a00"},
+		{"error info", ErrorInfo("r", "d", map[string]string{"k": "v", "a": "b"}), "type.googleapis.com/google.rpc.ErrorInfo", "0a0172120164" + "1a060a0161120162" + "1a060a016b120176"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.detail.TypeURL != tc.typeURL {
+				t.Errorf("TypeURL = %q, want %q", tc.detail.TypeURL, tc.typeURL)
+			}
+			if got := hex.EncodeToString(tc.detail.Value); got != tc.value {
+				t.Errorf("Value = %s, want %s", got, tc.value)
+			}
+		})
+	}
+}
+
+func TestGRPCErrorStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		f    *GRPCError
+		want string
+	}{
+		{"code", &GRPCError{Code: 14}, "080e"},
+		{"message", &GRPCError{Code: 14, Message: "x"}, "080e120178"},
+		{"details", &GRPCError{Code: 8, Details: []GRPCDetail{{TypeURL: "t", Value: []byte{1}}}}, "0808" + "1a06" + "0a0174120101"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := hex.EncodeToString(tc.f.status()); got != tc.want {
+				t.Errorf("status() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProtoBufVarint(t *testing.T) {
+	for _, tc := range []struct {
+		field int
+		v     uint64
+		want  []byte
+	}{
+		{1, 0, nil},
+		{1, 1, []byte{0x08, 0x01}},
+		{1, 300, []byte{0x08, 0xac, 0x02}},
+		{16, 1, []byte{0x80, 0x01, 0x01}},
+		{2, 1<<64 - 1, []byte{0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	} {
+		var b protoBuf
+		b.varint(tc.field, tc.v)
+		if !bytes.Equal(b, tc.want) {
+			t.Errorf("varint(%d, %d) = %x, want %x", tc.field, tc.v, []byte(b), tc.want)
+		}
+	}
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	for _, tc := range []struct {
+		msg, want string
+	}{
+		{"", ""},
+		{"unavailable", "unavailable"},
+		{"50% done", "50%25 done"},
+		{"line\nbreak", "line%0Abreak"},
+		{"café", "caf%C3%A9"},
+	} {
+		if got := encodeGRPCMessage(tc.msg); got != tc.want {
+			t.Errorf("encodeGRPCMessage(%q) = %q, want %q", tc.msg, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package grpcfault adapts the faults of package fault to gRPC servers served by grpc.Server, which is not
+// an http.Handler unless ServeHTTP is used.
+//
+//	h := fault.New(&fault.GRPCError{Code: 14, Message: "unavailable"}, 0.1)
+//	s := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(grpcfault.UnaryServerInterceptor(h)),
+//		grpc.ChainStreamInterceptor(grpcfault.StreamServerInterceptor(h)),
+//	)
+//
+// The Handlers decide on the calls as usual; a call is converted to a POST *http.Request to the full method name,
+// such as "/pkg.Service/Method", with the metadata as the headers, for the Matchers, the Samplers and the Hooks.
+// The faults are applied natively on gRPC, and only Delay, Error, DelayWithError, Abort, DelayWithAbort,
+// GRPCError and ConnectError are supported, including the ones combined by fault.All, fault.Named and Override.
+// The calls whose fault is not supported pass with the reason "unsupported" and are not counted as injected.
+//
+// Error and DelayWithError are returned as the statuses the gRPC clients report for the HTTP status codes,
+// such as UNAVAILABLE for 503. Abort is returned as INTERNAL, as the clients report the reset stream,
+// unless its Value is set, in which case it panics with the Value for the recovery interceptors.
+package grpcfault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hidetatz/fault"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor applying the faults of the Handlers to the calls, in order.
+func UnaryServerInterceptor(handlers ...*fault.Handler) grpc.UnaryServerInterceptor {
+	chain := fault.Chain(handlers)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		err := apply(ctx, info.FullMethod, chain, func() error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor applying the faults of the Handlers to the calls, in order.
+// The faults are applied when the stream starts; a delay with Afterward is added after the handler returns.
+func StreamServerInterceptor(handlers ...*fault.Handler) grpc.StreamServerInterceptor {
+	chain := fault.Chain(handlers)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return apply(ss.Context(), info.FullMethod, chain, func() error {
+			return handler(srv, ss)
+		})
+	}
+}
+
+// apply evaluates the Handlers on the call and calls call unless a fault ends the call.
+func apply(ctx context.Context, method string, chain fault.Chain, call func() error) error {
+	if len(chain) == 0 {
+		return call()
+	}
+
+	r := request(ctx, method)
+	var after []time.Duration
+	for _, h := range chain {
+		o := h.EvaluateSupported(r, supported)
+		if o.Decision != fault.Inject {
+			continue
+		}
+
+		for _, step := range fault.Steps(o.Fault) {
+			switch f := step.(type) {
+			case *fault.Delay:
+				if f.Afterward {
+					after = append(after, f.Duration)
+					continue
+				}
+				if err := sleep(ctx, f.Duration); err != nil {
+					return err
+				}
+			case *fault.Error:
+				return httpError(f.StatusCode, f.StatusText)
+			case *fault.DelayWithError:
+				if err := sleep(ctx, f.Duration); err != nil {
+					return err
+				}
+				return httpError(f.StatusCode, f.StatusText)
+			case *fault.Abort:
+				return abort(f.Value)
+			case *fault.DelayWithAbort:
+				if err := sleep(ctx, f.Duration); err != nil {
+					return err
+				}
+				return abort(f.Value)
+			case *fault.GRPCError:
+				return grpcError(f.Code, f.Message, f.Details)
+			case *fault.ConnectError:
+				return grpcError(f.Code, f.Message, f.Details)
+			}
+		}
+	}
+
+	err := call()
+	for _, d := range after {
+		fault.CurrentClock().Sleep(ctx, d)
+	}
+	return err
+}
+
+// supported reports whether every step of f can be applied to the calls.
+func supported(f fault.Fault) bool {
+	for _, step := range fault.Steps(f) {
+		switch step.(type) {
+		case *fault.Delay, *fault.Error, *fault.DelayWithError, *fault.Abort, *fault.DelayWithAbort,
+			*fault.GRPCError, *fault.ConnectError:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// request converts the call to *http.Request for the Handlers.
+func request(ctx context.Context, method string) *http.Request {
+	r := (&http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: method},
+		RequestURI: method,
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+	}).WithContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		if k == ":authority" {
+			if len(vs) > 0 {
+				r.Host = vs[0]
+			}
+			continue
+		}
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	if r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/grpc")
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
+
+// sleep sleeps for d, returning the status of the context if the call ends first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := fault.CurrentClock().Sleep(ctx, d); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
+// httpCodes is the gRPC codes the clients report for the HTTP status codes, see
+// https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md. Others are reported as UNKNOWN.
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:         codes.Internal,
+	http.StatusUnauthorized:       codes.Unauthenticated,
+	http.StatusForbidden:          codes.PermissionDenied,
+	http.StatusNotFound:           codes.Unimplemented,
+	http.StatusTooManyRequests:    codes.Unavailable,
+	http.StatusBadGateway:         codes.Unavailable,
+	http.StatusServiceUnavailable: codes.Unavailable,
+	http.StatusGatewayTimeout:     codes.Unavailable,
+}
+
+// httpError returns the status the clients report for the HTTP status code.
+func httpError(code int, text string) error {
+	c, ok := httpCodes[code]
+	if !ok {
+		c = codes.Unknown
+	}
+	if text == "" {
+		text = http.StatusText(code)
+	}
+	return status.Error(c, fmt.Sprintf("unexpected HTTP status code received from server: %d (%s)", code, text))
+}
+
+// abort returns the status the clients report for the reset stream, or panics with v if it is set.
+func abort(v interface{}) error {
+	if v != nil {
+		panic(v)
+	}
+	return status.Error(codes.Internal, "stream terminated by RST_STREAM with error code: INTERNAL_ERROR")
+}
+
+// grpcError returns the status with the details.
+func grpcError(code int, msg string, details []fault.GRPCDetail) error {
+	s := &spb.Status{Code: int32(code), Message: msg}
+	for _, d := range details {
+		s.Details = append(s.Details, &anypb.Any{TypeUrl: d.TypeURL, Value: d.Value})
+	}
+	return status.FromProto(s).Err()
+}
@@ -0,0 +1,131 @@
+package grpcfault
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hidetatz/fault"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func client(t *testing.T, h *fault.Handler) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(h)),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(h)),
+	)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestInterceptors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		f    fault.Fault
+		code codes.Code
+	}{
+		{"error", &fault.Error{StatusCode: 503}, codes.Unavailable},
+		{"error unknown", &fault.Error{StatusCode: 418}, codes.Unknown},
+		{"delay with error", &fault.DelayWithError{Duration: time.Millisecond, StatusCode: 401}, codes.Unauthenticated},
+		{"abort", &fault.Abort{}, codes.Internal},
+		{"grpc error", &fault.GRPCError{Code: 8, Message: "quota", Details: []fault.GRPCDetail{fault.RetryInfo(time.Second)}}, codes.ResourceExhausted},
+		{"delay", &fault.Delay{Duration: time.Millisecond}, codes.OK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := fault.New(tc.f, 1)
+			h.Matchers = []fault.Matcher{fault.GRPCService("grpc.health.v1.Health")}
+			c := client(t, h)
+
+			_, err := c.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			if got := status.Code(err); got != tc.code {
+				t.Errorf("unary: code = %v, want %v: %v", got, tc.code, err)
+			}
+
+			stream, err := c.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if got := status.Code(err); got != tc.code {
+				t.Errorf("stream: code = %v, want %v: %v", got, tc.code, err)
+			}
+		})
+	}
+}
+
+func TestInterceptorsGRPCErrorDetails(t *testing.T) {
+	h := fault.New(&fault.GRPCError{Code: 14, Message: "down", Details: []fault.GRPCDetail{fault.RetryInfo(2 * time.Second)}}, 1)
+	_, err := client(t, h).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	s := status.Convert(err)
+	if s.Code() != codes.Unavailable || s.Message() != "down" {
+		t.Fatalf("status = %v", s)
+	}
+	if ds := s.Proto().GetDetails(); len(ds) != 1 || ds[0].GetTypeUrl() != "type.googleapis.com/google.rpc.RetryInfo" {
+		t.Errorf("details = %v", ds)
+	}
+}
+
+func TestInterceptorsNotInjected(t *testing.T) {
+	h := fault.New(&fault.Error{StatusCode: 503}, 0)
+	if _, err := client(t, h).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := h.Stats().Injected; n != 0 {
+		t.Errorf("injected = %d, want 0", n)
+	}
+}
+
+func TestInterceptorsOverride(t *testing.T) {
+	h := fault.New(&fault.Delay{}, 0)
+	h.Override = &fault.Override{Secret: "s"}
+	c := client(t, h)
+
+	for _, tc := range []struct {
+		header string
+		code   codes.Code
+	}{
+		{"delay=1ms;grpc_error(code=8,message=quota)", codes.ResourceExhausted},
+		{"delay=1ms;error=503", codes.Unavailable},
+		{"delay=1ms;hang", codes.OK},
+	} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-fault-inject", tc.header, "x-fault-secret", "s")
+		_, err := c.Check(ctx, &healthpb.HealthCheckRequest{})
+		if got := status.Code(err); got != tc.code {
+			t.Errorf("%s: code = %v, want %v: %v", tc.header, got, tc.code, err)
+		}
+	}
+	// The unsupported hang is not counted.
+	if n := h.Stats().Injected; n != 2 {
+		t.Errorf("injected = %d, want 2", n)
+	}
+}
+
+func TestInterceptorsUnsupported(t *testing.T) {
+	h := fault.New(fault.All(&fault.Delay{}, &fault.Hang{}), 1)
+	if _, err := client(t, h).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := h.Stats().Injected; n != 0 {
+		t.Errorf("injected = %d, want 0", n)
+	}
+}
@@ -0,0 +1,86 @@
+package fault
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// The gRPC matchers work at the HTTP level as GRPCError does, so they target the RPCs of a gRPC server served as
+// an http.Handler, such as by grpc.Server's ServeHTTP, or the ones passing through a proxy.
+// They also target the RPCs intercepted by package grpcfault, which describes the RPCs as requests.
+// They match only the gRPC requests, whose Content-Type is application/grpc or its variants.
+
+// GRPCMethod returns a Matcher matching the RPCs of any of the full method names, such as
+// "/helloworld.Greeter/SayHello". The leading "/" can be omitted.
+func GRPCMethod(methods ...string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		if !isGRPC(r) {
+			return false
+		}
+		for _, m := range methods {
+			if strings.TrimPrefix(m, "/") == strings.TrimPrefix(r.URL.Path, "/") {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// GRPCService returns a Matcher matching the RPCs of any of the full service names, such as "helloworld.Greeter".
+func GRPCService(services ...string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		if !isGRPC(r) {
+			return false
+		}
+		service, _, ok := cutTrimmed(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if !ok {
+			return false
+		}
+		for _, s := range services {
+			if s == service {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// GRPCMetadata returns a Matcher matching the RPCs whose incoming metadata has the key with value, such as
+// GRPCMetadata("x-tenant", "acme"). The key is case-insensitive. The values of the binary keys ending with "-bin"
+// are compared after decoding base64. If value is empty, the RPCs having the key match.
+func GRPCMetadata(key, value string) Matcher {
+	binary := strings.HasSuffix(strings.ToLower(key), "-bin")
+	return MatcherFunc(func(r *http.Request) bool {
+		if !isGRPC(r) {
+			return false
+		}
+		vs := r.Header.Values(key)
+		if value == "" {
+			return len(vs) > 0
+		}
+		for _, h := range vs {
+			// Several values of a key may be sent in one header joined by ",".
+			for _, v := range strings.Split(h, ",") {
+				v = strings.TrimSpace(v)
+				if binary {
+					b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(v, "="))
+					if err != nil {
+						continue
+					}
+					v = string(b)
+				}
+				if v == value {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// isGRPC reports whether the request is a gRPC request by its Content-Type.
+func isGRPC(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+") || strings.HasPrefix(ct, "application/grpc;")
+}
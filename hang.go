@@ -0,0 +1,33 @@
+package fault
+
+import (
+	"net/http"
+	"time"
+)
+
+// Hang accepts the request and never responds, so that the client timeouts, the WriteTimeout of the server and
+// the idle timeouts of the load balancers can be tested.
+// It blocks until the request context is done, which happens when the client gives up and closes the connection.
+// If Max is set, it aborts the request as Abort does after Max, still without writing any response.
+type Hang struct {
+	// Max is how long it hangs at most. If zero, it hangs until the request context is done.
+	Max time.Duration
+}
+
+// Handle hangs the request.
+func (f *Hang) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if f.Max <= 0 {
+		<-r.Context().Done()
+		return
+	}
+
+	if err := sleepContext(r.Context(), f.Max); err != nil {
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// Validate checks Max is not negative.
+func (f *Hang) Validate() error {
+	return validateDuration("max", f.Max)
+}
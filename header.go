@@ -0,0 +1,38 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultLargeHeaderName is the name of the header LargeHeader adds by default.
+const DefaultLargeHeaderName = "X-Fault-Padding"
+
+// LargeHeader adds a very large header to the response of the next handler, so that the header size limits of
+// the clients and the proxies, such as http.Transport.MaxResponseHeaderBytes and the proxy buffer sizes,
+// and the handling of their errors can be tested.
+type LargeHeader struct {
+	// Name is the name of the added header. If empty, DefaultLargeHeaderName is used.
+	Name string
+	// KB is the size of the header value in kilobytes. Must be positive.
+	KB int
+}
+
+// Handle adds the header, then calls next.
+func (f *LargeHeader) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	name := f.Name
+	if name == "" {
+		name = DefaultLargeHeaderName
+	}
+	w.Header().Set(name, strings.Repeat("x", f.KB*1024))
+	next.ServeHTTP(w, r)
+}
+
+// Validate checks KB is positive.
+func (f *LargeHeader) Validate() error {
+	if f.KB <= 0 {
+		return fmt.Errorf("kb %d must be positive", f.KB)
+	}
+	return nil
+}
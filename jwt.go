@@ -0,0 +1,114 @@
+package fault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// JWTClaimKey returns a KeyFunc which extracts the claim from the JWT in the Authorization header
+// as a bearer token, such as JWTClaimKey("tenant_id"). Strings, numbers and booleans are supported.
+// The signature is not verified; the claim only selects the requests the faults are injected into,
+// and a client forging it can only disturb its own requests. Verify the token in the application as usual.
+func JWTClaimKey(claim string) KeyFunc {
+	return func(r *http.Request) string {
+		claims := jwtClaims(bearerToken(r))
+		if claims == nil {
+			return ""
+		}
+		s, _ := claimString(claims[claim])
+		return s
+	}
+}
+
+// JWTClaim returns a Matcher matching the requests whose JWT in the Authorization header has the claim with value,
+// such as JWTClaim("sub", "user-123") or JWTClaim("chaos", "true"). Strings, numbers and booleans are compared
+// by their text, and an array claim, such as "aud", matches by any of its elements.
+// If value is empty, the requests having the claim match.
+// As JWTClaimKey, the signature is not verified; use VerifiedJWTClaim to target only the verified identities.
+func JWTClaim(claim, value string) Matcher {
+	return VerifiedJWTClaim(claim, value, nil)
+}
+
+// JWTVerifier verifies the signature and the validity of the JWT, such as by a JWT library with the keys of
+// the identity provider. The token is the raw JWT without "Bearer ".
+type JWTVerifier func(token string) error
+
+// VerifiedJWTClaim returns a Matcher as JWTClaim does, which also requires the JWT to be verified by verify.
+// If verify is nil, the signature is not verified.
+func VerifiedJWTClaim(claim, value string, verify JWTVerifier) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		token := bearerToken(r)
+		claims := jwtClaims(token)
+		if claims == nil {
+			return false
+		}
+		v, ok := claims[claim]
+		if !ok {
+			return false
+		}
+		if value != "" && !claimMatches(v, value) {
+			return false
+		}
+		return verify == nil || verify(token) == nil
+	})
+}
+
+// claimMatches reports whether the claim is value, or has value if it is an array.
+func claimMatches(claim interface{}, value string) bool {
+	if vs, ok := claim.([]interface{}); ok {
+		for _, v := range vs {
+			if s, ok := claimString(v); ok && s == value {
+				return true
+			}
+		}
+		return false
+	}
+	s, ok := claimString(claim)
+	return ok && s == value
+}
+
+// claimString returns the text of a string, number or boolean claim.
+func claimString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// bearerToken returns the bearer token in the Authorization header of the request, or "" if there is none.
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
+
+// jwtClaims decodes the claims of the JWT, or returns nil if it is not a valid one.
+func jwtClaims(token string) map[string]interface{} {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil
+	}
+
+	d := json.NewDecoder(strings.NewReader(string(b)))
+	d.UseNumber()
+	var claims map[string]interface{}
+	if err := d.Decode(&claims); err != nil {
+		return nil
+	}
+	return claims
+}
@@ -0,0 +1,57 @@
+package fault
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+var globalDisabled uint32
+
+// DisableAll immediately stops every Handler in the process from injecting faults.
+// This is the kill switch for incident response while an experiment is running.
+func DisableAll() {
+	atomic.StoreUint32(&globalDisabled, 1)
+}
+
+// EnableAll resumes the Handlers stopped by DisableAll.
+// Handlers disabled individually stay disabled.
+func EnableAll() {
+	atomic.StoreUint32(&globalDisabled, 0)
+}
+
+// AllDisabled reports whether the kill switch is on.
+func AllDisabled() bool {
+	return atomic.LoadUint32(&globalDisabled) == 1
+}
+
+// DisableAllOnSignal calls DisableAll when the process receives any of the signals.
+// If no signal is given, SIGUSR2 is used on the platforms supporting it; on the others, signals must be given.
+// The returned function stops listening the signals.
+func DisableAllOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = defaultKillSignals
+	}
+	if len(sigs) == 0 {
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				DisableAll()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package fault
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultKillSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,47 @@
+package fault
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ContentLengthMismatch sends the response of the next handler with a Content-Length header which differs from
+// the length of the body, as misbehaving upstreams do, so that the handling of the intermediaries and the clients
+// can be tested. With a larger Content-Length, the clients wait for the rest of the body until the connection is
+// closed; with a smaller one, the rest of the body follows the response as garbage before the connection is closed.
+// It hijacks the connection to write the raw response, since net/http never sends a wrong Content-Length,
+// so it works only on HTTP/1.x. On HTTP/2, the request is aborted as Abort does.
+type ContentLengthMismatch struct {
+	// Delta is added to the length of the body to make the Content-Length; positive to declare a larger length,
+	// and negative to declare a smaller one. Must not be zero.
+	// The Content-Length never goes below zero.
+	Delta int
+}
+
+// Handle proxies the request to next, then sends the response with the wrong Content-Length.
+func (f *ContentLengthMismatch) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	next.ServeHTTP(buf, r)
+
+	writeRaw(w, r, func(bw *bufio.Writer) {
+		body := buf.body.Bytes()
+		n := len(body) + f.Delta
+		if n < 0 {
+			n = 0
+		}
+		buf.header.Del("Transfer-Encoding")
+		buf.header.Set("Content-Length", strconv.Itoa(n))
+		writeRawHeader(bw, buf.code, buf.header)
+		bw.Write(body)
+	})
+}
+
+// Validate checks Delta is not zero.
+func (f *ContentLengthMismatch) Validate() error {
+	if f.Delta == 0 {
+		return fmt.Errorf("delta must not be zero")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package fault
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Listener injects connection-level faults into the connections accepted by the wrapped net.Listener,
+// before HTTP even parses the request. This tests L4 failure modes such as slow accepts, connection resets
+// and connections closed by the server without a response.
+//
+//	ln, _ := net.Listen("tcp", ":8080")
+//	http.Serve(&fault.Listener{Listener: ln, RefuseRatio: 0.1}, mux)
+//
+// The faults are not injected while the kill switch is on.
+type Listener struct {
+	net.Listener
+	// AcceptDelay delays every Accept for the duration. Since servers usually accept connections one by one,
+	// this also delays the connections waiting in the backlog, as an overloaded server does.
+	AcceptDelay time.Duration
+	// RefuseRatio is the ratio of connections reset right after they are accepted, in [0, 1].
+	// The client sees "connection reset by peer" where possible, otherwise the connection is just closed.
+	// Refused connections are not returned by Accept.
+	RefuseRatio float64
+	// CloseRatio is the ratio of connections closed by the server after CloseAfter, in [0, 1].
+	CloseRatio float64
+	// CloseAfter is how long the connections chosen by CloseRatio live. If zero, they are closed
+	// before anything is read from them.
+	CloseAfter time.Duration
+	// Conn injects faults into the reads and writes of the accepted connections. If nil, they are not wrapped.
+	Conn *ConnFault
+}
+
+// Accept waits for and returns the next connection which is not refused.
+func (l *Listener) Accept() (net.Conn, error) {
+	for {
+		if l.AcceptDelay > 0 && !AllDisabled() {
+			sleep(l.AcceptDelay)
+		}
+
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if AllDisabled() {
+			return c, nil
+		}
+
+		if defaultSampler.Sample(nil, l.RefuseRatio) {
+			reset(c)
+			continue
+		}
+
+		if defaultSampler.Sample(nil, l.CloseRatio) {
+			c = closeAfter(c, l.CloseAfter)
+		}
+		if l.Conn != nil {
+			c = l.Conn.Wrap(c)
+		}
+
+		return c, nil
+	}
+}
+
+// reset closes the connection with RST instead of FIN if the connection supports it.
+func reset(c net.Conn) {
+	if tc, ok := c.(interface{ SetLinger(sec int) error }); ok {
+		tc.SetLinger(0)
+	}
+	c.Close()
+}
+
+// closeAfter returns the connection which is closed after d.
+func closeAfter(c net.Conn, d time.Duration) net.Conn {
+	if d <= 0 {
+		c.Close()
+		return c
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		if sleepContext(ctx, d) == nil {
+			c.Close()
+		}
+	}()
+	return &earlyCloseConn{Conn: c, cancel: cancel}
+}
+
+// earlyCloseConn is a net.Conn closed by a timer.
+type earlyCloseConn struct {
+	net.Conn
+	cancel context.CancelFunc
+}
+
+func (c *earlyCloseConn) Close() error {
+	c.cancel()
+	return c.Conn.Close()
+}
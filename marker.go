@@ -0,0 +1,93 @@
+package fault
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FaultName returns the name of the fault, which is used to report the fault such as in the marker header.
+// If the fault has a method Name() string, its result is used.
+// Otherwise the type name of the fault in snake case is used, which is the same as the registered effect name
+// for the built-in faults; e.g. "delay_with_error" for DelayWithError.
+func FaultName(f Fault) string {
+	if n, ok := f.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+
+	t := reflect.TypeOf(f)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Insert an underscore at every word boundary, keeping acronyms together; e.g. "SSEStall" to "sse_stall".
+	rs := []rune(t.Name())
+	var b strings.Builder
+	for i, c := range rs {
+		if unicode.IsUpper(c) {
+			if i > 0 && (!unicode.IsUpper(rs[i-1]) || i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// markerValue is the value of the marker header, such as "error;ratio=0.1;request_id=abc".
+func markerValue(o Outcome, ratio float64) string {
+	v := FaultName(o.Fault) + ";ratio=" + strconv.FormatFloat(ratio, 'f', -1, 64)
+	if o.Reason != "sampled" {
+		v += ";reason=" + o.Reason
+	}
+	if id := o.RequestID; id != "" {
+		if strings.ContainsAny(id, "\";, ") {
+			id = strconv.Quote(id)
+		}
+		v += ";request_id=" + id
+	}
+	return v
+}
+
+// markerWriter adds the marker header to the response, unless the response is written by the next handler.
+type markerWriter struct {
+	http.ResponseWriter
+	name, value string
+	proxied     bool
+	wroteHeader bool
+}
+
+func (w *markerWriter) WriteHeader(code int) {
+	if !w.wroteHeader && !w.proxied {
+		w.Header().Set(w.name, w.value)
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *markerWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *markerWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *markerWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("fault: %T does not support hijacking", w.ResponseWriter)
+	}
+	return h.Hijack()
+}
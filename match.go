@@ -0,0 +1,229 @@
+package fault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query returns a Matcher matching the requests whose query has the parameter key with value, such as
+// Query("debug_chaos", "1") for "?debug_chaos=1". If value is empty, the requests having the parameter with
+// any value, including the empty one, match. A parameter given several times matches by any of its values.
+func Query(key, value string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		vs, ok := r.URL.Query()[key]
+		if !ok {
+			return false
+		}
+		if value == "" {
+			return true
+		}
+		for _, v := range vs {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// QueryRegexp returns a Matcher matching the requests whose query has the parameter key with a value matching re,
+// such as QueryRegexp("customer_id", regexp.MustCompile(`^acme-`)).
+func QueryRegexp(key string, re *regexp.Regexp) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		for _, v := range r.URL.Query()[key] {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Cookie returns a Matcher matching the requests having the cookie name with value, such as Cookie("chaos", "on")
+// set by QA, so that the faults can be opted into by individual browser sessions.
+// If value is empty, the requests having the cookie with any value match.
+func Cookie(name, value string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		for _, c := range r.Cookies() {
+			if c.Name == name && (value == "" || c.Value == value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// maxMatchBody is the largest request body the body matchers inspect.
+const maxMatchBody = 1 << 20
+
+// BodyJSON returns a Matcher matching the requests whose JSON body has the field at path with value, such as
+// BodyJSON("type", "refund") for {"type":"refund"}. The path is the names of the fields joined by ".", and the
+// indexes of the arrays can be in it, such as "items.0.sku". The numbers, the booleans and null are compared by
+// their JSON text, such as "10" and "true". If value is empty, the requests having the field match.
+//
+// The body is buffered to be inspected, and the next handler reads it as it is.
+// The requests whose body is not JSON or larger than 1 MiB do not match.
+func BodyJSON(path, value string) Matcher {
+	keys := strings.Split(path, ".")
+	return MatcherFunc(func(r *http.Request) bool {
+		b, ok := peekBody(r, maxMatchBody)
+		if !ok {
+			return false
+		}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return false
+		}
+
+		for _, k := range keys {
+			switch x := v.(type) {
+			case map[string]interface{}:
+				if v, ok = x[k]; !ok {
+					return false
+				}
+			case []interface{}:
+				i, err := strconv.Atoi(k)
+				if err != nil || i < 0 || i >= len(x) {
+					return false
+				}
+				v = x[i]
+			default:
+				return false
+			}
+		}
+		if value == "" {
+			return true
+		}
+
+		switch x := v.(type) {
+		case string:
+			return x == value
+		case json.Number:
+			return x.String() == value
+		case bool:
+			return strconv.FormatBool(x) == value
+		case nil:
+			return value == "null"
+		}
+		return false
+	})
+}
+
+// BodyRegexp returns a Matcher matching the requests whose body matches re,
+// such as BodyRegexp(regexp.MustCompile(`"type":\s*"refund"`)).
+//
+// The body is buffered to be inspected, and the next handler reads it as it is.
+// The requests whose body is larger than 1 MiB do not match.
+func BodyRegexp(re *regexp.Regexp) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		b, ok := peekBody(r, maxMatchBody)
+		return ok && re.Match(b)
+	})
+}
+
+// PathMatcher is a Matcher matching the paths of the requests by a regular expression, for the routing schemes
+// the prefixes cannot express, such as `/users/[0-9]+/orders`. See PathRegexp.
+type PathMatcher struct {
+	re *regexp.Regexp
+}
+
+// PathRegexp returns a PathMatcher matching the requests whose path matches expr as a whole.
+// The named groups of expr capture the path parameters, which Params returns for logging:
+//
+//	m, err := fault.PathRegexp(`/users/(?P<user>[0-9]+)/orders`)
+//	h.Matchers = append(h.Matchers, m)
+//	h.Hooks = append(h.Hooks, func(o fault.Outcome) {
+//		if o.Decision == fault.Inject {
+//			log.Printf("fault injected: %v", m.Params(o.Request))
+//		}
+//	})
+func PathRegexp(expr string) (*PathMatcher, error) {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("fault: %w", err)
+	}
+	return &PathMatcher{re: re}, nil
+}
+
+// Match returns true if the path of the request matches.
+func (m *PathMatcher) Match(r *http.Request) bool {
+	return m.re.MatchString(r.URL.Path)
+}
+
+// Params returns the path parameters captured by the named groups, or nil if the path does not match.
+func (m *PathMatcher) Params(r *http.Request) map[string]string {
+	sub := m.re.FindStringSubmatch(r.URL.Path)
+	if sub == nil {
+		return nil
+	}
+	params := map[string]string{}
+	for i, name := range m.re.SubexpNames() {
+		if name != "" {
+			params[name] = sub[i]
+		}
+	}
+	return params
+}
+
+// Host returns a Matcher matching the requests to the hosts matching any of patterns, such as
+// Host("payments.internal") and Host("*.example.com:8443"). A pattern starting with "*." matches the subdomains,
+// and a pattern with a port matches only the port, where the port defaults to the one of the scheme.
+// The outgoing requests of Transport are matched by the destination, and the incoming ones by the Host header.
+func Host(patterns ...string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		return matchHosts(patterns, r)
+	})
+}
+
+// matchHosts reports whether the request is sent to a host matching any of the patterns.
+func matchHosts(patterns []string, r *http.Request) bool {
+	host, port := requestHostPort(r)
+	for _, p := range patterns {
+		if matchHost(p, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestHostPort returns the lower-cased host and the port the request is sent to.
+func requestHostPort(r *http.Request) (host, port string) {
+	hostport := r.URL.Host
+	if hostport == "" {
+		hostport = r.Host
+	}
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.Trim(hostport, "[]")
+		switch {
+		case r.URL.Scheme == "https", r.URL.Scheme == "" && r.TLS != nil:
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return strings.ToLower(strings.TrimSuffix(host, ".")), port
+}
+
+// matchHost reports whether the host and the port match the pattern, see Host.
+func matchHost(pattern, host, port string) bool {
+	if h, p, err := net.SplitHostPort(pattern); err == nil {
+		if p != port {
+			return false
+		}
+		pattern = h
+	}
+	pattern = strings.ToLower(strings.TrimSuffix(strings.Trim(pattern, "[]"), "."))
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
@@ -0,0 +1,87 @@
+package fault
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Mock responds a canned response without calling the next handler, so that a dependency can be replaced with
+// the failure fixtures entirely. The response can be loaded from a file by LoadMock, or from the embedded bytes
+// by ParseMock:
+//
+//	//go:embed testdata/unavailable.http
+//	var unavailable []byte
+//
+//	m, err := fault.ParseMock(unavailable)
+type Mock struct {
+	// StatusCode is the status code of the response. Required.
+	StatusCode int
+	// Header is the header of the response.
+	Header http.Header
+	// Body is the body of the response.
+	Body []byte
+}
+
+// Handle responds the canned response.
+func (f *Mock) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	h := w.Header()
+	for k, vs := range f.Header {
+		h[k] = vs
+	}
+	w.WriteHeader(f.StatusCode)
+	w.Write(f.Body)
+}
+
+// Validate checks StatusCode is valid.
+func (f *Mock) Validate() error {
+	return validateStatus(f.StatusCode)
+}
+
+// LoadMock reads the Mock from the file at the given path, see ParseMock for the format.
+func LoadMock(path string) (*Mock, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseMock(b)
+}
+
+// ParseMock parses the Mock from a raw HTTP/1.x response, such as the one dumped by curl -i:
+//
+//	HTTP/1.1 503 Service Unavailable
+//	Content-Type: application/json
+//	Retry-After: 30
+//
+//	{"error":"unavailable"}
+//
+// The lines may end with either CRLF or LF. The body is the rest of the input as is, and Content-Length and
+// Transfer-Encoding are dropped since net/http sets them for the body.
+func ParseMock(b []byte) (*Mock, error) {
+	m, err := parseMock(b)
+	if err != nil {
+		return nil, fmt.Errorf("fault: parse mock: %w", err)
+	}
+	return m, nil
+}
+
+func parseMock(b []byte) (*Mock, error) {
+	br := bufio.NewReader(bytes.NewReader(b))
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The body is read from br directly, not by resp.Body, so that Content-Length and Transfer-Encoding are ignored.
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Header.Del("Content-Length")
+	resp.Header.Del("Transfer-Encoding")
+	return &Mock{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
@@ -0,0 +1,114 @@
+package fault
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types of the error bodies.
+const (
+	textMediaType = "text/plain"
+	jsonMediaType = "application/json"
+	xmlMediaType  = "application/xml"
+)
+
+// errorEnvelope is the JSON and the XML body of the injected errors.
+type errorEnvelope struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Code    int      `json:"code" xml:"code"`
+	Message string   `json:"message" xml:"message"`
+}
+
+// ErrorBody returns the Content-Type and the body of the error Error responds to the request, in the format
+// negotiated by the Accept header: {"error":{"code":503,"message":"..."}} for JSON, <error><code>503</code>
+// <message>...</message></error> for XML if xml is true, and the plain text otherwise.
+// If text is empty, a placeholder message is used.
+// It is exported so that the adapters of the other frameworks respond the same errors.
+func ErrorBody(r *http.Request, code int, text string, xml bool) (contentType string, body []byte) {
+	if text == "" {
+		text = "fault: pseudo status text is injected"
+	}
+
+	offers := []string{textMediaType, jsonMediaType}
+	if xml {
+		offers = append(offers, xmlMediaType)
+	}
+	switch negotiate(r.Header.Get("Accept"), offers) {
+	case jsonMediaType:
+		b, _ := json.Marshal(struct {
+			Error errorEnvelope `json:"error"`
+		}{errorEnvelope{Code: code, Message: text}})
+		return jsonMediaType, b
+	case xmlMediaType:
+		b, _ := xmlMarshal(errorEnvelope{Code: code, Message: text})
+		return xmlMediaType + "; charset=utf-8", b
+	}
+	return textMediaType + "; charset=utf-8", []byte(text)
+}
+
+func xmlMarshal(v interface{}) ([]byte, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// writeError writes the error negotiated by ErrorBody.
+func writeError(w http.ResponseWriter, r *http.Request, code int, text string, xml bool) {
+	contentType, body := ErrorBody(r, code, text, xml)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// negotiate returns the offer the Accept header prefers. The offer of the highest quality wins; among the ones
+// of the same quality, the one matched by the earlier media range in the header wins, then the earlier offer.
+// If the header is empty or accepts none of the offers, the first offer is returned.
+func negotiate(accept string, offers []string) string {
+	best, bestQ, bestPos := offers[0], 0.0, 0
+	for _, offer := range offers {
+		q, pos := acceptQuality(accept, offer)
+		if q > bestQ || q == bestQ && q > 0 && pos < bestPos {
+			best, bestQ, bestPos = offer, q, pos
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality of the media type by the most specific media range in the Accept header
+// matching it, and the position of the range in the header.
+func acceptQuality(accept, mediaType string) (q float64, pos int) {
+	typ := mediaType[:strings.IndexByte(mediaType, '/')]
+	specificity := -1
+	for i, rng := range strings.Split(accept, ",") {
+		params := strings.Split(rng, ";")
+		mr := strings.ToLower(strings.TrimSpace(params[0]))
+		var s int
+		switch {
+		case mr == mediaType:
+			s = 2
+		case mr == typ+"/*":
+			s = 1
+		case mr == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q, pos = s, 1, i
+		for _, p := range params[1:] {
+			if k, v, ok := cutTrimmed(p, "="); ok && k == "q" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+	}
+	return q, pos
+}
@@ -0,0 +1,119 @@
+package fault
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// NetError is the transport error TransportError fails the round trip with.
+type NetError int
+
+const (
+	// NetConnectionRefused fails with *net.OpError of ECONNREFUSED, as dialing a closed port does.
+	NetConnectionRefused NetError = iota
+	// NetTimeout fails with *net.OpError of os.ErrDeadlineExceeded, whose Timeout reports true.
+	NetTimeout
+	// NetNoSuchHost fails with *net.OpError wrapping *net.DNSError of NXDOMAIN.
+	NetNoSuchHost
+	// NetTLSHandshake fails with x509.UnknownAuthorityError, as the handshake with an untrusted certificate does.
+	NetTLSHandshake
+	// NetUnexpectedEOF sends a part of the response body of the next handler and aborts,
+	// so reading the body fails with io.ErrUnexpectedEOF.
+	NetUnexpectedEOF
+)
+
+// netErrors is the names of NetError, used by the effect.
+var netErrors = map[string]NetError{
+	"connection_refused": NetConnectionRefused,
+	"timeout":            NetTimeout,
+	"no_such_host":       NetNoSuchHost,
+	"tls_handshake":      NetTLSHandshake,
+	"unexpected_eof":     NetUnexpectedEOF,
+}
+
+// TransportError fails the round trips of Transport with the errors http.Transport returns on the connection-level
+// failures, so that the error type checks of the callers, such as errors.As with *net.OpError and the Timeout method
+// of net.Error, behave as in production. http.Client wraps them in *url.Error as usual.
+//
+// The server side cannot fail the connection in these ways, so it aborts the connection as Abort does,
+// except for NetUnexpectedEOF, which behaves the same on both sides.
+type TransportError struct {
+	// Error is the transport error.
+	Error NetError
+	// Timeout is how long NetTimeout hangs before failing, unless the request context is done earlier.
+	// If zero, it fails immediately.
+	Timeout time.Duration
+}
+
+// Handle fails the round trip of the request.
+func (f *TransportError) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if f.Error == NetUnexpectedEOF {
+		(&PartialResponse{Fraction: 0.5}).Handle(w, r, next)
+		return
+	}
+
+	rw, ok := r.Context().Value(roundTripKey{}).(*roundTripWriter)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	if f.Error == NetTimeout && f.Timeout > 0 {
+		if err := sleepContext(r.Context(), f.Timeout); err != nil {
+			rw.fail(err)
+			return
+		}
+	}
+	rw.fail(f.err(r))
+}
+
+// Validate checks Timeout is not negative.
+func (f *TransportError) Validate() error {
+	return validateDuration("timeout", f.Timeout)
+}
+
+// err returns the error the round trip of r fails with.
+func (f *TransportError) err(r *http.Request) error {
+	addr := hostAddr(r.URL.Host)
+	if r.URL.Port() == "" {
+		port := "80"
+		if r.URL.Scheme == "https" {
+			port = "443"
+		}
+		addr = hostAddr(net.JoinHostPort(r.URL.Hostname(), port))
+	}
+
+	switch f.Error {
+	case NetConnectionRefused:
+		return &net.OpError{Op: "dial", Net: "tcp", Addr: addr, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	case NetTimeout:
+		return &net.OpError{Op: "dial", Net: "tcp", Addr: addr, Err: os.ErrDeadlineExceeded}
+	case NetNoSuchHost:
+		return &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: r.URL.Hostname(), IsNotFound: true}}
+	case NetTLSHandshake:
+		return x509.UnknownAuthorityError{}
+	}
+	return fmt.Errorf("fault: unknown transport error %d", f.Error)
+}
+
+// hostAddr is the net.Addr of the host the request is sent to, which may not be resolved.
+type hostAddr string
+
+func (a hostAddr) Network() string { return "tcp" }
+func (a hostAddr) String() string  { return string(a) }
+
+// parseNetError parses the name of a NetError. An empty name is NetConnectionRefused.
+func parseNetError(s string) (NetError, error) {
+	if s == "" {
+		return NetConnectionRefused, nil
+	}
+	e, ok := netErrors[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid error %s", strconv.Quote(s))
+	}
+	return e, nil
+}
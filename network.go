@@ -0,0 +1,55 @@
+package fault
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientNetworks returns a Matcher matching the requests from any of the networks, such as "10.20.0.0/16" for
+// the load test generators. A plain IP address matches the address only.
+// The client IP is taken from the RemoteAddr as ClientIPKey does. To always skip some networks,
+// such as the office VPN, negate the Matcher by Not.
+func ClientNetworks(cidrs ...string) (Matcher, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("fault: invalid IP address %q", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("fault: %w", err)
+		}
+		nets = append(nets, n)
+	}
+
+	return MatcherFunc(func(r *http.Request) bool {
+		ip := net.ParseIP(ClientIPKey(r))
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// Not returns a Matcher matching the requests m does not match.
+func Not(m Matcher) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		return !m.Match(r)
+	})
+}
@@ -0,0 +1,446 @@
+package fault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIError responds the error responses documented in an OpenAPI 3 spec for the operation the request matches,
+// with the documented status code and the body in the documented shape, without calling the next handler,
+// so that the clients validating the responses against the spec see realistic failures.
+// It is created by LoadOpenAPI or ParseOpenAPI.
+//
+// The response is chosen at random from the 4xx and 5xx responses of the operation, and the "default" response
+// is responded as 500. The body is the example of the media type if the spec has one, or generated from the schema.
+// If the request matches no operation or the operation has no error response, it responds 500 as Error does.
+type OpenAPIError struct {
+	// Statuses limits the responses to the ones of these status codes. If empty, every error response is used.
+	Statuses []int
+
+	servers    []string
+	operations []*openAPIOperation
+}
+
+// openAPIOperation is an operation in the spec with its error responses.
+type openAPIOperation struct {
+	method    string
+	segments  []string
+	responses []openAPIResponse
+}
+
+// openAPIResponse is a prepared error response.
+type openAPIResponse struct {
+	code        int
+	contentType string
+	body        []byte
+}
+
+// Handle responds an error response of the matched operation.
+func (f *OpenAPIError) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	var candidates []openAPIResponse
+	if op := f.match(r); op != nil {
+		for _, resp := range op.responses {
+			if f.allowed(resp.code) {
+				candidates = append(candidates, resp)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("fault: pseudo status text is injected"))
+		return
+	}
+
+	resp := candidates[randomIndex(len(candidates))]
+	if resp.contentType != "" {
+		w.Header().Set("Content-Type", resp.contentType)
+	}
+	w.WriteHeader(resp.code)
+	w.Write(resp.body)
+}
+
+// Validate checks Statuses are valid.
+func (f *OpenAPIError) Validate() error {
+	for _, code := range f.Statuses {
+		if err := validateStatus(code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *OpenAPIError) allowed(code int) bool {
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, s := range f.Statuses {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the operation of the request, trying the path as is and without the path of each server.
+func (f *OpenAPIError) match(r *http.Request) *openAPIOperation {
+	paths := []string{r.URL.Path}
+	for _, s := range f.servers {
+		if p := strings.TrimPrefix(r.URL.Path, s); p != r.URL.Path && (p == "" || p[0] == '/') {
+			paths = append(paths, p)
+		}
+	}
+
+	for _, p := range paths {
+		segments := strings.Split(strings.Trim(p, "/"), "/")
+		for _, op := range f.operations {
+			if op.method == r.Method && matchSegments(op.segments, segments) {
+				return op
+			}
+		}
+	}
+	return nil
+}
+
+// matchSegments reports whether the path segments match the ones of a path template, such as "/users/{id}".
+func matchSegments(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if t != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadOpenAPI reads the OpenAPI spec from the file at the given path, see ParseOpenAPI.
+func LoadOpenAPI(path string) (*OpenAPIError, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseOpenAPI(b)
+}
+
+// ParseOpenAPI parses the OpenAPI 3 spec in JSON, and returns the OpenAPIError responding its error responses.
+// The spec in YAML must be converted to JSON beforehand.
+// The references to the schemas and the responses in the components of the spec are resolved.
+func ParseOpenAPI(b []byte) (*OpenAPIError, error) {
+	f, err := parseOpenAPI(b)
+	if err != nil {
+		return nil, fmt.Errorf("fault: parse openapi: %w", err)
+	}
+	return f, nil
+}
+
+// openAPIDoc is the part of the OpenAPI document used to build the responses.
+type openAPIDoc struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas   map[string]*openAPISchema       `json:"schemas"`
+		Responses map[string]*openAPIResponseSpec `json:"responses"`
+	} `json:"components"`
+}
+
+type openAPIOperationSpec struct {
+	Responses map[string]*openAPIResponseSpec `json:"responses"`
+}
+
+type openAPIResponseSpec struct {
+	Ref         string                           `json:"$ref"`
+	Description string                           `json:"description"`
+	Content     map[string]*openAPIMediaTypeSpec `json:"content"`
+}
+
+type openAPIMediaTypeSpec struct {
+	Schema   *openAPISchema  `json:"schema"`
+	Example  json.RawMessage `json:"example"`
+	Examples map[string]struct {
+		Value json.RawMessage `json:"value"`
+	} `json:"examples"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       json.RawMessage           `json:"type"`
+	Format     string                    `json:"format"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	Items      *openAPISchema            `json:"items"`
+	AllOf      []*openAPISchema          `json:"allOf"`
+	OneOf      []*openAPISchema          `json:"oneOf"`
+	AnyOf      []*openAPISchema          `json:"anyOf"`
+	Enum       []json.RawMessage         `json:"enum"`
+	Example    json.RawMessage           `json:"example"`
+	Default    json.RawMessage           `json:"default"`
+}
+
+// openAPIMethods are the operations of a path item.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maxRefDepth limits the chain of the references to the responses, against the circular references.
+const maxRefDepth = 8
+
+func parseOpenAPI(b []byte) (*OpenAPIError, error) {
+	var doc openAPIDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	f := &OpenAPIError{}
+	for _, s := range doc.Servers {
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			return nil, fmt.Errorf("server %s: %w", strconv.Quote(s.URL), err)
+		}
+		if p := strings.TrimSuffix(u.Path, "/"); p != "" {
+			f.servers = append(f.servers, p)
+		}
+	}
+
+	// The paths are sorted so that the same spec always matches in the same way.
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		item := doc.Paths[p]
+		for _, m := range openAPIMethods {
+			raw, ok := item[m]
+			if !ok {
+				continue
+			}
+			var spec openAPIOperationSpec
+			if err := json.Unmarshal(raw, &spec); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", m, p, err)
+			}
+			op := &openAPIOperation{method: strings.ToUpper(m), segments: strings.Split(strings.Trim(p, "/"), "/")}
+			for key, resp := range spec.Responses {
+				code, ok := openAPIStatus(key)
+				if !ok {
+					continue
+				}
+				r, err := doc.response(code, resp)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: response %s: %w", m, p, key, err)
+				}
+				op.responses = append(op.responses, r)
+			}
+			sort.Slice(op.responses, func(i, j int) bool { return op.responses[i].code < op.responses[j].code })
+			f.operations = append(f.operations, op)
+		}
+	}
+	return f, nil
+}
+
+// openAPIStatus returns the status code of the key of an error response: "404", "4XX" as 400, and "default" as 500.
+func openAPIStatus(key string) (int, bool) {
+	switch {
+	case key == "default":
+		return http.StatusInternalServerError, true
+	case len(key) == 3 && (key[0] == '4' || key[0] == '5') && strings.EqualFold(key[1:], "XX"):
+		return int(key[0]-'0') * 100, true
+	}
+	code, err := strconv.Atoi(key)
+	if err != nil || code < 400 || code > 599 {
+		return 0, false
+	}
+	return code, true
+}
+
+// response prepares the response of the spec.
+func (doc *openAPIDoc) response(code int, spec *openAPIResponseSpec) (openAPIResponse, error) {
+	for i := 0; spec != nil && spec.Ref != ""; i++ {
+		if i >= maxRefDepth {
+			return openAPIResponse{}, fmt.Errorf("too deep reference %s", strconv.Quote(spec.Ref))
+		}
+		name := strings.TrimPrefix(spec.Ref, "#/components/responses/")
+		resolved, ok := doc.Components.Responses[name]
+		if name == spec.Ref || !ok {
+			return openAPIResponse{}, fmt.Errorf("unresolved reference %s", strconv.Quote(spec.Ref))
+		}
+		spec = resolved
+	}
+
+	r := openAPIResponse{code: code}
+	if spec == nil || len(spec.Content) == 0 {
+		return r, nil
+	}
+
+	// JSON is preferred since the clients validating against the spec mostly speak it.
+	types := make([]string, 0, len(spec.Content))
+	for t := range spec.Content {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		ji, jj := isJSONMediaType(types[i]), isJSONMediaType(types[j])
+		if ji != jj {
+			return ji
+		}
+		return types[i] < types[j]
+	})
+	r.contentType = types[0]
+	mt := spec.Content[r.contentType]
+
+	var example json.RawMessage
+	switch {
+	case mt == nil:
+	case len(mt.Example) > 0:
+		example = mt.Example
+	case len(mt.Examples) > 0:
+		names := make([]string, 0, len(mt.Examples))
+		for n := range mt.Examples {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		example = mt.Examples[names[0]].Value
+	case mt.Schema != nil:
+		v, err := doc.generate(mt.Schema, map[string]bool{})
+		if err != nil {
+			return openAPIResponse{}, err
+		}
+		if example, err = json.Marshal(v); err != nil {
+			return openAPIResponse{}, err
+		}
+	}
+
+	if !isJSONMediaType(r.contentType) {
+		// A string example of a non-JSON media type is the body itself.
+		var s string
+		if json.Unmarshal(example, &s) == nil {
+			r.body = []byte(s)
+			return r, nil
+		}
+	}
+	r.body = example
+	return r, nil
+}
+
+func isJSONMediaType(t string) bool {
+	return t == "application/json" || strings.HasSuffix(t, "+json")
+}
+
+// generate returns a value conforming to the schema.
+// refs are the schemas being generated, so that a recursive schema is generated as null or an empty array.
+func (doc *openAPIDoc) generate(s *openAPISchema, refs map[string]bool) (interface{}, error) {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		resolved, ok := doc.Components.Schemas[name]
+		if name == s.Ref || !ok {
+			return nil, fmt.Errorf("unresolved reference %s", strconv.Quote(s.Ref))
+		}
+		if refs[name] {
+			return nil, nil
+		}
+		refs[name] = true
+		defer delete(refs, name)
+		return doc.generate(resolved, refs)
+	}
+
+	for _, raw := range []json.RawMessage{s.Example, s.Default} {
+		if len(raw) > 0 {
+			var v interface{}
+			err := json.Unmarshal(raw, &v)
+			return v, err
+		}
+	}
+	if len(s.Enum) > 0 {
+		var v interface{}
+		err := json.Unmarshal(s.Enum[0], &v)
+		return v, err
+	}
+	if len(s.AllOf) > 0 {
+		merged := map[string]interface{}{}
+		for _, sub := range s.AllOf {
+			v, err := doc.generate(sub, refs)
+			if err != nil {
+				return nil, err
+			}
+			if m, ok := v.(map[string]interface{}); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged, nil
+	}
+	if len(s.OneOf) > 0 {
+		return doc.generate(s.OneOf[0], refs)
+	}
+	if len(s.AnyOf) > 0 {
+		return doc.generate(s.AnyOf[0], refs)
+	}
+
+	switch t := s.typ(); {
+	case t == "object" || t == "" && len(s.Properties) > 0:
+		m := map[string]interface{}{}
+		for k, p := range s.Properties {
+			v, err := doc.generate(p, refs)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case t == "array":
+		if s.Items == nil {
+			return []interface{}{}, nil
+		}
+		v, err := doc.generate(s.Items, refs)
+		if err != nil || v == nil {
+			return []interface{}{}, err
+		}
+		return []interface{}{v}, nil
+	case t == "string":
+		switch s.Format {
+		case "date-time":
+			return CurrentClock().Now().UTC().Format(time.RFC3339), nil
+		case "date":
+			return CurrentClock().Now().UTC().Format("2006-01-02"), nil
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000", nil
+		}
+		return "fault: pseudo error is injected", nil
+	case t == "integer" || t == "number":
+		return 0, nil
+	case t == "boolean":
+		return false, nil
+	}
+	return nil, nil
+}
+
+// typ returns the type of the schema. Of the types of OpenAPI 3.1, such as ["string", "null"],
+// the first one other than "null" is returned.
+func (s *openAPISchema) typ() string {
+	var t string
+	if json.Unmarshal(s.Type, &t) == nil {
+		return t
+	}
+	var ts []string
+	json.Unmarshal(s.Type, &ts)
+	for _, t := range ts {
+		if t != "null" {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package fault
+
+import (
+	"log"
+	"time"
+)
+
+// MaxOverhead is a performance budget of a Handler.
+// The overhead is the time the Handler spends to decide whether to inject, including Matchers, Sampler and Hooks;
+// the time spent by the fault itself is not included.
+// When the overhead of a request exceeds the Limit, it is logged, and optionally the Handler is disabled,
+// so that the fault injection never becomes a bottleneck of latency-critical services.
+type MaxOverhead struct {
+	// Limit is the allowed overhead per request. Zero means no limit.
+	Limit time.Duration
+	// Disable makes the Handler disabled once the Limit is exceeded. Call Handler.Enable to resume it.
+	// If false, the violation is only logged.
+	Disable bool
+	// Logf logs the violation. If nil, log.Printf is used.
+	Logf func(format string, args ...interface{})
+}
+
+func (m *MaxOverhead) check(h *Handler, d time.Duration) {
+	if m.Limit <= 0 || d <= m.Limit {
+		return
+	}
+
+	logf := m.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+
+	if m.Disable {
+		if h.Disabled() {
+			return
+		}
+		h.Disable()
+		logf("fault: handler %q took %v (limit %v) to decide, disabled", h.Name, d, m.Limit)
+		return
+	}
+
+	logf("fault: handler %q took %v (limit %v) to decide", h.Name, d, m.Limit)
+}
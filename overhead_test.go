@@ -0,0 +1,119 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// nop is a fault doing nothing but calling next, so that the benchmarks measure the Handler itself.
+var nop = FaultFunc(func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	next.ServeHTTP(w, r)
+})
+
+// benchmarkHandler returns a Handler with Matchers, a Sampler and a Hook, as a typical experiment has.
+func benchmarkHandler(ratio float64) *Handler {
+	h := New(nop, ratio)
+	h.Matchers = []Matcher{Query("chaos", ""), Cookie("session", "")}
+	h.Sampler = &CounterSampler{}
+	if ratio == 0 {
+		h.Sampler = SamplerFunc(func(*http.Request, float64) bool { return false })
+	}
+	h.Hooks = []Hook{func(Outcome) {}}
+	return h
+}
+
+func benchmarkRequest() *http.Request {
+	r := httptest.NewRequest("GET", "/users/1?chaos=1", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	return r
+}
+
+func BenchmarkHandlerEvaluate(b *testing.B) {
+	for _, tc := range []struct {
+		name  string
+		ratio float64
+	}{
+		{"not_injected", 0},
+		{"injected", 1},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			h := benchmarkHandler(tc.ratio)
+			r := benchmarkRequest()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.Evaluate(r)
+			}
+		})
+	}
+}
+
+func BenchmarkHandlerServeHTTP(b *testing.B) {
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, tc := range []struct {
+		name  string
+		ratio float64
+	}{
+		{"not_injected", 0},
+		{"injected", 1},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			hh := benchmarkHandler(tc.ratio).Handler(next)
+			r := benchmarkRequest()
+			w := httptest.NewRecorder()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				hh.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func TestMaxOverhead(t *testing.T) {
+	// The overhead is measured in the real time even if the tests fake the Clock.
+	SetClock(NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	defer SetClock(nil)
+
+	for _, tc := range []struct {
+		name     string
+		took     time.Duration
+		limit    time.Duration
+		disable  bool
+		logged   bool
+		disabled bool
+	}{
+		{"under limit", 0, time.Minute, true, false, false},
+		{"over limit", 20 * time.Millisecond, 5 * time.Millisecond, false, true, false},
+		{"over limit disables", 20 * time.Millisecond, 5 * time.Millisecond, true, true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs []string
+			h := New(nop, 1)
+			h.Name = "test"
+			// A slow Matcher makes the decision take long.
+			h.Matchers = []Matcher{MatcherFunc(func(*http.Request) bool {
+				time.Sleep(tc.took)
+				return true
+			})}
+			h.MaxOverhead = &MaxOverhead{
+				Limit:   tc.limit,
+				Disable: tc.disable,
+				Logf: func(format string, args ...interface{}) {
+					logs = append(logs, fmt.Sprintf(format, args...))
+				},
+			}
+
+			h.Evaluate(httptest.NewRequest("GET", "/", nil))
+			if got := len(logs) > 0; got != tc.logged {
+				t.Errorf("logged = %v, want %v: %q", got, tc.logged, logs)
+			}
+			if got := h.Disabled(); got != tc.disabled {
+				t.Errorf("Disabled() = %v, want %v", got, tc.disabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package fault
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Override lets a single request force a fault by a request header, regardless of the Matchers and the Sampler.
+// This enables targeted reproduction from integration tests without changing the ratio.
+//
+// The header value is a semicolon-separated list of effects, which are applied in order:
+//
+//	X-Fault-Inject: delay=2s;error=503
+//	X-Fault-Inject: delay_with_error(duration=1s,status=502,text=oops)
+//	X-Fault-Inject: abort
+//
+// The shorthand "effect=value" sets the primary parameter of the built-in effects:
+// duration for delay and delay_with_abort, status for error, limit for rate_limit and max for concurrency.
+// Other effects can be given in the "effect(key=value,...)" form.
+//
+// Since anyone who can send the header can inject a fault, an override is honored only if
+// Secret or Allow is set and the request passes them, and only the effects in Effects can be requested.
+// If the header cannot be parsed, the request is responded 400 so that the mistake is noticed.
+// The response does not tell the details; they are given to the Hooks as Outcome.Err.
+type Override struct {
+	// Header is the name of the header describing the fault. If empty, "X-Fault-Inject" is used.
+	Header string
+	// Secret is a shared secret which must be sent in the SecretHeader.
+	Secret string
+	// SecretHeader is the name of the header carrying the Secret. If empty, "X-Fault-Secret" is used.
+	SecretHeader string
+	// Allow is called to check whether the request is allowed to override, such as by the client IP.
+	Allow func(r *http.Request) bool
+	// Effects are the names of the effects which can be requested. If empty, DefaultOverrideEffects is used.
+	Effects []string
+}
+
+// DefaultOverrideEffects are the built-in effects which can be requested by Override by default.
+// The effects reading files or calling other servers, such as mock and openapi, are not included,
+// since the parameters come from the requests.
+var DefaultOverrideEffects = []string{
+	"abort", "auth_failure", "bad_compression", "bad_conditional", "bad_cors", "bad_expect", "bad_trailer",
+	"baseline_delay", "circuit_breaker", "clock_skew", "concurrency", "connect_error", "content_length_mismatch",
+	"cpu", "delay", "delay_with_abort", "delay_with_error", "empty_success", "error", "fail_first", "fuzzed_body",
+	"graphql_error", "grpc_error", "hang", "large_body", "large_header", "latency_amplification", "load_shed",
+	"malformed_chunked", "partial_response", "rate_limit", "session_expiry", "slow_upload", "sse",
+	"status_distribution", "transport_error",
+}
+
+// fault returns the fault requested by the header, or nil if the request does not override.
+func (o *Override) fault(r *http.Request) (Fault, error) {
+	header := o.Header
+	if header == "" {
+		header = "X-Fault-Inject"
+	}
+	v := r.Header.Get(header)
+	if v == "" {
+		return nil, nil
+	}
+
+	if o.Secret == "" && o.Allow == nil {
+		return nil, nil
+	}
+	if o.Secret != "" {
+		secretHeader := o.SecretHeader
+		if secretHeader == "" {
+			secretHeader = "X-Fault-Secret"
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(secretHeader)), []byte(o.Secret)) != 1 {
+			return nil, nil
+		}
+	}
+	if o.Allow != nil && !o.Allow(r) {
+		return nil, nil
+	}
+
+	effects := o.Effects
+	if len(effects) == 0 {
+		effects = DefaultOverrideEffects
+	}
+	return parseEffects(v, func(name string) bool {
+		for _, e := range effects {
+			if e == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// primaryParams is the parameter set by the "effect=value" shorthand.
+var primaryParams = map[string]string{
+	"delay":            "duration",
+	"delay_with_abort": "duration",
+	"error":            "status",
+	"rate_limit":       "limit",
+	"concurrency":      "max",
+}
+
+// ParseEffects builds a fault from the textual description used by Override, such as "delay=2s;error=503".
+// If several effects are described, they are applied in order.
+func ParseEffects(s string) (Fault, error) {
+	return parseEffects(s, func(string) bool { return true })
+}
+
+// parseEffects is ParseEffects accepting only the effects allowed.
+func parseEffects(s string, allowed func(name string) bool) (Fault, error) {
+	var fs []Fault
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		var name string
+		params := map[string]string{}
+		switch {
+		case strings.HasSuffix(item, ")") && strings.Contains(item, "("):
+			i := strings.Index(item, "(")
+			name = strings.TrimSpace(item[:i])
+			for _, kv := range strings.Split(item[i+1:len(item)-1], ",") {
+				kv = strings.TrimSpace(kv)
+				if kv == "" {
+					continue
+				}
+				j := strings.Index(kv, "=")
+				if j < 0 {
+					return nil, fmt.Errorf("fault: invalid parameter %q in %q", kv, item)
+				}
+				params[strings.TrimSpace(kv[:j])] = strings.TrimSpace(kv[j+1:])
+			}
+		case strings.Contains(item, "="):
+			i := strings.Index(item, "=")
+			name = strings.TrimSpace(item[:i])
+			p, ok := primaryParams[name]
+			if !ok {
+				return nil, fmt.Errorf("fault: effect %q has no shorthand, use %s(key=value)", name, name)
+			}
+			params[p] = strings.TrimSpace(item[i+1:])
+		default:
+			name = item
+		}
+
+		if !allowed(name) {
+			return nil, fmt.Errorf("fault: effect %q is not allowed", name)
+		}
+		e, ok := LookupEffect(name)
+		if !ok {
+			return nil, fmt.Errorf("fault: unknown effect %q", name)
+		}
+		f, err := e(params)
+		if err == nil {
+			err = validateFault(f)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fault: %s: %w", name, err)
+		}
+		fs = append(fs, f)
+	}
+
+	switch len(fs) {
+	case 0:
+		return nil, fmt.Errorf("fault: no effect in %q", s)
+	case 1:
+		return fs[0], nil
+	default:
+		return sequence(fs), nil
+	}
+}
+
+// sequence applies the faults in order.
+type sequence []Fault
+
+// Handle applies the first fault, with the rest applied to next of it.
+func (s sequence) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if len(s) == 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+	s[0].Handle(w, r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s[1:].Handle(w, r, next)
+	}))
+}
+
+// Validate validates every fault in the sequence.
+func (s sequence) Validate() error {
+	for _, f := range s {
+		if err := validateFault(f); err != nil {
+			return fmt.Errorf("%s: %w", FaultName(f), err)
+		}
+	}
+	return nil
+}
+
+// Name joins the names of the faults with "+".
+func (s sequence) Name() string {
+	names := make([]string, len(s))
+	for i, f := range s {
+		names[i] = FaultName(f)
+	}
+	return strings.Join(names, "+")
+}
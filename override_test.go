@@ -0,0 +1,68 @@
+package fault
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOverride(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		effects []string
+		header  string
+		status  int
+		body    string
+		err     string
+	}{
+		{"shorthand", nil, "error=503", 503, "", ""},
+		{"call form", nil, "delay_with_error(duration=1ms,status=502,text=oops)", 502, "oops", ""},
+		{"file effect", nil, "mock(file=/etc/passwd)", 400, "invalid fault override", `effect "mock" is not allowed`},
+		{"not in effects", []string{"delay"}, "error=503", 400, "invalid fault override", `effect "error" is not allowed`},
+		{"invalid", nil, "error=abc", 400, "invalid fault override", "error"},
+		{"unknown", []string{"nope"}, "nope", 400, "invalid fault override", `unknown effect "nope"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var outcome Outcome
+			h := New(nop, 0)
+			h.Override = &Override{Secret: "s", Effects: tc.effects}
+			h.Hooks = []Hook{func(o Outcome) { outcome = o }}
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "ok") })
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-Fault-Inject", tc.header)
+			r.Header.Set("X-Fault-Secret", "s")
+			w := httptest.NewRecorder()
+			h.Handler(next).ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Errorf("status = %d, want %d", w.Code, tc.status)
+			}
+			if tc.body != "" && !strings.Contains(w.Body.String(), tc.body) {
+				t.Errorf("body = %q, want %q", w.Body.String(), tc.body)
+			}
+			if strings.Contains(w.Body.String(), "/etc/passwd") {
+				t.Errorf("body = %q, must not tell the details", w.Body.String())
+			}
+			switch {
+			case tc.err == "" && outcome.Err != nil:
+				t.Errorf("Outcome.Err = %v, want nil", outcome.Err)
+			case tc.err != "" && (outcome.Err == nil || !strings.Contains(outcome.Err.Error(), tc.err)):
+				t.Errorf("Outcome.Err = %v, want %q", outcome.Err, tc.err)
+			}
+		})
+	}
+}
+
+func TestOverrideNotAllowed(t *testing.T) {
+	h := New(nop, 0)
+	h.Override = &Override{Secret: "s"}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Fault-Inject", "error=503")
+	r.Header.Set("X-Fault-Secret", "wrong")
+	if o := h.Evaluate(r); o.Decision != Pass {
+		t.Errorf("Decision = %v, want pass", o.Decision)
+	}
+}
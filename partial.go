@@ -0,0 +1,54 @@
+package fault
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// PartialResponse sends the status line, the headers and a part of the body of the response of the next handler,
+// then aborts the connection, so that the clients can be tested to tell a truncated success from a failure.
+// The Content-Length is set to the length of the whole body, so the clients can notice the truncation.
+// If the body is empty, the connection is aborted before the headers are sent, as no part of it can be
+// withheld and the response would be complete otherwise.
+type PartialResponse struct {
+	// Fraction is the fraction of the body sent before the abort, in [0, 1). If zero, only the headers are sent.
+	Fraction float64
+}
+
+// Handle proxies the request to next, then sends a part of the response.
+func (f *PartialResponse) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	buf := newResponseBuffer()
+	next.ServeHTTP(buf, r)
+
+	body := buf.body.Bytes()
+	if len(body) == 0 {
+		panic(http.ErrAbortHandler)
+	}
+	n := int(float64(len(body)) * f.Fraction)
+	if n >= len(body) {
+		n = len(body) - 1
+	}
+
+	h := w.Header()
+	for k, vs := range buf.header {
+		h[k] = vs
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(buf.code)
+	w.Write(body[:n])
+	if fl, ok := w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	// https://pkg.go.dev/net/http#Handler
+	panic(http.ErrAbortHandler)
+}
+
+// Validate checks Fraction is in [0, 1).
+func (f *PartialResponse) Validate() error {
+	if math.IsNaN(f.Fraction) || f.Fraction < 0 || f.Fraction >= 1 {
+		return fmt.Errorf("fraction %v must be in [0, 1)", f.Fraction)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package fault
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestPartialResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		fraction float64
+		header   bool
+		sent     string
+	}{
+		{"half", "0123456789", 0.5, true, "01234"},
+		{"headers only", "0123456789", 0, true, ""},
+		{"one byte", "0", 0.9, true, ""},
+		{"empty", "", 0.5, false, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Next", "1")
+				io.WriteString(w, tc.body)
+			})
+			w := httptest.NewRecorder()
+			func() {
+				defer func() {
+					if v := recover(); v != http.ErrAbortHandler {
+						t.Errorf("recovered %v, want http.ErrAbortHandler", v)
+					}
+				}()
+				(&PartialResponse{Fraction: tc.fraction}).Handle(w, httptest.NewRequest("GET", "/", nil), next)
+			}()
+
+			if got := w.Header().Get("X-Next") != ""; got != tc.header {
+				t.Errorf("headers sent = %v, want %v", got, tc.header)
+			}
+			if got := w.Body.String(); got != tc.sent {
+				t.Errorf("body = %q, want %q", got, tc.sent)
+			}
+			if tc.header && w.Header().Get("Content-Length") != strconv.Itoa(len(tc.body)) {
+				t.Errorf("Content-Length = %q, want %d", w.Header().Get("Content-Length"), len(tc.body))
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package fault
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// peekedBody is a request body read by peekBody, which replays the read part before the rest.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+	peeked []byte
+	// whole reports whether peeked is the whole body.
+	whole bool
+}
+
+// peekBody reads the body of the request up to max bytes, and replaces the body so that the next handler reads it
+// as it is. It reports false if the body is larger than max or cannot be read.
+// Once the whole body is read, peeking the same request again does not read it again.
+func peekBody(r *http.Request, max int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if pb, ok := r.Body.(*peekedBody); ok && pb.whole {
+		return pb.peeked, int64(len(pb.peeked)) <= max
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	whole := err == nil && int64(len(b)) <= max
+	r.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(b), r.Body), Closer: r.Body, peeked: b, whole: whole}
+	return b, whole
+}
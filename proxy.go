@@ -0,0 +1,41 @@
+package fault
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ReverseProxy is a reverse proxy wired with faults on both sides, for sidecar-style deployment.
+// In is applied to the incoming requests before they are proxied, and Out is applied to the outgoing requests
+// to the upstream in the Transport; e.g. an Abort in In closes the connection to the client,
+// while an Abort in Out makes the proxy respond 502 as if the upstream closed the connection.
+type ReverseProxy struct {
+	// Proxy is the underlying proxy. It can be customized before serving requests.
+	Proxy *httputil.ReverseProxy
+	// In is applied to the incoming requests. If nil, no fault is injected.
+	In Middleware
+}
+
+// NewReverseProxy returns a ReverseProxy to the target. Either in or out can be nil.
+// The proxy flushes the response to the client immediately, so that streaming responses
+// and the faults writing partial responses pass through as they are.
+func NewReverseProxy(target *url.URL, in Middleware, out Chain) *ReverseProxy {
+	p := httputil.NewSingleHostReverseProxy(target)
+	p.FlushInterval = -1
+	if len(out) > 0 {
+		p.Transport = out.Transport(nil)
+	}
+
+	return &ReverseProxy{Proxy: p, In: in}
+}
+
+// ServeHTTP implements http.Handler.
+func (p *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.In == nil {
+		p.Proxy.ServeHTTP(w, r)
+		return
+	}
+
+	p.In.Handler(p.Proxy).ServeHTTP(w, r)
+}
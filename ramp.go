@@ -0,0 +1,38 @@
+package fault
+
+import "time"
+
+// Ramp changes the injection ratio gradually, so that an experiment starts gently instead of hitting full blast instantly.
+// The ratio increases linearly from 0 to the configured ratio over Up, stays there for Hold,
+// then optionally decreases linearly to 0 over Down. The ramp starts when the Handler starts.
+type Ramp struct {
+	// Up is the period the ratio increases over.
+	Up time.Duration
+	// Hold is the period the ratio stays at the configured ratio after Up.
+	// It is used only if Down is non-zero.
+	Hold time.Duration
+	// Down is the period the ratio decreases over after Hold. If zero, the ratio never decreases.
+	Down time.Duration
+}
+
+// factor returns the multiplier of the ratio at the elapsed time since the start, in [0, 1].
+func (r *Ramp) factor(elapsed time.Duration) float64 {
+	if elapsed < 0 {
+		return 0
+	}
+	if elapsed < r.Up {
+		return float64(elapsed) / float64(r.Up)
+	}
+	if r.Down <= 0 {
+		return 1
+	}
+
+	elapsed -= r.Up + r.Hold
+	if elapsed < 0 {
+		return 1
+	}
+	if elapsed < r.Down {
+		return 1 - float64(elapsed)/float64(r.Down)
+	}
+	return 0
+}
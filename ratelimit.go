@@ -0,0 +1,65 @@
+package fault
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit responds 429 Too Many Requests in the same manner as a rate limited API does.
+// In addition to the status code, it sets Retry-After and X-RateLimit-Limit/Remaining/Reset headers,
+// so the client's backoff logic can be validated against realistic responses.
+// There should be no actual server call.
+type RateLimit struct {
+	// Limit is the number of requests allowed in a window, reported in X-RateLimit-Limit.
+	// If zero, 60 is used.
+	Limit int
+	// Window is the length of the rate limit window. Windows are aligned to the wall clock
+	// and the reset time is the end of the current window. If zero, 1 minute is used.
+	Window time.Duration
+	// ResetAfter changes X-RateLimit-Reset from the Unix time of the reset
+	// to the number of seconds until the reset.
+	ResetAfter bool
+	// StatusText is used as HTTP response body. Optional but if empty, a placeholder message is used.
+	StatusText string
+}
+
+// Handle responds 429 to the given handler.
+func (f *RateLimit) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	limit := f.Limit
+	if limit == 0 {
+		limit = 60
+	}
+	window := f.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	statusText := f.StatusText
+	if statusText == "" {
+		statusText = "fault: pseudo rate limit is injected"
+	}
+
+	now := CurrentClock().Now()
+	reset := now.Truncate(window).Add(window)
+	// Round up so that the client never retries before the reset.
+	after := int((reset.Sub(now) + time.Second - 1) / time.Second)
+
+	w.Header().Set("Retry-After", strconv.Itoa(after))
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", "0")
+	if f.ResetAfter {
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(after))
+	} else {
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	}
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(statusText))
+}
+
+// Validate checks Limit and Window are not negative.
+func (f *RateLimit) Validate() error {
+	if err := validateCount("limit", f.Limit); err != nil {
+		return err
+	}
+	return validateDuration("window", f.Window)
+}
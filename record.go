@@ -0,0 +1,163 @@
+package fault
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Record is an injection decision recorded by a Recorder, which is written as a line of JSON.
+type Record struct {
+	// Time is when the decision was made.
+	Time time.Time `json:"time"`
+	// Request is the hash of the request, see RequestHash.
+	Request string `json:"request"`
+	// N is the order of the request among the ones of the same hash sampled by the Handler, starting from 0.
+	N int `json:"n"`
+	// Method and URL describe the request for humans reading the records.
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// RequestID is the ID of the request, see Handler.RequestIDHeader.
+	RequestID string `json:"request_id,omitempty"`
+	// Decision is "inject" or "dry_run".
+	Decision string `json:"decision"`
+	// Fault is the name of the injected fault, see FaultName.
+	Fault string `json:"fault"`
+	// Params is the fault encoded as JSON. It is omitted if the fault cannot be encoded, such as FaultFunc.
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// RequestHash returns the hash identifying the request by its method, host, path and query.
+// The headers and the body are not included, so the requests differing only in them have the same hash.
+func RequestHash(r *http.Request) string {
+	h := fnv.New64a()
+	for _, s := range []string{r.Method, r.Host, r.URL.Path, r.URL.RawQuery} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// Recorder records the injection decisions of a Handler, so that a failure found under random injection
+// can be reproduced by replaying them with a Replayer. Use its Hook as a Hook of the Handler:
+//
+//	f, _ := os.Create("decisions.jsonl")
+//	rec := fault.NewRecorder(f)
+//	h.Hooks = append(h.Hooks, rec.Hook)
+//
+// Only the requests sampled by the Sampler are recorded; the injections forced by Override and Envoy are not,
+// since the requests themselves reproduce them. A Recorder must be used by only one Handler.
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	counts map[string]int
+	err    error
+}
+
+// NewRecorder returns a Recorder writing the Records to w, one JSON per line.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w, counts: map[string]int{}}
+}
+
+// Hook records the Outcome if the request is injected or would have been injected in dry-run mode.
+func (rec *Recorder) Hook(o Outcome) {
+	switch o.Reason {
+	case "sampled", "unsampled", "budget exhausted":
+	default:
+		// The Sampler was not called for the request.
+		return
+	}
+
+	hash := RequestHash(o.Request)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	n := rec.counts[hash]
+	rec.counts[hash]++
+	if o.Reason != "sampled" || rec.err != nil {
+		return
+	}
+
+	r := Record{
+		Time:      o.Time,
+		Request:   hash,
+		N:         n,
+		Method:    o.Request.Method,
+		URL:       o.Request.URL.String(),
+		RequestID: o.RequestID,
+		Decision:  o.Decision.String(),
+		Fault:     FaultName(o.Fault),
+	}
+	if b, err := json.Marshal(o.Fault); err == nil {
+		r.Params = b
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		rec.err = err
+		return
+	}
+	_, rec.err = rec.w.Write(append(b, '\n'))
+}
+
+// Err returns the first error writing the Records. Once it fails, the Recorder stops recording.
+func (rec *Recorder) Err() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.err
+}
+
+// Replayer is a Sampler reapplying the decisions recorded by a Recorder: it selects exactly the requests
+// selected in the recorded run, identified by the hash and the order among the requests of the same hash.
+// The ratio is ignored. The requests must be sent in the same order as in the recorded run for the same result,
+// and the Handler must be configured in the same way, such as the Matchers and the Budget.
+//
+//	f, _ := os.Open("decisions.jsonl")
+//	h.Sampler, err = fault.NewReplayer(f)
+type Replayer struct {
+	mu       sync.Mutex
+	selected map[string]map[int]bool
+	counts   map[string]int
+}
+
+// NewReplayer returns a Replayer reading the Records from r.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	p := &Replayer{selected: map[string]map[int]bool{}, counts: map[string]int{}}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("fault: invalid record at line %d: %w", line, err)
+		}
+		if p.selected[rec.Request] == nil {
+			p.selected[rec.Request] = map[int]bool{}
+		}
+		p.selected[rec.Request][rec.N] = true
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("fault: read records: %w", err)
+	}
+
+	return p, nil
+}
+
+// Sample returns true if the request was selected in the recorded run.
+func (p *Replayer) Sample(r *http.Request, _ float64) bool {
+	if r == nil {
+		return false
+	}
+	hash := RequestHash(r)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.counts[hash]
+	p.counts[hash]++
+	return p.selected[hash][n]
+}
@@ -0,0 +1,136 @@
+package fault
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedisCounter is a Counter backed by Redis INCR.
+// It speaks the minimum of the Redis protocol by itself, so no Redis client library is required.
+type RedisCounter struct {
+	// Addr is the address of Redis such as "localhost:6379". Required.
+	Addr string
+	// Password is sent by AUTH if non-empty.
+	Password string
+	// DB is selected by SELECT if non-zero.
+	DB int
+	// MaxIdle is the number of idle connections kept. If zero, 4 is used.
+	MaxIdle int
+
+	once sync.Once
+	pool chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Incr implements Counter.
+func (c *RedisCounter) Incr(ctx context.Context, key string) (int64, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := conn.do(ctx, "INCR", key)
+	if err != nil {
+		conn.Close()
+		return 0, err
+	}
+	c.put(conn)
+
+	return n, nil
+}
+
+func (c *RedisCounter) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.idle():
+		return conn, nil
+	default:
+	}
+
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.Password != "" {
+		if _, err := conn.do(ctx, "AUTH", c.Password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.DB != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(c.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *RedisCounter) put(conn *redisConn) {
+	select {
+	case c.idle() <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// idle returns the pool of idle connections. The pool is lazily made since RedisCounter has no constructor.
+func (c *RedisCounter) idle() chan *redisConn {
+	c.once.Do(func() {
+		n := c.MaxIdle
+		if n <= 0 {
+			n = 4
+		}
+		c.pool = make(chan *redisConn, n)
+	})
+	return c.pool
+}
+
+// do sends the command and reads the reply. Only simple string, error and integer replies are supported.
+func (conn *redisConn) do(ctx context.Context, args ...string) (int64, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return 0, err
+	}
+
+	line, err := conn.r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return 0, fmt.Errorf("fault: redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return 0, nil
+	case '-':
+		return 0, fmt.Errorf("fault: redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	default:
+		return 0, fmt.Errorf("fault: redis: unexpected reply %q", line)
+	}
+}
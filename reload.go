@@ -0,0 +1,181 @@
+package fault
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader serves the faults described by a Config, and replaces them live when a new Config is applied.
+// Handlers are rebuilt on every Apply, but a Handler keeps its Stats, Budget consumption and creation time
+// (which TTL counts from) if the new config has a fault of the same name. It also keeps the count of
+// a CounterSampler if the new one is a CounterSampler too, and the ratio set by SetRatio unless the new config
+// changes the ratio; the other Samplers start over.
+// Whether a Handler is disabled follows the new config; Disable called at runtime does not survive a reload.
+type Reloader struct {
+	chain atomic.Value // Chain
+	mu    sync.Mutex
+}
+
+// NewReloader returns a Reloader serving the config.
+func NewReloader(c *Config) (*Reloader, error) {
+	r := &Reloader{}
+	if err := r.Apply(c); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Apply replaces the faults with the ones in the config.
+// If the config is invalid, the current faults are kept and an error is returned.
+func (r *Reloader) Apply(c *Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chain, err := c.Build()
+	if err != nil {
+		return err
+	}
+
+	old := map[string]*Handler{}
+	for _, h := range r.Chain() {
+		old[h.Name] = h
+	}
+	for _, h := range chain {
+		if o, ok := old[h.Name]; ok {
+			h.inherit(o)
+		}
+	}
+
+	r.chain.Store(chain)
+	return nil
+}
+
+// Chain returns the Handlers currently in effect.
+func (r *Reloader) Chain() Chain {
+	c, _ := r.chain.Load().(Chain)
+	return c
+}
+
+// Handler wraps the given handler with the faults in effect at the time of each request.
+func (r *Reloader) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.Chain().Handler(next).ServeHTTP(w, req)
+	})
+}
+
+// Snapshot returns the Snapshot of every Handler in effect.
+func (r *Reloader) Snapshot() []Snapshot {
+	return r.Chain().Snapshot()
+}
+
+// Stats returns the Stats of every Handler in effect.
+func (r *Reloader) Stats() map[string]Stats {
+	return r.Chain().Stats()
+}
+
+// inherit takes over the runtime state from the Handler being replaced.
+func (h *Handler) inherit(old *Handler) {
+	h.created = old.created
+	h.stats = old.stats
+	if h.Budget != nil && old.Budget != nil {
+		h.Budget.inherit(old.Budget)
+	}
+	if s, ok := h.Sampler.(*CounterSampler); ok {
+		if o, ok := old.Sampler.(*CounterSampler); ok {
+			atomic.StoreUint64(&s.n, uint64(o.Count()))
+		}
+	}
+	if atomic.LoadUint32(&old.ratioSet) == 1 && h.RandomRatio == old.RandomRatio {
+		h.SetRatio(old.Ratio())
+	}
+}
+
+// inherit takes over the consumption from the Budget being replaced.
+func (b *Budget) inherit(old *Budget) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.windowStart = old.windowStart
+	b.windowCount = old.windowCount
+	b.total = old.total
+}
+
+// WatchConfig loads the config file at the path, and watches it to apply changes live until ctx is done.
+// The file is checked every interval; if interval is zero, 5 seconds is used.
+// If the changed file is invalid, the current faults are kept. Such errors and the config warnings are reported by logf;
+// if logf is nil, log.Printf is used.
+func WatchConfig(ctx context.Context, path string, interval time.Duration, logf func(format string, args ...interface{})) (*Reloader, error) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	if logf == nil {
+		logf = log.Printf
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReloader(path, b, logf)
+	if err != nil {
+		return nil, err
+	}
+
+	go poll(ctx, interval, func() {
+		nb, err := os.ReadFile(path)
+		if err != nil {
+			logf("fault: reload %s: %v", path, err)
+			return
+		}
+		if bytes.Equal(nb, b) {
+			return
+		}
+		b = nb
+		r.reload(path, b, logf)
+	})
+
+	return r, nil
+}
+
+// newReloader returns a Reloader serving the config document read from the source.
+func newReloader(source string, b []byte, logf func(format string, args ...interface{})) (*Reloader, error) {
+	c, err := ParseConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range c.Warnings {
+		logf("fault: %s: %s", source, w)
+	}
+
+	return NewReloader(c)
+}
+
+// reload applies the config document read from the source. Errors are reported by logf.
+func (r *Reloader) reload(source string, b []byte, logf func(format string, args ...interface{})) {
+	c, err := ParseConfig(b)
+	if err != nil {
+		logf("fault: reload %s: %v", source, err)
+		return
+	}
+	for _, w := range c.Warnings {
+		logf("fault: %s: %s", source, w)
+	}
+	if err := r.Apply(c); err != nil {
+		logf("fault: reload %s: %v", source, err)
+	}
+}
+
+// poll calls f every interval until ctx is done.
+func poll(ctx context.Context, interval time.Duration, f func()) {
+	for sleepContext(ctx, interval) == nil {
+		f()
+	}
+}
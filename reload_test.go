@@ -0,0 +1,50 @@
+package fault
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReloaderInherit(t *testing.T) {
+	parse := func(s string) *Config {
+		t.Helper()
+		c, err := ParseConfig([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	const counter = `{"faults": [{"name": "a", "effect": "delay", "params": {"duration": "1ms"}, "percentage": 50,
+		"sampler": "counter", "sampler_params": {"every": "2"}}]}`
+
+	r, err := NewReloader(parse(counter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := r.Chain()[0]
+	h.Evaluate(httptest.NewRequest("GET", "/", nil))
+	h.SetRatio(1)
+
+	// The same config keeps the count and the ratio.
+	if err := r.Apply(parse(counter)); err != nil {
+		t.Fatal(err)
+	}
+	h = r.Chain()[0]
+	if got := h.Sampler.(*CounterSampler).Count(); got != 1 {
+		t.Errorf("Count() = %d, want 1", got)
+	}
+	if got := h.Ratio(); got != 1 {
+		t.Errorf("Ratio() = %v, want 1 set before the reload", got)
+	}
+	if o := h.Evaluate(httptest.NewRequest("GET", "/", nil)); o.Decision != Inject {
+		t.Errorf("second request = %v (%s), want inject as the counter goes on", o.Decision, o.Reason)
+	}
+
+	// The config changing the ratio takes precedence over SetRatio.
+	if err := r.Apply(parse(`{"faults": [{"name": "a", "effect": "delay", "params": {"duration": "1ms"}, "percentage": 10}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Chain()[0].Ratio(); got != 0.1 {
+		t.Errorf("Ratio() = %v, want 0.1 of the new config", got)
+	}
+}
@@ -0,0 +1,111 @@
+package fault
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RemoteConfig fetches the config from an HTTP(S) endpoint periodically,
+// so that a central chaos controller can push experiment changes to a fleet of services.
+// The ETag of the response is sent back in If-None-Match, and 304 Not Modified is treated as no change.
+type RemoteConfig struct {
+	// URL is the endpoint serving the config in JSON. Required.
+	URL string
+	// Header is added to every request, such as Authorization.
+	Header http.Header
+	// Client is used to fetch the config. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Interval is how often the config is fetched. If zero, 30 seconds is used.
+	Interval time.Duration
+	// Logf reports the errors on polling and the config warnings. If nil, log.Printf is used.
+	Logf func(format string, args ...interface{})
+}
+
+// Poll fetches the config and returns a Reloader serving it, then keeps polling until ctx is done.
+// If the first fetch fails, an error is returned. Later failures are logged and the current faults are kept.
+func (rc *RemoteConfig) Poll(ctx context.Context) (*Reloader, error) {
+	interval := rc.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	logf := rc.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+
+	b, etag, err := rc.fetch(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReloader(rc.URL, b, logf)
+	if err != nil {
+		return nil, err
+	}
+
+	go poll(ctx, interval, func() {
+		nb, netag, err := rc.fetch(ctx, etag)
+		if err != nil {
+			if ctx.Err() == nil {
+				logf("fault: reload %s: %v", rc.URL, err)
+			}
+			return
+		}
+		if nb == nil {
+			return
+		}
+		// The ETag is kept even if the body is the same, so that the server can answer the next fetch with 304.
+		etag = netag
+		if bytes.Equal(nb, b) {
+			return
+		}
+		b = nb
+		r.reload(rc.URL, b, logf)
+	})
+
+	return r, nil
+}
+
+// fetch gets the config. It returns nil body if the server responds 304 Not Modified.
+func (rc *RemoteConfig) fetch(ctx context.Context, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rc.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for k, vs := range rc.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	client := rc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		return b, resp.Header.Get("ETag"), nil
+	case http.StatusNotModified:
+		return nil, etag, nil
+	default:
+		return nil, "", fmt.Errorf("fault: fetch config: unexpected status %s", resp.Status)
+	}
+}
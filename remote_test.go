@@ -0,0 +1,44 @@
+package fault
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRemoteConfigETag(t *testing.T) {
+	clk := NewFakeClock(epoch)
+	SetClock(clk)
+	defer SetClock(nil)
+
+	// The server changes the ETag but not the body, such as after a redeploy.
+	matches := make(chan string, 10)
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		matches <- r.Header.Get("If-None-Match")
+		fetches++
+		w.Header().Set("ETag", strconv.Quote("v"+strconv.Itoa(fetches)))
+		io.WriteString(w, `{"faults": [{"name": "slow", "effect": "delay", "params": {"duration": "1s"}, "percentage": 25}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rc := &RemoteConfig{URL: srv.URL, Interval: time.Second}
+	if _, err := rc.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-matches
+
+	for _, want := range []string{`"v1"`, `"v2"`} {
+		waitSleepers(t, clk, 1)
+		clk.Add(time.Second)
+		if got := <-matches; got != want {
+			t.Errorf("If-None-Match = %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package fault
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Report is the record of a run of a Scenario, so that the evidence of an experiment can be attached to its ticket.
+// It is encoded as JSON by encoding/json. The durations are in nanoseconds.
+type Report struct {
+	// Scenario is the name of the Scenario.
+	Scenario string `json:"scenario"`
+	// Start is when the run started.
+	Start time.Time `json:"start"`
+	// End is when the run ended. Zero if it is running.
+	End time.Time `json:"end"`
+	// Error is why the run stopped before the last phase ends, such as the violation of the steady state.
+	Error string `json:"error,omitempty"`
+	// Phases are the phases run so far.
+	Phases []PhaseReport `json:"phases"`
+}
+
+// PhaseReport is the record of a phase in a Report.
+type PhaseReport struct {
+	// Name is the name of the phase.
+	Name string `json:"name"`
+	// Fault is the name of the fault, see FaultName. Empty if the phase has no Handler.
+	Fault string `json:"fault,omitempty"`
+	// Ratio is the ratio of the Handler when the phase ended, or at the moment if it is running.
+	Ratio float64 `json:"ratio"`
+	// Start is when the phase started.
+	Start time.Time `json:"start"`
+	// End is when the phase ended. Zero if it is running.
+	End time.Time `json:"end"`
+	// Requests is the number of requests served by Scenario.Handler in the phase.
+	Requests int `json:"requests"`
+	// Injected is the number of requests the fault was injected into.
+	Injected int `json:"injected"`
+	// Statuses is the number of responses per status code, such as "200". The requests aborted by a panic,
+	// such as by Abort, are counted as "aborted".
+	Statuses map[string]int `json:"statuses"`
+	// Latency is the distribution of the response time of the requests.
+	Latency LatencyReport `json:"latency"`
+}
+
+// LatencyReport is a distribution of response time.
+// The percentiles are estimated from up to 10000 samples of the requests.
+type LatencyReport struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// maxLatencySamples is the number of latencies kept for the percentiles.
+const maxLatencySamples = 10000
+
+// observation records the responses in a phase.
+type observation struct {
+	mu       sync.Mutex
+	requests int
+	statuses map[string]int
+	sum      time.Duration
+	min, max time.Duration
+	// samples are a uniform sample of the latencies by reservoir sampling.
+	samples []time.Duration
+}
+
+func (o *observation) record(status string, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.requests++
+	if o.statuses == nil {
+		o.statuses = map[string]int{}
+	}
+	o.statuses[status]++
+	o.sum += d
+	if o.requests == 1 || d < o.min {
+		o.min = d
+	}
+	if d > o.max {
+		o.max = d
+	}
+	if len(o.samples) < maxLatencySamples {
+		o.samples = append(o.samples, d)
+	} else if i := randomIndex(o.requests); i < maxLatencySamples {
+		o.samples[i] = d
+	}
+}
+
+// fill sets the observed values to the PhaseReport.
+func (o *observation) fill(pr *PhaseReport) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	pr.Requests = o.requests
+	pr.Statuses = make(map[string]int, len(o.statuses))
+	for k, v := range o.statuses {
+		pr.Statuses[k] = v
+	}
+	pr.Latency = o.latency()
+}
+
+// latency returns the distribution of the observed latencies. o.mu must be held.
+func (o *observation) latency() LatencyReport {
+	if o.requests == 0 {
+		return LatencyReport{}
+	}
+
+	samples := o.sorted()
+	return LatencyReport{
+		Min:  o.min,
+		Mean: o.sum / time.Duration(o.requests),
+		P50:  percentile(samples, 50),
+		P90:  percentile(samples, 90),
+		P99:  percentile(samples, 99),
+		Max:  o.max,
+	}
+}
+
+// sorted returns the sorted copy of the samples. o.mu must be held.
+func (o *observation) sorted() []time.Duration {
+	samples := append([]time.Duration(nil), o.samples...)
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples
+}
+
+// percentile returns the p-th percentile of the sorted samples, which must not be empty.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	return samples[int(float64(len(samples)-1)*p/100)]
+}
+
+// observe serves the request by next, and records its response to o.
+func (o *observation) observe(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	sw := &statusWriter{ResponseWriter: w}
+	start := CurrentClock().Now()
+	defer func() {
+		if v := recover(); v != nil {
+			o.record("aborted", CurrentClock().Now().Sub(start))
+			panic(v)
+		}
+		code := sw.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		o.record(strconv.Itoa(code), CurrentClock().Now().Sub(start))
+	}()
+
+	next.ServeHTTP(sw, r)
+}
+
+// statusWriter remembers the status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("fault: %T does not support hijacking", w.ResponseWriter)
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,106 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailFirst fails the first Attempts attempts of each request and lets the following ones succeed,
+// so that the retries and the backoffs of the clients, and the exactly-once handling of the servers can be tested
+// directly. The attempts of the same request are identified by Key.
+// Only the attempts injected by the Handler are counted, so the Handler usually injects it at the ratio 1:
+//
+//	fault.New(&fault.FailFirst{Attempts: 2}, 1)
+type FailFirst struct {
+	// Attempts is the number of the attempts failing per request. Must be positive.
+	Attempts int
+	// Key identifies the request the attempt is of. If nil, the Idempotency-Key header is used,
+	// or RequestHash for the requests without it.
+	Key KeyFunc
+	// Fault fails the attempts. If nil, Error with 503 Service Unavailable is used.
+	Fault Fault
+	// TTL is how long a request is remembered since its last attempt. If zero, 10 minutes is used.
+	TTL time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]*attempts
+	swept    time.Time
+}
+
+// attempts is the number of the attempts of a request.
+type attempts struct {
+	n    int
+	last time.Time
+}
+
+// Handle fails the request if it has not been attempted Attempts times yet, and calls next otherwise.
+func (f *FailFirst) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if !f.attempt(f.key(r), CurrentClock().Now()) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	failure := f.Fault
+	if failure == nil {
+		failure = &Error{StatusCode: http.StatusServiceUnavailable}
+	}
+	failure.Handle(w, r, next)
+}
+
+// Validate checks Attempts is positive, TTL is not negative, and Fault is valid.
+func (f *FailFirst) Validate() error {
+	if f.Attempts <= 0 {
+		return fmt.Errorf("attempts %d must be positive", f.Attempts)
+	}
+	if err := validateDuration("ttl", f.TTL); err != nil {
+		return err
+	}
+	if f.Fault != nil {
+		return validateFault(f.Fault)
+	}
+	return nil
+}
+
+func (f *FailFirst) key(r *http.Request) string {
+	if f.Key != nil {
+		return f.Key(r)
+	}
+	if k := r.Header.Get("Idempotency-Key"); k != "" {
+		return k
+	}
+	return RequestHash(r)
+}
+
+// attempt counts an attempt of the key, and reports whether it fails.
+func (f *FailFirst) attempt(key string, now time.Time) bool {
+	ttl := f.TTL
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.attempts == nil {
+		f.attempts = map[string]*attempts{}
+	}
+	// Forget the requests not attempted for TTL, at most once per TTL.
+	if now.Sub(f.swept) >= ttl {
+		for k, a := range f.attempts {
+			if now.Sub(a.last) >= ttl {
+				delete(f.attempts, k)
+			}
+		}
+		f.swept = now
+	}
+
+	a, ok := f.attempts[key]
+	if !ok || now.Sub(a.last) >= ttl {
+		a = &attempts{}
+		f.attempts[key] = a
+	}
+	a.n++
+	a.last = now
+	return a.n <= f.Attempts
+}
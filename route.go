@@ -0,0 +1,104 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeKey struct{}
+
+// WithRoute returns a copy of ctx carrying the route pattern the request matched, such as "/users/:id".
+// Adapters for routers set it so that faults can target requests by their route rather than by their path.
+func WithRoute(ctx context.Context, pattern string) context.Context {
+	return context.WithValue(ctx, routeKey{}, pattern)
+}
+
+// RouteFrom returns the route pattern carried by ctx, or "" if none.
+func RouteFrom(ctx context.Context) string {
+	p, _ := ctx.Value(routeKey{}).(string)
+	return p
+}
+
+// Route returns a Matcher matching the requests whose route pattern set by WithRoute is any of the patterns.
+func Route(patterns ...string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		route := RouteFrom(r.Context())
+		for _, p := range patterns {
+			if route == p {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// PatternFunc returns the route pattern the request matches, or "" if none.
+type PatternFunc func(r *http.Request) string
+
+// ServeMuxPattern returns a PatternFunc returning the pattern of mux the request matches.
+func ServeMuxPattern(mux *http.ServeMux) PatternFunc {
+	return func(r *http.Request) string {
+		_, p := mux.Handler(r)
+		return p
+	}
+}
+
+// Routes injects different faults per route pattern in one declaration, instead of wrapping each route.
+// It wraps the whole router, finds the pattern of each request by Pattern, and applies the faults of the pattern.
+// The pattern is also set by WithRoute, so the faults can use Route.
+//
+//	routes := &fault.Routes{
+//		Pattern: fault.ServeMuxPattern(mux),
+//		Faults: map[string]fault.Middleware{
+//			"/users/":  fault.New(&fault.Delay{Duration: time.Second}, 0.1),
+//			"/orders/": fault.New(&fault.Error{StatusCode: 503}, 0.05),
+//		},
+//	}
+//	http.ListenAndServe(":8080", routes.Handler(mux))
+//
+// Other routers can find the pattern in the same way, such as chi:
+//
+//	Pattern: func(r *http.Request) string {
+//		rctx := chi.NewRouteContext()
+//		if router.Match(rctx, r.Method, r.URL.Path) {
+//			return rctx.RoutePattern()
+//		}
+//		return ""
+//	}
+//
+// and gorilla/mux:
+//
+//	Pattern: func(r *http.Request) string {
+//		var m mux.RouteMatch
+//		if router.Match(r, &m) && m.Route != nil {
+//			tpl, _ := m.Route.GetPathTemplate()
+//			return tpl
+//		}
+//		return ""
+//	}
+type Routes struct {
+	// Pattern finds the route pattern of a request. Required.
+	Pattern PatternFunc
+	// Faults maps the route patterns to the faults injected into the routes.
+	Faults map[string]Middleware
+}
+
+// Handler wraps the router with the faults of the routes.
+func (rs *Routes) Handler(next http.Handler) http.Handler {
+	wrapped := make(map[string]http.Handler, len(rs.Faults))
+	for p, m := range rs.Faults {
+		wrapped[p] = m.Handler(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := rs.Pattern(r)
+		if p != "" {
+			r = r.WithContext(WithRoute(r.Context(), p))
+		}
+		if h, ok := wrapped[p]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,258 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scenario runs an experiment of several phases, one after another, each injecting its own fault for a while.
+// A game day script can be encoded as a Scenario and run by Run:
+//
+//	s := &fault.Scenario{
+//		Name: "checkout-game-day",
+//		Phases: []fault.Phase{
+//			{Name: "slow", Duration: 5 * time.Minute, Handler: fault.New(&fault.Delay{Duration: 100 * time.Millisecond}, 1)},
+//			{Name: "unavailable", Duration: 5 * time.Minute, Handler: fault.New(&fault.Error{StatusCode: 503}, 0.1)},
+//			{Name: "abort", Duration: time.Minute, Handler: fault.New(&fault.Abort{}, 1)},
+//		},
+//	}
+//	srv := &http.Server{Handler: s.Handler(mux)}
+//	go s.Run(ctx)
+//
+// Requests are proxied to next untouched while the Scenario is not running.
+type Scenario struct {
+	// Name identifies the Scenario.
+	Name string
+	// Phases are run in order. Phases must not be modified while the Scenario runs.
+	Phases []Phase
+	// OnStart is called when the Scenario starts. The callbacks are called from Run, so they must not block.
+	OnStart func()
+	// OnPhase is called when a phase begins.
+	OnPhase func(p Phase)
+	// OnFinish is called with the Report when the Scenario finishes, or stops by an error.
+	OnFinish func(r Report)
+	// SteadyState stops the Scenario when the service leaves the steady state. It is checked when the Scenario
+	// starts, then every Interval. If nil, there is no guard.
+	SteadyState *SteadyState
+
+	// current is the index of the running phase plus one, or zero if the Scenario is not running.
+	current int32
+	running uint32
+
+	mu     sync.Mutex
+	report Report
+	runs   []*phaseRun
+}
+
+// phaseRun is the record of a phase in a run.
+type phaseRun struct {
+	report  PhaseReport
+	handler *Handler
+	// injected is the number of injections of the Handler when the phase started.
+	injected int
+	obs      *observation
+}
+
+// Phase is a step of a Scenario.
+type Phase struct {
+	// Name identifies the phase.
+	Name string
+	// Duration is how long the phase lasts.
+	Duration time.Duration
+	// Handler injects the fault during the phase. If nil, no fault is injected, which is useful as an interval
+	// between the phases. Run sets its start to when the phase begins by SetStart so that the Ramp and the TTL count from there,
+	// so a Handler must not be used in more than one phase, nor outside the Scenario.
+	Handler *Handler
+}
+
+// Run runs the phases in order, and returns when the last phase ends.
+// If ctx is done before that, the Scenario is stopped and ctx.Err() is returned.
+// If the SteadyState is violated, the Scenario is stopped and the error of the check is returned.
+// A Scenario can be run again after Run returns, but not concurrently.
+// What happened in the run is recorded, which Report returns.
+func (s *Scenario) Run(ctx context.Context) error {
+	if !atomic.CompareAndSwapUint32(&s.running, 0, 1) {
+		return errors.New("fault: scenario is already running")
+	}
+	defer atomic.StoreUint32(&s.running, 0)
+
+	s.mu.Lock()
+	s.report = Report{Scenario: s.Name, Start: CurrentClock().Now()}
+	s.runs = make([]*phaseRun, len(s.Phases))
+	for i := range s.runs {
+		s.runs[i] = &phaseRun{obs: &observation{}}
+	}
+	s.mu.Unlock()
+
+	if s.OnStart != nil {
+		s.OnStart()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	violated := make(chan error, 1)
+	if s.SteadyState != nil {
+		go s.watch(ctx, violated, cancel)
+	}
+
+	err := s.run(ctx)
+	atomic.StoreInt32(&s.current, 0)
+	select {
+	case verr := <-violated:
+		s.SteadyState.violate("scenario "+strconv.Quote(s.Name), verr)
+		err = fmt.Errorf("fault: steady state is violated: %w", verr)
+	default:
+	}
+
+	s.mu.Lock()
+	s.report.End = CurrentClock().Now()
+	if err != nil {
+		s.report.Error = err.Error()
+	}
+	s.mu.Unlock()
+	if s.OnFinish != nil {
+		s.OnFinish(s.Report())
+	}
+	return err
+}
+
+func (s *Scenario) run(ctx context.Context) error {
+	for i := range s.Phases {
+		p := s.Phases[i]
+		s.begin(i, CurrentClock().Now())
+		atomic.StoreInt32(&s.current, int32(i+1))
+		if s.OnPhase != nil {
+			s.OnPhase(p)
+		}
+		err := sleepContext(ctx, p.Duration)
+		s.end(i, CurrentClock().Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// begin records the start of the i-th phase.
+func (s *Scenario) begin(i int, now time.Time) {
+	p := s.Phases[i]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.runs[i]
+	pr.report.Name = p.Name
+	pr.report.Start = now
+	pr.handler = p.Handler
+	if p.Handler != nil {
+		p.Handler.SetStart(now)
+		pr.report.Fault = FaultName(p.Handler.f)
+		pr.injected = p.Handler.Stats().Injected
+	}
+}
+
+// end records the end of the i-th phase.
+func (s *Scenario) end(i int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[i].report.End = now
+	s.fillHandler(i)
+}
+
+// fillHandler sets the ratio and the injections of the Handler of the i-th phase to its report.
+func (s *Scenario) fillHandler(i int) {
+	pr := s.runs[i]
+	h := pr.handler
+	if h == nil {
+		return
+	}
+	pr.report.Ratio = h.Ratio()
+	pr.report.Injected = h.Stats().Injected - pr.injected
+}
+
+// Report returns the Report of the current run, or of the last run if the Scenario is not running.
+// It reports the phases run so far, including the running one.
+func (s *Scenario) Report() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.report
+	r.Phases = []PhaseReport{}
+	for i, pr := range s.runs {
+		if pr.report.Start.IsZero() {
+			break
+		}
+		if pr.report.End.IsZero() {
+			s.fillHandler(i)
+		}
+		p := pr.report
+		pr.obs.fill(&p)
+		r.Phases = append(r.Phases, p)
+	}
+
+	return r
+}
+
+// phaseRun returns the record of the running phase, or nil if the Scenario is not running.
+func (s *Scenario) phaseRun() *phaseRun {
+	i := atomic.LoadInt32(&s.current)
+	if i == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[i-1]
+}
+
+// watch checks the SteadyState until ctx is done. If it is violated, the error is sent to violated,
+// then stop is called.
+func (s *Scenario) watch(ctx context.Context, violated chan<- error, stop func()) {
+	for {
+		if err := s.SteadyState.check(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			violated <- err
+			stop()
+			return
+		}
+
+		if sleepContext(ctx, s.SteadyState.interval()) != nil {
+			return
+		}
+	}
+}
+
+// Phase returns the running phase. It reports false if the Scenario is not running.
+func (s *Scenario) Phase() (Phase, bool) {
+	i := atomic.LoadInt32(&s.current)
+	if i == 0 {
+		return Phase{}, false
+	}
+	return s.Phases[i-1], true
+}
+
+// Handler wraps the given handler with the Handler of the running phase.
+// The responses are recorded for the Report while the Scenario is running.
+func (s *Scenario) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pr := s.phaseRun()
+		if pr == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := next
+		if pr.handler != nil {
+			h = pr.handler.Handler(next)
+		}
+		pr.obs.observe(h, w, r)
+	})
+}
@@ -0,0 +1,56 @@
+package fault
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScenarioPhaseStart(t *testing.T) {
+	clk := NewFakeClock(epoch)
+	SetClock(clk)
+	defer SetClock(nil)
+
+	h := New(nop, 1)
+	h.TTL = time.Minute
+	clk.Add(2 * time.Minute)
+	if o := h.Evaluate(httptest.NewRequest("GET", "/", nil)); o.Reason != "expired" {
+		t.Fatalf("reason before the phase = %q, want expired", o.Reason)
+	}
+
+	// The Handler is serving while the phase begins.
+	stop := make(chan struct{})
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				h.Evaluate(httptest.NewRequest("GET", "/", nil))
+			}
+		}
+	}()
+
+	s := &Scenario{Name: "s", Phases: []Phase{{Name: "p", Duration: time.Hour, Handler: h}}}
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	waitSleepers(t, clk, 1)
+	close(stop)
+	<-served
+
+	if o := h.Evaluate(httptest.NewRequest("GET", "/", nil)); o.Decision != Inject {
+		t.Errorf("decision in the phase = %v (%s), want inject as the TTL counts from the phase", o.Decision, o.Reason)
+	}
+	clk.Add(time.Minute)
+	if o := h.Evaluate(httptest.NewRequest("GET", "/", nil)); o.Reason != "expired" {
+		t.Errorf("reason after the TTL = %q, want expired", o.Reason)
+	}
+
+	clk.Add(time.Hour)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,169 @@
+package fault
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells whether the fault is active at the given time.
+// It allows recurring experiments, such as game days, without manual toggling.
+type Schedule interface {
+	Active(t time.Time) bool
+}
+
+// Window is a Schedule active during a daily time range on the given weekdays,
+// such as weekdays 14:00-15:00.
+type Window struct {
+	// Weekdays are the days the window opens. If empty, every day.
+	Weekdays []time.Weekday
+	// From and To are the range of the window, as the time elapsed since midnight.
+	// From is inclusive and To is exclusive. If To is before From, the window lasts over midnight
+	// and the weekday is checked at From.
+	From, To time.Duration
+	// Location is the time zone of the window. If nil, the local time zone is used.
+	Location *time.Location
+}
+
+// Active returns true if t is in the window.
+func (w *Window) Active(t time.Time) bool {
+	if w.Location != nil {
+		t = t.In(w.Location)
+	}
+	// The time of day is taken from the clock, not from midnight, which is an hour off on the days DST changes.
+	elapsed := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	if w.From <= w.To {
+		return elapsed >= w.From && elapsed < w.To && w.onDay(t.Weekday())
+	}
+
+	// The window lasts over midnight.
+	if elapsed >= w.From {
+		return w.onDay(t.Weekday())
+	}
+	if elapsed < w.To {
+		return w.onDay((t.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+func (w *Window) onDay(d time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, wd := range w.Weekdays {
+		if wd == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Cron is a Schedule described by a cron expression.
+// The schedule is active during every minute the expression matches;
+// e.g. "* 14 * * 1-5" is active on weekdays from 14:00 to 14:59.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	loc                           *time.Location
+}
+
+// ParseCron parses a standard 5-field cron expression: minute, hour, day of month, month and day of week.
+// Each field accepts "*", numbers, ranges "a-b", steps "*/n", "a-b/n" and "a/n", and comma-separated lists of them.
+// As in cron, "a/n" is every n from a to the maximum of the field.
+// Day of week is 0-7 where both 0 and 7 are Sunday. As in cron, if both day of month and day of week
+// are restricted, the schedule is active when either matches; a field starting with "*", such as "*/2", is not restricted.
+// loc is the time zone the expression is evaluated in. If nil, the local time zone is used.
+func ParseCron(expr string, loc *time.Location) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("fault: cron expression %q must have 5 fields", expr)
+	}
+
+	c := &Cron{loc: loc}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("fault: cron minute: %w", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("fault: cron hour: %w", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("fault: cron day of month: %w", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("fault: cron month: %w", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("fault: cron day of week: %w", err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return c, nil
+}
+
+// parseCronField returns the bit set of the values the field matches.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step, stepped := 1, false
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step, stepped = s, true
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if stepped {
+				hi = max
+			}
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("%q is out of range [%d, %d]", part, min, max)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// Active returns true if the minute of t matches the expression.
+func (c *Cron) Active(t time.Time) bool {
+	if c.loc != nil {
+		t = t.In(c.loc)
+	}
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
@@ -0,0 +1,118 @@
+package fault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, tc := range []struct {
+		expr   string
+		active []string
+		idle   []string
+	}{
+		{"* * * * *", []string{"2024-01-01 00:00", "2024-06-15 23:59"}, nil},
+		{"* 14 * * 1-5", []string{"2024-01-01 14:00", "2024-01-05 14:59"}, []string{"2024-01-01 15:00", "2024-01-06 14:00"}},
+		{"*/15 * * * *", []string{"2024-01-01 10:00", "2024-01-01 10:45"}, []string{"2024-01-01 10:01", "2024-01-01 10:44"}},
+		{"0,30 9-17/4 * * *", []string{"2024-01-01 09:00", "2024-01-01 13:30", "2024-01-01 17:00"}, []string{"2024-01-01 10:00", "2024-01-01 09:15"}},
+		{"0 0 1 1 *", []string{"2024-01-01 00:00"}, []string{"2024-01-02 00:00", "2024-02-01 00:00"}},
+		{"* * * * 7", []string{"2024-01-07 12:00"}, []string{"2024-01-06 12:00"}},
+		{"* * * * 0", []string{"2024-01-07 12:00"}, []string{"2024-01-08 12:00"}},
+		// Either the day of month or the day of week matches if both are restricted.
+		{"* * 15 * 1", []string{"2024-01-15 12:00", "2024-01-08 12:00"}, []string{"2024-01-09 12:00"}},
+		// Both must match if either is "*".
+		{"* * 15 * *", []string{"2024-01-15 12:00"}, []string{"2024-01-08 12:00"}},
+		{"5/10 * * * *", []string{"2024-01-01 10:05", "2024-01-01 10:15", "2024-01-01 10:55"}, []string{"2024-01-01 10:00", "2024-01-01 10:06"}},
+		// "*/2" is unrestricted as "*" is, so both days must match.
+		{"* * */2 * 1", []string{"2024-01-15 12:00"}, []string{"2024-01-08 12:00", "2024-01-09 12:00"}},
+		{"* * 16 * */2", []string{"2024-01-16 12:00"}, []string{"2024-01-09 12:00", "2024-01-17 12:00"}},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			c, err := ParseCron(tc.expr, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tc.active {
+				if !c.Active(parseMinute(t, s)) {
+					t.Errorf("Active(%s) = false, want true", s)
+				}
+			}
+			for _, s := range tc.idle {
+				if c.Active(parseMinute(t, s)) {
+					t.Errorf("Active(%s) = true, want false", s)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	} {
+		if _, err := ParseCron(expr, time.UTC); err == nil {
+			t.Errorf("ParseCron(%q) = nil error, want an error", expr)
+		}
+	}
+}
+
+func TestCronLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+9", 9*60*60)
+	c, err := ParseCron("* 9 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Active(parseMinute(t, "2024-01-01 00:30")) {
+		t.Error("Active(00:30 UTC) = false, want true in UTC+9")
+	}
+}
+
+func TestWindowDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	w := &Window{From: 14 * time.Hour, To: 15 * time.Hour, Location: loc}
+	// The clocks went forward on 2024-03-10 and back on 2024-11-03.
+	for _, day := range []int{10, 3} {
+		month := time.March
+		if day == 3 {
+			month = time.November
+		}
+		for _, tc := range []struct {
+			hour, min int
+			want      bool
+		}{
+			{13, 59, false},
+			{14, 0, true},
+			{14, 59, true},
+			{15, 0, false},
+		} {
+			tm := time.Date(2024, month, day, tc.hour, tc.min, 0, 0, loc)
+			if got := w.Active(tm); got != tc.want {
+				t.Errorf("Active(%v) = %v, want %v", tm, got, tc.want)
+			}
+		}
+	}
+}
+
+func parseMinute(t *testing.T, s string) time.Time {
+	t.Helper()
+	tm, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
@@ -0,0 +1,77 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Script applies the faults following a scripted sequence across the requests, to simulate an upstream incident
+// with its recovery, such as:
+//
+//	fault.New(&fault.Script{Steps: []fault.Step{
+//		{Requests: 50, Fault: &fault.Error{StatusCode: 503}},
+//		{Requests: 100, Fault: &fault.Delay{Duration: 2 * time.Second}},
+//	}}, 1)
+//
+// After the last step, every request is passed to the next handler, unless Repeat is true.
+// Only the requests injected by the Handler advance the script, so the Handler usually injects it at the ratio 1.
+type Script struct {
+	// Steps are the steps of the script in order.
+	Steps []Step
+	// Repeat starts the script over after the last step.
+	Repeat bool
+
+	n uint64
+}
+
+// Step is a step of a Script.
+type Step struct {
+	// Requests is the number of the requests the step lasts. Must be positive.
+	Requests int
+	// Fault is applied to the requests in the step. If nil, the requests are passed to the next handler,
+	// which makes a quiet period of the script.
+	Fault Fault
+}
+
+// Handle applies the fault of the current step.
+func (s *Script) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if f := s.step(atomic.AddUint64(&s.n, 1) - 1); f != nil {
+		f.Handle(w, r, next)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// Validate checks every step lasts a positive number of requests and has a valid fault.
+func (s *Script) Validate() error {
+	for i, st := range s.Steps {
+		if st.Requests <= 0 {
+			return fmt.Errorf("requests %d of step %d must be positive", st.Requests, i)
+		}
+		if st.Fault != nil {
+			if err := validateFault(st.Fault); err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// step returns the fault of the step the n-th request is in.
+func (s *Script) step(n uint64) Fault {
+	var total uint64
+	for _, st := range s.Steps {
+		total += uint64(st.Requests)
+	}
+	if s.Repeat && total > 0 {
+		n %= total
+	}
+	for _, st := range s.Steps {
+		if n < uint64(st.Requests) {
+			return st.Fault
+		}
+		n -= uint64(st.Requests)
+	}
+	return nil
+}
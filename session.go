@@ -0,0 +1,93 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionExpiry fails the requests of a session with 401 Unauthorized once the session has been seen for After,
+// as a token expiring in the middle of the use does, so that the silent re-authentication flows of the clients
+// can be tested. The sessions are identified by Session, such as a session cookie or a claim of the JWT:
+//
+//	fault.New(&fault.SessionExpiry{Session: fault.CookieKey("session_id"), After: 5 * time.Minute}, 1)
+//
+// The requests without a session are passed to the next handler. A session not seen for After is forgotten,
+// so it starts over when it is seen again. Only the requests injected by the Handler are tracked,
+// so the Handler usually injects it at the ratio 1, narrowing down the sessions by the Matchers.
+type SessionExpiry struct {
+	// Session identifies the session of the request. Required.
+	Session KeyFunc
+	// After is how long a session lasts since it is first seen. Must be positive.
+	After time.Duration
+	// Fault fails the requests of the expired sessions. If nil, AuthFailure with AuthExpiredToken is used.
+	Fault Fault
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	swept    time.Time
+}
+
+// session is when a session was first and last seen.
+type session struct {
+	first, last time.Time
+}
+
+// Handle fails the request if its session has expired, and calls next otherwise.
+func (f *SessionExpiry) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	var key string
+	if f.Session != nil {
+		key = f.Session(r)
+	}
+	if key == "" || !f.expired(key, CurrentClock().Now()) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	failure := f.Fault
+	if failure == nil {
+		failure = &AuthFailure{Error: AuthExpiredToken}
+	}
+	failure.Handle(w, r, next)
+}
+
+// Validate checks Session is set, After is positive, and Fault is valid.
+func (f *SessionExpiry) Validate() error {
+	if f.Session == nil {
+		return fmt.Errorf("session is required")
+	}
+	if f.After <= 0 {
+		return fmt.Errorf("after %v must be positive", f.After)
+	}
+	if f.Fault != nil {
+		return validateFault(f.Fault)
+	}
+	return nil
+}
+
+// expired records the session is seen at now, and reports whether it has expired.
+func (f *SessionExpiry) expired(key string, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sessions == nil {
+		f.sessions = map[string]*session{}
+	}
+	// Forget the sessions not seen for After, at most once per After.
+	if now.Sub(f.swept) >= f.After {
+		for k, s := range f.sessions {
+			if now.Sub(s.last) >= f.After {
+				delete(f.sessions, k)
+			}
+		}
+		f.swept = now
+	}
+
+	s, ok := f.sessions[key]
+	if !ok || now.Sub(s.last) >= f.After {
+		s = &session{first: now}
+		f.sessions[key] = s
+	}
+	s.last = now
+	return now.Sub(s.first) >= f.After
+}
@@ -0,0 +1,157 @@
+package fault
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Shadow duplicates a sampled fraction of the requests to a shadow target with the faults applied only to the copies,
+// so that the behavior under failures can be observed without affecting the real responses.
+// The copies are served in background, and their responses are discarded.
+//
+//	shadow := &fault.Shadow{
+//		Target: httputil.NewSingleHostReverseProxy(shadowURL),
+//		Faults: fault.New(&fault.Error{StatusCode: 503}, 0.5),
+//		Ratio:  0.1,
+//	}
+//	http.ListenAndServe(":8080", shadow.Handler(mux))
+//
+// No request is duplicated while the kill switch is on.
+type Shadow struct {
+	// Target serves the copies of the requests. Required.
+	Target http.Handler
+	// Faults wraps Target with the faults. If nil, the copies are served by Target as they are.
+	Faults Middleware
+	// Ratio is the ratio of the requests duplicated, in [0, 1].
+	Ratio float64
+	// Sampler selects the requests duplicated. If nil, RandomSampler is used.
+	Sampler Sampler
+	// MaxBody is the largest request body duplicated, since the body is buffered to be read twice.
+	// The requests of the larger body are not duplicated. If zero, 1 MiB is used.
+	MaxBody int64
+	// Timeout limits how long a copy is served. If zero, 30 seconds is used.
+	Timeout time.Duration
+	// MaxInFlight limits the copies served at once. The requests exceeding it are not duplicated.
+	// If zero, 100 is used.
+	MaxInFlight int
+	// OnResult is called with the result of each copy. Optional.
+	OnResult func(ShadowResult)
+
+	inFlight int64
+}
+
+// ShadowResult is the result of a copy served by Shadow.
+type ShadowResult struct {
+	// Request is the copy of the request.
+	Request *http.Request
+	// StatusCode is the status code responded to the copy. Zero if it is aborted.
+	StatusCode int
+	// Aborted reports whether serving the copy panicked, such as by Abort.
+	Aborted bool
+	// Duration is how long the copy took.
+	Duration time.Duration
+}
+
+// Handler wraps next, duplicating the sampled requests to the shadow.
+func (s *Shadow) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cp, ok := s.duplicate(r); ok {
+			go s.serve(cp)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// duplicate returns the copy of the request if it is sampled.
+// The body of r is replaced so that it can be read again.
+func (s *Shadow) duplicate(r *http.Request) (*http.Request, bool) {
+	sampler := s.Sampler
+	if sampler == nil {
+		sampler = defaultSampler
+	}
+	if AllDisabled() || !sampler.Sample(r, s.Ratio) {
+		return nil, false
+	}
+
+	maxInFlight := s.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 100
+	}
+	if atomic.AddInt64(&s.inFlight, 1) > int64(maxInFlight) {
+		atomic.AddInt64(&s.inFlight, -1)
+		return nil, false
+	}
+
+	maxBody := s.MaxBody
+	if maxBody <= 0 {
+		maxBody = 1 << 20
+	}
+	body, ok := peekBody(r, maxBody)
+	if !ok {
+		atomic.AddInt64(&s.inFlight, -1)
+		return nil, false
+	}
+
+	// The copy outlives the request, so it does not inherit the cancellation of the request context.
+	cp := r.Clone(context.Background())
+	cp.Body = io.NopCloser(bytes.NewReader(body))
+	cp.ContentLength = int64(len(body))
+	return cp, true
+}
+
+// serve serves the copy by the target with the faults.
+func (s *Shadow) serve(r *http.Request) {
+	defer atomic.AddInt64(&s.inFlight, -1)
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	target := s.Target
+	if s.Faults != nil {
+		target = s.Faults.Handler(target)
+	}
+	sw := &shadowWriter{header: http.Header{}}
+	start := CurrentClock().Now()
+	defer func() {
+		res := ShadowResult{Request: r, StatusCode: sw.code, Duration: CurrentClock().Now().Sub(start)}
+		if v := recover(); v != nil {
+			res.StatusCode, res.Aborted = 0, true
+		} else if res.StatusCode == 0 {
+			res.StatusCode = http.StatusOK
+		}
+		if s.OnResult != nil {
+			s.OnResult(res)
+		}
+	}()
+	target.ServeHTTP(sw, r)
+}
+
+// shadowWriter discards the response to a copy, remembering its status code.
+type shadowWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *shadowWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *shadowWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *shadowWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
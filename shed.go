@@ -0,0 +1,65 @@
+package fault
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultPriorityHeader is the name of the header LoadShed exempts the requests by default.
+const DefaultPriorityHeader = "X-Priority"
+
+// LoadShed sheds the requests with 503 Service Unavailable as an overloaded upstream protecting itself does,
+// unless they carry the priority header, so that the clients can be tested against a brownout rather than
+// a blackout. The fraction shed is the ratio of the Handler:
+//
+//	fault.New(&fault.LoadShed{Priorities: []string{"critical"}}, 0.3)
+//
+// The exempted requests are passed to the next handler, while they are counted as injected by the Handler.
+type LoadShed struct {
+	// Header is the name of the priority header. If empty, DefaultPriorityHeader is used.
+	Header string
+	// Priorities are the values of the header exempted. If empty, the requests having the header with any value
+	// are exempted.
+	Priorities []string
+	// RetryAfter is set to the Retry-After header of the shed responses. If zero, the header is not set.
+	RetryAfter time.Duration
+}
+
+// Handle sheds the request unless it has the priority.
+func (f *LoadShed) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if f.prioritized(r) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if f.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((f.RetryAfter+time.Second-1)/time.Second)))
+	}
+	writeError(w, r, http.StatusServiceUnavailable, "fault: pseudo load shedding is injected", false)
+}
+
+// Validate checks RetryAfter is not negative.
+func (f *LoadShed) Validate() error {
+	return validateDuration("retry after", f.RetryAfter)
+}
+
+func (f *LoadShed) prioritized(r *http.Request) bool {
+	header := f.Header
+	if header == "" {
+		header = DefaultPriorityHeader
+	}
+	v := r.Header.Get(header)
+	if v == "" {
+		return false
+	}
+	if len(f.Priorities) == 0 {
+		return true
+	}
+	for _, p := range f.Priorities {
+		if p == v {
+			return true
+		}
+	}
+	return false
+}
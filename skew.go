@@ -0,0 +1,32 @@
+package fault
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClockSkew shifts the times in the Date, Expires and Last-Modified headers of the response of the next handler
+// by Skew, as a server with a skewed clock responds, so that the caches and the signature validations sensitive
+// to the clock differences can be tested. The Date header is added if the next handler does not set it, since
+// net/http would set the current time. The headers which are not valid HTTP dates, such as "Expires: 0",
+// are left as they are. The response is streamed as it is.
+type ClockSkew struct {
+	// Skew is added to the times; positive to be ahead, and negative to be behind.
+	Skew time.Duration
+}
+
+// Handle calls next, shifting the times in the headers of its response.
+func (f *ClockSkew) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	next.ServeHTTP(&headerWriter{ResponseWriter: w, rewrite: f.rewrite}, r)
+}
+
+func (f *ClockSkew) rewrite(h http.Header) {
+	if h.Get("Date") == "" {
+		h.Set("Date", CurrentClock().Now().UTC().Format(http.TimeFormat))
+	}
+	for _, name := range []string{"Date", "Expires", "Last-Modified"} {
+		if t, err := http.ParseTime(h.Get(name)); err == nil {
+			h.Set(name, t.Add(f.Skew).UTC().Format(http.TimeFormat))
+		}
+	}
+}
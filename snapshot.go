@@ -0,0 +1,63 @@
+package fault
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Snapshot is a point-in-time copy of the effective settings of a Handler.
+// It is safe to keep and read a Snapshot while the Handler keeps serving requests.
+type Snapshot struct {
+	// Name is the name of the Handler.
+	Name string `json:"name"`
+	// Fault is the fault in effect. Faults given to a Handler are never modified by this package,
+	// so the fault must not be modified through the Snapshot either.
+	// It is not encoded to JSON, since some faults cannot be; FaultName and Params are encoded instead.
+	Fault Fault `json:"-"`
+	// FaultName is the name of the fault, see FaultName.
+	FaultName string `json:"fault"`
+	// Params is the fault encoded as JSON when the Snapshot was taken.
+	// It is omitted if the fault cannot be encoded, such as FaultFunc.
+	Params json.RawMessage `json:"params,omitempty"`
+	// Ratio is the injection ratio in effect, after the Ramp is applied. Handler.MethodRatios are not applied.
+	Ratio float64 `json:"ratio"`
+	// Disabled reports whether the Handler is disabled.
+	Disabled bool `json:"disabled"`
+	// Active reports whether the Handler is injecting; the kill switch is off,
+	// and the Handler is enabled, within its time box and on schedule.
+	Active bool `json:"active"`
+	// DryRun reports whether the Handler is in dry-run mode, either by itself or by SetDryRun.
+	DryRun bool `json:"dry_run"`
+	// Time is when the Snapshot was taken.
+	Time time.Time `json:"time"`
+}
+
+// Snapshot returns the effective settings of the Handler at the moment.
+func (h *Handler) Snapshot() Snapshot {
+	now := CurrentClock().Now()
+	disabled := h.Disabled()
+	s := Snapshot{
+		Name:      h.Name,
+		Fault:     h.f,
+		FaultName: FaultName(h.f),
+		Ratio:     h.ratio(nil, now),
+		Disabled:  disabled,
+		Active:    !AllDisabled() && !disabled && h.timeBox(now) == "",
+		DryRun:    h.dryRun(),
+		Time:      now,
+	}
+	if b, err := json.Marshal(h.f); err == nil {
+		s.Params = b
+	}
+	return s
+}
+
+// Snapshot returns the Snapshot of every Handler in the chain.
+func (c Chain) Snapshot() []Snapshot {
+	ss := make([]Snapshot, len(c))
+	for i, h := range c {
+		ss[i] = h.Snapshot()
+	}
+
+	return ss
+}
@@ -0,0 +1,52 @@
+package fault
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSnapshotJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		f      Fault
+		fault  string
+		params string
+	}{
+		{"plain", &Error{StatusCode: 503}, "error", `{"StatusCode":503,"StatusText":"","XML":false}`},
+		{"func", FaultFunc(func(http.ResponseWriter, *http.Request, http.Handler) {}), "fault_func", ""},
+		{"func field", &FailFirst{Attempts: 1, Key: func(*http.Request) string { return "" }}, "fail_first", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := New(tc.f, 0.5)
+			h.Name = "h"
+			b, err := json.Marshal(h.Snapshot())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Name   string          `json:"name"`
+				Fault  string          `json:"fault"`
+				Params json.RawMessage `json:"params"`
+				Ratio  float64         `json:"ratio"`
+				Time   time.Time       `json:"time"`
+			}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != "h" || got.Fault != tc.fault || string(got.Params) != tc.params || got.Ratio != 0.5 {
+				t.Errorf("snapshot = %s", b)
+			}
+		})
+	}
+}
+
+func TestSnapshotParamsCopied(t *testing.T) {
+	f := &Error{StatusCode: 503}
+	s := New(f, 1).Snapshot()
+	f.StatusCode = 500
+	if want := `{"StatusCode":503,"StatusText":"","XML":false}`; string(s.Params) != want {
+		t.Errorf("Params = %s, want %s", s.Params, want)
+	}
+}
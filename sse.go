@@ -0,0 +1,152 @@
+package fault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// SSE disturbs Server-Sent Events streams (text/event-stream responses) of the next handler, so that the reconnect
+// logic of the clients can be tested. The events can be stalled, dropped, or the stream can be terminated early.
+// The response header is flushed as soon as it is written, so the clients see the stream open as usual.
+// Other responses are proxied as they are.
+type SSE struct {
+	// Stall delays every event but the first one.
+	Stall time.Duration
+	// DropRatio is the ratio of events which are dropped, in [0, 1].
+	DropRatio float64
+	// MaxEvents terminates the stream once the number of events are sent. Zero means no limit.
+	// When the stream is terminated, the context of the request is canceled and the writes by the next handler fail,
+	// so that it stops writing.
+	MaxEvents int
+}
+
+// errStreamTerminated is returned from the writes after the SSE stream is terminated.
+var errStreamTerminated = errors.New("fault: event stream is terminated")
+
+// Handle disturbs the event stream written by the given handler.
+func (f *SSE) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sw := &sseWriter{ResponseWriter: w, f: f, ctx: ctx, cancel: cancel}
+	next.ServeHTTP(sw, r.WithContext(ctx))
+}
+
+// Validate checks Stall and MaxEvents are not negative and DropRatio is in [0, 1].
+func (f *SSE) Validate() error {
+	if err := validateDuration("stall", f.Stall); err != nil {
+		return err
+	}
+	if err := validateRatio("drop ratio", f.DropRatio); err != nil {
+		return err
+	}
+	return validateCount("max events", f.MaxEvents)
+}
+
+// sseWriter splits the event stream into events, and writes them with the faults.
+type sseWriter struct {
+	http.ResponseWriter
+	f      *SSE
+	ctx    context.Context
+	cancel func()
+
+	wroteHeader bool
+	stream      bool
+	buf         []byte
+	events      int
+	terminated  bool
+}
+
+func (w *sseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	mt, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	w.stream = mt == "text/event-stream"
+	w.ResponseWriter.WriteHeader(code)
+	if w.stream {
+		w.flush()
+	}
+}
+
+func (w *sseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.stream {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.terminated {
+		return 0, errStreamTerminated
+	}
+
+	w.buf = append(w.buf, b...)
+	for {
+		end := eventEnd(w.buf)
+		if end < 0 {
+			break
+		}
+		ev := w.buf[:end]
+		w.buf = w.buf[end:]
+		if err := w.send(ev); err != nil {
+			return len(b), err
+		}
+	}
+
+	return len(b), nil
+}
+
+// send writes an event with the faults.
+func (w *sseWriter) send(ev []byte) error {
+	if w.events > 0 && w.f.Stall > 0 {
+		if err := sleepContext(w.ctx, w.f.Stall); err != nil {
+			return err
+		}
+	}
+	if defaultSampler.Sample(nil, w.f.DropRatio) {
+		return nil
+	}
+
+	if _, err := w.ResponseWriter.Write(ev); err != nil {
+		return err
+	}
+	w.flush()
+	w.events++
+
+	if w.f.MaxEvents > 0 && w.events >= w.f.MaxEvents {
+		w.terminated = true
+		w.cancel()
+		return errStreamTerminated
+	}
+	return nil
+}
+
+// Flush flushes the written events. An incomplete event is kept until it completes.
+func (w *sseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.flush()
+}
+
+func (w *sseWriter) flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// eventEnd returns the length of the first complete event in b including the blank line ending it, or -1.
+func eventEnd(b []byte) int {
+	end := -1
+	for _, sep := range [][]byte{[]byte("\n\n"), []byte("\r\n\r\n"), []byte("\r\r")} {
+		if i := bytes.Index(b, sep); i >= 0 && (end < 0 || i+len(sep) < end) {
+			end = i + len(sep)
+		}
+	}
+	return end
+}
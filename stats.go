@@ -0,0 +1,99 @@
+package fault
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is the counters of a Handler, so that operators can query the state programmatically.
+type Stats struct {
+	// Evaluated is the number of requests the Handler evaluated.
+	Evaluated int `json:"evaluated"`
+	// Injected is the number of requests the faults were injected into.
+	Injected int `json:"injected"`
+	// DryRun is the number of requests which would have been injected in dry-run mode.
+	DryRun int `json:"dry_run"`
+	// LastInjection is when the last injection happened. Zero if never.
+	LastInjection time.Time `json:"last_injection"`
+	// Faults is the counters per fault, keyed by FaultName.
+	Faults map[string]FaultStats `json:"faults"`
+}
+
+// FaultStats is the counters of a fault.
+type FaultStats struct {
+	// Injected is the number of requests the fault was injected into.
+	Injected int `json:"injected"`
+	// LastInjection is when the fault was injected last.
+	LastInjection time.Time `json:"last_injection"`
+}
+
+// stats counts the outcomes of a Handler.
+type stats struct {
+	// evaluated must be the first field to be 64-bit aligned for atomic operations.
+	evaluated uint64
+
+	mu            sync.Mutex
+	injected      int
+	dryRun        int
+	lastInjection time.Time
+	faults        map[string]FaultStats
+}
+
+func (s *stats) record(o Outcome) {
+	atomic.AddUint64(&s.evaluated, 1)
+
+	switch o.Decision {
+	case Inject:
+		name := FaultName(o.Fault)
+		s.mu.Lock()
+		s.injected++
+		s.lastInjection = o.Time
+		if s.faults == nil {
+			s.faults = map[string]FaultStats{}
+		}
+		fs := s.faults[name]
+		fs.Injected++
+		fs.LastInjection = o.Time
+		s.faults[name] = fs
+		s.mu.Unlock()
+	case DryRun:
+		s.mu.Lock()
+		s.dryRun++
+		s.mu.Unlock()
+	}
+}
+
+// Stats returns the counters of the Handler since it was created.
+func (h *Handler) Stats() Stats {
+	s := h.stats
+	st := Stats{Evaluated: int(atomic.LoadUint64(&s.evaluated))}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.Injected = s.injected
+	st.DryRun = s.dryRun
+	st.LastInjection = s.lastInjection
+	st.Faults = make(map[string]FaultStats, len(s.faults))
+	for k, v := range s.faults {
+		st.Faults[k] = v
+	}
+
+	return st
+}
+
+// Stats returns the Stats of every Handler in the chain, keyed by the Handler's Name.
+// Handlers without a name are keyed by their index in the chain.
+func (c Chain) Stats() map[string]Stats {
+	m := make(map[string]Stats, len(c))
+	for i, h := range c {
+		name := h.Name
+		if name == "" {
+			name = "#" + strconv.Itoa(i)
+		}
+		m[name] = h.Stats()
+	}
+
+	return m
+}
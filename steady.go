@@ -0,0 +1,86 @@
+package fault
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SteadyState is a safety net of an experiment: a probe telling whether the service is still healthy,
+// such as the error rate or the latency in the metrics is below a threshold.
+// When the probe fails while the fault is injected, the injection is stopped automatically;
+// a Handler is disabled, and a Scenario is stopped. Call Handler.Enable to resume a disabled Handler.
+type SteadyState struct {
+	// Check returns an error if the service is not in the steady state. Required.
+	Check func(ctx context.Context) error
+	// Interval is how often Check is called while the fault is injected. If zero, every 10 seconds.
+	Interval time.Duration
+	// Timeout is the timeout of Check. If zero, Interval is used.
+	// Check failing by the timeout is a violation.
+	Timeout time.Duration
+	// OnViolation is called with the error of Check after the injection is stopped.
+	OnViolation func(err error)
+	// Logf logs the violation. If nil, log.Printf is used.
+	Logf func(format string, args ...interface{})
+
+	mu       sync.Mutex
+	last     time.Time
+	checking bool
+}
+
+func (s *SteadyState) interval() time.Duration {
+	if s.Interval <= 0 {
+		return 10 * time.Second
+	}
+	return s.Interval
+}
+
+// check calls Check with the timeout.
+func (s *SteadyState) check(ctx context.Context) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = s.interval()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return s.Check(ctx)
+}
+
+// violate reports the violation. name is what is stopped, such as `handler "foo"`.
+func (s *SteadyState) violate(name string, err error) {
+	logf := s.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+	logf("fault: steady state of %s is violated, stopped: %v", name, err)
+	if s.OnViolation != nil {
+		s.OnViolation(err)
+	}
+}
+
+// poll checks the steady state in background if the Interval has passed since the last check,
+// and disables h if it is violated. It is called by h when it injects a request.
+func (s *SteadyState) poll(h *Handler, now time.Time) {
+	s.mu.Lock()
+	if s.checking || now.Sub(s.last) < s.interval() {
+		s.mu.Unlock()
+		return
+	}
+	s.checking = true
+	s.mu.Unlock()
+
+	go func() {
+		err := s.check(context.Background())
+		if err != nil && !h.Disabled() {
+			h.Disable()
+			s.violate("handler "+strconv.Quote(h.Name), err)
+		}
+
+		s.mu.Lock()
+		s.checking = false
+		s.last = CurrentClock().Now()
+		s.mu.Unlock()
+	}()
+}
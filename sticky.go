@@ -0,0 +1,79 @@
+package fault
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+)
+
+// KeyFunc extracts an attribute from the request, such as a user identifier.
+// An empty string means the request does not have the attribute.
+type KeyFunc func(r *http.Request) string
+
+// HeaderKey returns a KeyFunc which extracts the value of the header.
+func HeaderKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// CookieKey returns a KeyFunc which extracts the value of the cookie.
+func CookieKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
+
+// ClientIPKey extracts the IP address of the client from the RemoteAddr of the request.
+// Headers such as X-Forwarded-For are not considered since they can be forged.
+func ClientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// StickySampler selects requests by the consistent hash of a request attribute, instead of flipping a coin per request.
+// The same users are always affected as long as the ratio is unchanged, which surfaces different client bugs
+// than per-request failures. When the ratio is increased, the already affected users stay affected.
+// Requests without the attribute are never selected.
+type StickySampler struct {
+	// Key extracts the attribute identifying the user. Required.
+	Key KeyFunc
+	// Salt is mixed into the hash. Changing it selects another set of users,
+	// so that the same users are not always the victims of every experiment.
+	Salt string
+}
+
+// Sample returns true if the hash of the attribute falls into the ratio.
+func (s *StickySampler) Sample(r *http.Request, ratio float64) bool {
+	if r == nil {
+		return false
+	}
+	k := s.Key(r)
+	if k == "" {
+		return false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(s.Salt))
+	h.Write([]byte{0})
+	h.Write([]byte(k))
+	// Use the upper 53 bits to get a uniform float64 in [0, 1).
+	return float64(mix64(h.Sum64())>>11)/(1<<53) < ratio
+}
+
+// mix64 is the finalizer of MurmurHash3. FNV alone does not spread similar keys well enough in the upper bits.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
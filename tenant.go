@@ -0,0 +1,42 @@
+package fault
+
+import "net/http"
+
+// Tenants injects different faults per tenant, so that chaos is applied only to the designated internal or test
+// tenants, each with its own faults and ratios, while the requests of the other tenants are untouched.
+//
+//	tenants := &fault.Tenants{
+//		Tenant: fault.HeaderKey("X-Tenant-Id"),
+//		Faults: map[string]fault.Middleware{
+//			"acme-test":     fault.New(&fault.Delay{Duration: time.Second}, 0.5),
+//			"loadtest-corp": fault.New(&fault.Error{StatusCode: 503}, 0.1),
+//		},
+//	}
+//	http.ListenAndServe(":8080", tenants.Handler(mux))
+//
+// The tenant can also be taken from a claim of the JWT by JWTClaimKey, or by any KeyFunc.
+// A Reloader can be given per tenant to configure the faults of the tenant by a Config.
+type Tenants struct {
+	// Tenant extracts the tenant of a request. Required.
+	Tenant KeyFunc
+	// Faults maps the tenants to the faults injected into their requests.
+	Faults map[string]Middleware
+}
+
+// Handler wraps the given handler with the faults of the tenants.
+func (ts *Tenants) Handler(next http.Handler) http.Handler {
+	wrapped := make(map[string]http.Handler, len(ts.Faults))
+	for t, m := range ts.Faults {
+		wrapped[t] = m.Handler(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t := ts.Tenant(r); t != "" {
+			if h, ok := wrapped[t]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
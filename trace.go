@@ -0,0 +1,88 @@
+package fault
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Baggage returns a Matcher matching the requests whose W3C baggage header has the member key with value,
+// such as Baggage("chaos", "payments-delay"). If value is empty, any value matches.
+// Since the baggage is propagated along the trace, an experiment can target one synthetic user journey across
+// the services, by setting the member at the entrance.
+func Baggage(key, value string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		for _, h := range r.Header.Values("Baggage") {
+			for _, member := range strings.Split(h, ",") {
+				// Properties of the member follow ";", which are not the value.
+				if i := strings.IndexByte(member, ';'); i >= 0 {
+					member = member[:i]
+				}
+				k, v, ok := cutTrimmed(member, "=")
+				if !ok || k != key {
+					continue
+				}
+				if uv, err := url.PathUnescape(v); err == nil {
+					v = uv
+				}
+				if value == "" || v == value {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// TraceState returns a Matcher matching the requests whose W3C tracestate header has the entry key with value,
+// such as TraceState("chaos", "on"). If value is empty, any value matches.
+func TraceState(key, value string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		for _, h := range r.Header.Values("Tracestate") {
+			for _, entry := range strings.Split(h, ",") {
+				k, v, ok := cutTrimmed(entry, "=")
+				if ok && k == key && (value == "" || v == value) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// cutTrimmed slices s around the first sep, trimming the spaces around both.
+func cutTrimmed(s, sep string) (before, after string, found bool) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+len(sep):]), true
+}
+
+// SampledTrace returns a Matcher matching the requests of the sampled traces, by the sampled flag of
+// the W3C traceparent header, so that every injected fault has the full trace as its evidence.
+// Requests without a valid traceparent, such as the ones starting a trace, do not match.
+//
+// When the tracing middleware runs before the Handler, the sampling decision of the active span can be used
+// instead, which also covers the traces started by the service. For example, with OpenTelemetry:
+//
+//	fault.MatcherFunc(func(r *http.Request) bool {
+//		return trace.SpanContextFromContext(r.Context()).IsSampled()
+//	})
+func SampledTrace() Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		// version "-" trace-id "-" parent-id "-" trace-flags, such as
+		// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+		parts := strings.Split(strings.TrimSpace(r.Header.Get("Traceparent")), "-")
+		if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[3]) != 2 {
+			return false
+		}
+		// Version 00 has exactly four fields, while the later versions may append more.
+		if parts[0] == "00" && len(parts) != 4 || parts[1] == strings.Repeat("0", 32) {
+			return false
+		}
+		flags, err := strconv.ParseUint(parts[3], 16, 8)
+		return err == nil && flags&0x01 == 1
+	})
+}
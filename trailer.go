@@ -0,0 +1,116 @@
+package fault
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TrailerError is how BadTrailer corrupts the trailers.
+type TrailerError int
+
+const (
+	// TrailerOmit announces the trailers in the Trailer header but never sends them.
+	TrailerOmit TrailerError = iota
+	// TrailerUnannounced sends the trailers without announcing them in the Trailer header.
+	TrailerUnannounced
+)
+
+// trailerErrors is the names of TrailerError, used by the effect.
+var trailerErrors = map[string]TrailerError{
+	"omit":        TrailerOmit,
+	"unannounced": TrailerUnannounced,
+}
+
+// DefaultTrailerName is the trailer BadTrailer uses if Names is empty.
+const DefaultTrailerName = "X-Fault-Trailer"
+
+// BadTrailer corrupts the trailers of the response of the next handler, so that gRPC over HTTP/1 and
+// the streaming clients relying on the trailer semantics can be tested.
+// The trailers of the next handler, declared by the Trailer header or by the http.TrailerPrefix keys,
+// are omitted or sent unannounced, together with Names.
+//
+// Trailers are sent only in the chunked encoding over HTTP/1.1, so Content-Length of the response is removed.
+type BadTrailer struct {
+	// Error is how the trailers are corrupted.
+	Error TrailerError
+	// Names is the trailers announced or sent in addition to the ones of the next handler.
+	// The unannounced ones have the value "fault". If empty, DefaultTrailerName is used.
+	Names []string
+}
+
+// Handle corrupts the trailers of the response.
+func (f *BadTrailer) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	names := f.Names
+	if len(names) == 0 {
+		names = []string{DefaultTrailerName}
+	}
+	var announced []string
+	hw := &headerWriter{ResponseWriter: w, rewrite: func(h http.Header) {
+		h.Del("Content-Length")
+		announced = trailerNames(h)
+		switch f.Error {
+		case TrailerOmit:
+			for _, name := range names {
+				h.Add("Trailer", name)
+			}
+		case TrailerUnannounced:
+			h.Del("Trailer")
+			// net/http chunks the response only if a trailer is known when the header is written.
+			for _, name := range names {
+				h.Set(http.TrailerPrefix+http.CanonicalHeaderKey(name), "fault")
+			}
+		}
+	}}
+	next.ServeHTTP(hw, r)
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+
+	// The trailers are taken from the header once the handler returns.
+	h := w.Header()
+	switch f.Error {
+	case TrailerOmit:
+		for k := range h {
+			if strings.HasPrefix(k, http.TrailerPrefix) {
+				delete(h, k)
+			}
+		}
+		for _, name := range append(announced, names...) {
+			h.Del(name)
+		}
+	case TrailerUnannounced:
+		for _, name := range announced {
+			if vs := h.Values(name); len(vs) > 0 {
+				h[http.TrailerPrefix+http.CanonicalHeaderKey(name)] = vs
+				h.Del(name)
+			}
+		}
+	}
+}
+
+// trailerNames returns the names the Trailer header announces.
+func trailerNames(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Trailer") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// parseTrailerError parses the name of a TrailerError. An empty name is TrailerOmit.
+func parseTrailerError(s string) (TrailerError, error) {
+	if s == "" {
+		return TrailerOmit, nil
+	}
+	e, ok := trailerErrors[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid error %s", strconv.Quote(s))
+	}
+	return e, nil
+}
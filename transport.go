@@ -0,0 +1,349 @@
+package fault
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Transport injects faults into outgoing requests of an HTTP client.
+// The same faults as the server side are used: the fault sees the outgoing request, and the actual round trip
+// takes the place of the next handler. For example, Delay delays the request, Error returns a synthetic response
+// without calling the server, and Abort makes RoundTrip fail as if the connection is closed by the server.
+// Requests which are not injected are sent by Base as they are.
+//
+// The faults writing raw responses, such as MalformedChunked and ContentLengthMismatch, are parsed by net/http
+// as the response from the server, and the trailers, including the ones BadTrailer corrupts, are set to
+// the Trailer of the response as the client would receive them.
+type Transport struct {
+	// Base is used to make the actual round trips. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Handler decides on the requests and holds the fault. Required.
+	Handler *Handler
+	// Hosts scopes the faults to the requests to the hosts matching any of the patterns, such as
+	// "payments.internal" and "*.example.com:8443", see Host. If empty, the requests to every host are faulted.
+	// Unlike Host in the Matchers of Handler, the requests to the other hosts are sent by Base without
+	// being evaluated at all, so they are not counted in the stats nor passed to the Hooks.
+	Hosts []string
+}
+
+// Transport returns a RoundTripper applying every Handler in the chain to the outgoing requests, in order.
+func (c Chain) Transport(base http.RoundTripper) http.RoundTripper {
+	return c.transport(base, nil)
+}
+
+// transport is Transport scoped to the hosts.
+func (c Chain) transport(base http.RoundTripper, hosts []string) http.RoundTripper {
+	for i := len(c) - 1; i >= 0; i-- {
+		base = &Transport{Base: base, Handler: c[i], Hosts: hosts}
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base
+}
+
+// Client returns a copy of base whose Transport applies every Handler in faults to the outgoing requests, in order,
+// so that the faults are injected into all the calls of a client by swapping its constructor in the tests.
+// If base is nil, a zero http.Client is used. To fault only the calls to some hosts, add Host to the Matchers
+// of the Handlers, or use ClientFor.
+//
+//	h := fault.New(&fault.Error{StatusCode: 503}, 0.5)
+//	h.Matchers = []fault.Matcher{fault.Host("payments.internal")}
+//	client := fault.Client(nil, h)
+func Client(base *http.Client, faults ...*Handler) *http.Client {
+	return ClientFor(base, nil, faults...)
+}
+
+// ClientFor is Client whose faults are scoped to the hosts matching any of the patterns, see Transport.Hosts.
+func ClientFor(base *http.Client, hosts []string, faults ...*Handler) *http.Client {
+	c := &http.Client{}
+	if base != nil {
+		*c = *base
+	}
+	c.Transport = Chain(faults).transport(c.Transport, hosts)
+	return c
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.Hosts) > 0 && !matchHosts(t.Hosts, req) {
+		return t.base().RoundTrip(req)
+	}
+
+	o := t.Handler.Evaluate(req)
+	if o.Decision != Inject {
+		return t.base().RoundTrip(req)
+	}
+
+	return roundTrip(req, t.base(), func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		t.Handler.inject(o, w, r, next)
+	})
+}
+
+// CloseIdleConnections closes the idle connections of Base if it supports.
+func (t *Transport) CloseIdleConnections() {
+	if c, ok := t.base().(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// roundTripKey is the context key of the roundTripWriter of the outgoing request,
+// with which the faults fail the round trip with an error.
+type roundTripKey struct{}
+
+// roundTrip runs the server-side handler h against the outgoing request.
+// The actual round trip by base is given to h as the next handler.
+// The response written by h is streamed back as the *http.Response.
+func roundTrip(req *http.Request, base http.RoundTripper, h func(w http.ResponseWriter, r *http.Request, next http.Handler)) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	w := &roundTripWriter{
+		req:    req,
+		header: http.Header{},
+		pw:     pw,
+		body:   pr,
+		ready:  make(chan struct{}),
+	}
+
+	proxied := false
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		proxied = true
+		resp, err := base.RoundTrip(r)
+		if err != nil {
+			w.fail(err)
+			return
+		}
+		defer resp.Body.Close()
+
+		for k, vs := range resp.Header {
+			rw.Header()[k] = vs
+		}
+		for k := range resp.Trailer {
+			rw.Header().Add("Trailer", k)
+		}
+		rw.WriteHeader(resp.StatusCode)
+		flusher, _ := rw.(http.Flusher)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				if _, werr := rw.Write(buf[:n]); werr != nil {
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			if err == io.EOF {
+				for k, vs := range resp.Trailer {
+					rw.Header()[k] = vs
+				}
+				return
+			}
+			if err != nil {
+				w.fail(err)
+				return
+			}
+		}
+	})
+
+	go func() {
+		defer func() {
+			v := recover()
+			if w.hijacked {
+				// The response is parsed from the hijacked connection.
+				return
+			}
+			if v != nil {
+				if v == http.ErrAbortHandler {
+					// The server closed the connection; the client sees an EOF.
+					w.fail(io.EOF)
+					return
+				}
+				w.fail(fmt.Errorf("fault: panic in fault: %v", v))
+				return
+			}
+			w.finish()
+		}()
+		defer func() {
+			// RoundTrip must close the request body even if it is not sent.
+			if !proxied && req.Body != nil {
+				req.Body.Close()
+			}
+		}()
+		h(w, req.WithContext(context.WithValue(req.Context(), roundTripKey{}, w)), next)
+	}()
+
+	<-w.ready
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.resp, nil
+}
+
+// roundTripWriter is a ResponseWriter turning the written response into an *http.Response.
+// The response is ready once the header is written, and the body is streamed through a pipe.
+// If the connection is hijacked, the response is parsed from what is written to it instead.
+type roundTripWriter struct {
+	req      *http.Request
+	header   http.Header
+	pw       *io.PipeWriter
+	body     io.ReadCloser
+	hijacked bool
+
+	once  sync.Once
+	ready chan struct{}
+	resp  *http.Response
+	err   error
+}
+
+func (w *roundTripWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *roundTripWriter) WriteHeader(code int) {
+	if w.hijacked {
+		return
+	}
+	w.once.Do(func() {
+		contentLength := int64(-1)
+		if cl, err := strconv.ParseInt(w.header.Get("Content-Length"), 10, 64); err == nil {
+			contentLength = cl
+		}
+		// The announced trailers are set once the handler returns, as net/http does.
+		header := w.header.Clone()
+		var trailer http.Header
+		for _, name := range trailerNames(header) {
+			if trailer == nil {
+				trailer = http.Header{}
+			}
+			trailer[http.CanonicalHeaderKey(name)] = nil
+		}
+		header.Del("Trailer")
+		for k := range header {
+			if strings.HasPrefix(k, http.TrailerPrefix) {
+				delete(header, k)
+			}
+		}
+		w.resp = &http.Response{
+			Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+			StatusCode:    code,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          w.body,
+			ContentLength: contentLength,
+			Trailer:       trailer,
+			Request:       w.req,
+		}
+		close(w.ready)
+	})
+}
+
+func (w *roundTripWriter) Write(b []byte) (int, error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(b)
+}
+
+// Hijack returns a connection whose other end is read as the raw HTTP/1.x response to the request,
+// so that the malformed responses reach the client as they would from the server.
+func (w *roundTripWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.hijacked {
+		return nil, nil, http.ErrHijacked
+	}
+	if w.resp != nil || w.err != nil {
+		return nil, nil, errors.New("fault: hijack after the response is written")
+	}
+	w.hijacked = true
+
+	server, client := net.Pipe()
+	go func() {
+		resp, err := http.ReadResponse(bufio.NewReader(client), w.req)
+		if err != nil {
+			client.Close()
+			w.once.Do(func() {
+				w.err = err
+				close(w.ready)
+			})
+			return
+		}
+		// The rest of the written data, such as the garbage after the body, is discarded on Close.
+		resp.Body = &connBody{ReadCloser: resp.Body, conn: client}
+		w.once.Do(func() {
+			w.resp = resp
+			close(w.ready)
+		})
+	}()
+
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+// connBody is the body of the response read from a hijacked connection, closing it on Close.
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.conn.Close()
+	return err
+}
+
+// Flush is a no-op since the body is streamed as it is written.
+func (w *roundTripWriter) Flush() {}
+
+// fail makes the round trip fail with the error. If the response is already returned, reading the body fails.
+func (w *roundTripWriter) fail(err error) {
+	headerWritten := true
+	w.once.Do(func() {
+		headerWritten = false
+		w.err = err
+		close(w.ready)
+	})
+	if headerWritten && errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	w.pw.CloseWithError(err)
+}
+
+// finish completes the response with the trailers in the header.
+func (w *roundTripWriter) finish() {
+	w.WriteHeader(http.StatusOK)
+	if resp := w.resp; resp != nil {
+		for name := range resp.Trailer {
+			if vs := w.header.Values(name); len(vs) > 0 {
+				resp.Trailer[name] = vs
+			}
+		}
+		for k, vs := range w.header {
+			if !strings.HasPrefix(k, http.TrailerPrefix) {
+				continue
+			}
+			if resp.Trailer == nil {
+				resp.Trailer = http.Header{}
+			}
+			resp.Trailer[http.CanonicalHeaderKey(strings.TrimPrefix(k, http.TrailerPrefix))] = vs
+		}
+	}
+	w.pw.Close()
+}
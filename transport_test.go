@@ -0,0 +1,86 @@
+package fault
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransportRawResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "0123456789")
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name    string
+		f       Fault
+		body    string
+		readErr bool
+	}{
+		{"bad chunk size", &MalformedChunked{Error: ChunkedBadSize}, "", true},
+		{"missing terminal chunk", &MalformedChunked{Error: ChunkedMissingTerminal}, "0123456789", true},
+		{"larger content length", &ContentLengthMismatch{Delta: 5}, "0123456789", true},
+		{"smaller content length", &ContentLengthMismatch{Delta: -4}, "012345", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := Client(nil, New(tc.f, 1))
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("Get() = %v, want the response to be parsed", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+
+			b, err := io.ReadAll(resp.Body)
+			if (err != nil) != tc.readErr {
+				t.Errorf("reading the body = %v, want error %v", err, tc.readErr)
+			}
+			if string(b) != tc.body {
+				t.Errorf("body = %q, want %q", b, tc.body)
+			}
+		})
+	}
+}
+
+func TestTransportTrailer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Status")
+		io.WriteString(w, "ok")
+		w.Header().Set("X-Status", "0")
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name    string
+		f       Fault
+		trailer http.Header
+	}{
+		{"proxied", &Delay{}, http.Header{"X-Status": {"0"}}},
+		{"omitted", &BadTrailer{Error: TrailerOmit}, http.Header{"X-Status": nil, DefaultTrailerName: nil}},
+		{"unannounced", &BadTrailer{Error: TrailerUnannounced}, http.Header{"X-Status": {"0"}, DefaultTrailerName: {"fault"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := Client(nil, New(tc.f, 1))
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if _, err := io.ReadAll(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Trailer) != len(tc.trailer) {
+				t.Errorf("Trailer = %v, want %v", resp.Trailer, tc.trailer)
+			}
+			for k, want := range tc.trailer {
+				if got, ok := resp.Trailer[k]; !ok || len(got) != len(want) || len(want) > 0 && got[0] != want[0] {
+					t.Errorf("Trailer[%s] = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
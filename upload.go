@@ -0,0 +1,57 @@
+package fault
+
+import (
+	"io"
+	"net/http"
+)
+
+// SlowUpload feeds the request body at the bandwidth, so that ReadTimeout of the servers and their protections
+// against slow clients can be tested from the client side with Transport:
+//
+//	client := &http.Client{Transport: &fault.Transport{Handler: fault.New(&fault.SlowUpload{Bandwidth: 512}, 1)}}
+//
+// On the server side, the next handler reads the body as if it is sent by a slow client.
+type SlowUpload struct {
+	// Bandwidth caps the body in bytes per second. Zero means no cap.
+	Bandwidth int
+}
+
+// Handle slows down the body of the request.
+func (f *SlowUpload) Handle(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if f.Bandwidth <= 0 || r.Body == nil || r.Body == http.NoBody {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	// The request is copied since a RoundTripper must not modify it.
+	r = r.WithContext(r.Context())
+	r.Body = &slowBody{ReadCloser: r.Body, bw: f.Bandwidth}
+	if getBody := r.GetBody; getBody != nil {
+		r.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &slowBody{ReadCloser: body, bw: f.Bandwidth}, nil
+		}
+	}
+	next.ServeHTTP(w, r)
+}
+
+// Validate checks Bandwidth is not negative.
+func (f *SlowUpload) Validate() error {
+	return validateCount("bandwidth", f.Bandwidth)
+}
+
+// slowBody is a request body read at the bandwidth.
+type slowBody struct {
+	io.ReadCloser
+	bw int
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	p = p[:min(len(p), chunkSize(b.bw))]
+	n, err := b.ReadCloser.Read(p)
+	throttle(n, b.bw)
+	return n, err
+}
@@ -0,0 +1,50 @@
+package fault
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Validator is implemented by the faults which can check their fields, so that a mistake is reported on setup
+// rather than silently doing nothing or panicking in WriteHeader at request time.
+// The error describes the invalid field, such as "status code 42 must be in [100, 599]".
+type Validator interface {
+	Validate() error
+}
+
+// validateFault validates f if it is a Validator.
+func validateFault(f Fault) error {
+	if v, ok := f.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+func validateRatio(name string, r float64) error {
+	if math.IsNaN(r) || r < 0 || r > 1 {
+		return fmt.Errorf("%s %v must be in [0, 1]", name, r)
+	}
+	return nil
+}
+
+func validateStatus(code int) error {
+	if code < 100 || code > 599 {
+		return fmt.Errorf("status code %d must be in [100, 599]", code)
+	}
+	return nil
+}
+
+func validateDuration(name string, d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("%s %v must not be negative", name, d)
+	}
+	return nil
+}
+
+func validateCount(name string, n int) error {
+	if n < 0 {
+		return fmt.Errorf("%s %d must not be negative", name, n)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package fault
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	nan := math.NaN()
+	for _, tc := range []struct {
+		name string
+		f    Fault
+		ok   bool
+	}{
+		{"delay", &Delay{Duration: time.Second}, true},
+		{"negative delay", &Delay{Duration: -time.Second}, false},
+		{"error", &Error{StatusCode: 503}, true},
+		{"invalid status", &Error{StatusCode: 42}, false},
+		{"grpc error", &GRPCError{Code: 14}, true},
+		{"grpc ok", &GRPCError{}, false},
+		{"sse", &SSE{DropRatio: 0.5}, true},
+		{"sse nan ratio", &SSE{DropRatio: nan}, false},
+		{"cors nan ratio", &BadCORS{PreflightRatio: nan}, false},
+		{"partial nan", &PartialResponse{Fraction: nan}, false},
+		{"distribution nan", &StatusDistribution{Weights: map[int]float64{503: nan}}, false},
+		{"amplification nan", &LatencyAmplification{Factor: nan}, false},
+		{"baseline nan", &BaselineDelay{Baseline: &Baseline{}, Factor: nan}, false},
+		{"sequence", sequence{&Delay{}, &Error{StatusCode: 42}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateFault(tc.f)
+			if ok := err == nil; ok != tc.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestHandlerValidateRatio(t *testing.T) {
+	for _, ratio := range []float64{-0.1, 1.1, math.NaN()} {
+		if err := New(&Delay{}, ratio).Validate(); err == nil {
+			t.Errorf("Validate() with ratio %v = nil, want an error", ratio)
+		}
+	}
+	h := New(&Delay{}, 0.5)
+	h.MethodRatios = map[string]float64{"POST": math.NaN()}
+	if err := h.Validate(); err == nil {
+		t.Error("Validate() with NaN method ratio = nil, want an error")
+	}
+}
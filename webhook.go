@@ -0,0 +1,351 @@
+package fault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Webhook POSTs the events of the faults and the experiments to a URL, so that chat and incident tooling
+// can follow along. Watch and WatchScenario register it to a Handler and a Scenario:
+//
+//	wh := &fault.Webhook{URL: "https://hooks.example.com/chaos", Interval: time.Minute}
+//	wh.Watch(h)
+//	wh.WatchScenario(s)
+//
+// The events are sent in background in order. When the events come faster than they are sent,
+// the ones exceeding the queue are dropped; the first drop is logged, and the number of the dropped events
+// is logged once the queue has room again.
+type Webhook struct {
+	// URL is the endpoint the events are posted to. Required.
+	URL string
+	// Header is added to every request, such as Authorization.
+	Header http.Header
+	// Client is used to post the events. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Body returns the value encoded as the JSON body of the event. If nil, the Event itself is encoded.
+	// For example, for a Slack incoming webhook:
+	//
+	//	Body: func(e fault.Event) interface{} { return map[string]string{"text": e.String()} },
+	Body func(e Event) interface{}
+	// Interval limits the "injected" events of a Handler to one per Interval, which reports the number of
+	// the injections since the last one. The injections after the last event are posted when the Interval
+	// passes, even if no injection follows. If zero, an event is posted as soon as the previous one of the
+	// Handler leaves the queue, reporting the injections while it waited.
+	Interval time.Duration
+	// Logf reports the failures to post. If nil, log.Printf is used.
+	Logf func(format string, args ...interface{})
+
+	once    sync.Once
+	events  chan func() Event
+	mu      sync.Mutex
+	dropped int
+}
+
+// Event types posted by Webhook.
+const (
+	EventScenarioStarted     = "scenario_started"
+	EventPhaseStarted        = "phase_started"
+	EventScenarioFinished    = "scenario_finished"
+	EventInjected            = "injected"
+	EventBudgetExhausted     = "budget_exhausted"
+	EventSteadyStateViolated = "steady_state_violated"
+)
+
+// Event is an event posted by Webhook.
+type Event struct {
+	// Type is the type of the event, such as "injected".
+	Type string `json:"type"`
+	// Time is when the event happened.
+	Time time.Time `json:"time"`
+	// Handler is the name of the Handler, for the events of a Handler.
+	Handler string `json:"handler,omitempty"`
+	// Scenario is the name of the Scenario, for the events of a Scenario.
+	Scenario string `json:"scenario,omitempty"`
+	// Phase is the name of the phase, for "phase_started".
+	Phase string `json:"phase,omitempty"`
+	// Fault is the name of the fault, see FaultName.
+	Fault string `json:"fault,omitempty"`
+	// Count is the number of the injections the "injected" event reports.
+	Count int `json:"count,omitempty"`
+	// Method, URL and RequestID describe the last injected request, for "injected".
+	Method    string `json:"method,omitempty"`
+	URL       string `json:"url,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Error is the error of the event, such as the failed check of "steady_state_violated".
+	Error string `json:"error,omitempty"`
+	// Report is the Report of the finished Scenario, for "scenario_finished".
+	Report *Report `json:"report,omitempty"`
+}
+
+// String returns a one-line summary of the event for humans.
+func (e Event) String() string {
+	s := "fault: " + e.Type
+	if e.Scenario != "" {
+		s += " scenario=" + strconv.Quote(e.Scenario)
+	}
+	if e.Phase != "" {
+		s += " phase=" + strconv.Quote(e.Phase)
+	}
+	if e.Handler != "" {
+		s += " handler=" + strconv.Quote(e.Handler)
+	}
+	if e.Fault != "" {
+		s += " fault=" + e.Fault
+	}
+	if e.Count > 0 {
+		s += " count=" + strconv.Itoa(e.Count)
+	}
+	if e.URL != "" {
+		s += " request=" + strconv.Quote(e.Method+" "+e.URL)
+	}
+	if e.RequestID != "" {
+		s += " request_id=" + strconv.Quote(e.RequestID)
+	}
+	if e.Error != "" {
+		s += " error=" + strconv.Quote(e.Error)
+	}
+	return s
+}
+
+// Watch posts the events of the Handler: "injected", "budget_exhausted" once the Budget is exhausted,
+// and "steady_state_violated" if it has a SteadyState. It must be called before the Handler serves requests.
+func (wh *Webhook) Watch(h *Handler) {
+	var (
+		mu        sync.Mutex
+		count     int
+		latest    Event
+		last      time.Time
+		queued    bool
+		cancel    context.CancelFunc
+		exhausted bool
+	)
+	// take returns the event of the latest injection, reporting the injections since the last event.
+	take := func() Event {
+		e := latest
+		e.Count = count
+		count = 0
+		return e
+	}
+	h.Hooks = append(h.Hooks, func(o Outcome) {
+		switch {
+		case o.Decision == Inject:
+			mu.Lock()
+			exhausted = false
+			count++
+			latest = Event{Type: EventInjected, Time: o.Time, Handler: h.Name, Fault: FaultName(o.Fault),
+				Method: o.Request.Method, URL: o.Request.URL.String(), RequestID: o.RequestID}
+			switch {
+			case wh.Interval <= 0:
+				// The event is taken when it leaves the queue, so the injections while it waits are reported
+				// by it rather than by their own events.
+				if queued {
+					mu.Unlock()
+					return
+				}
+				queued = true
+				mu.Unlock()
+				ok := wh.enqueue(EventInjected, func() Event {
+					mu.Lock()
+					defer mu.Unlock()
+					queued = false
+					return take()
+				})
+				if !ok {
+					// The injections are reported by the next event.
+					mu.Lock()
+					queued = false
+					mu.Unlock()
+				}
+			case o.Time.Sub(last) < wh.Interval:
+				if cancel == nil {
+					var ctx context.Context
+					ctx, cancel = context.WithCancel(context.Background())
+					go wh.flush(ctx, last.Add(wh.Interval).Sub(o.Time), func() (Event, bool) {
+						mu.Lock()
+						defer mu.Unlock()
+						if ctx.Err() != nil {
+							return Event{}, false
+						}
+						cancel()
+						cancel = nil
+						last = last.Add(wh.Interval)
+						return take(), true
+					})
+				}
+				mu.Unlock()
+			default:
+				if cancel != nil {
+					cancel()
+					cancel = nil
+				}
+				last = o.Time
+				e := take()
+				mu.Unlock()
+				wh.send(e)
+			}
+		case o.Reason == "budget exhausted":
+			mu.Lock()
+			first := !exhausted
+			exhausted = true
+			mu.Unlock()
+			if first {
+				wh.send(Event{Type: EventBudgetExhausted, Time: o.Time, Handler: h.Name, Fault: FaultName(o.Fault)})
+			}
+		}
+	})
+
+	if s := h.SteadyState; s != nil {
+		onViolation := s.OnViolation
+		s.OnViolation = func(err error) {
+			wh.send(Event{Type: EventSteadyStateViolated, Time: CurrentClock().Now(), Handler: h.Name, Error: err.Error()})
+			if onViolation != nil {
+				onViolation(err)
+			}
+		}
+	}
+}
+
+// WatchScenario posts the events of the Scenario: "scenario_started", "phase_started", "scenario_finished"
+// with the Report, and "steady_state_violated" if it has a SteadyState.
+// It must be called before the Scenario runs.
+func (wh *Webhook) WatchScenario(s *Scenario) {
+	onStart, onPhase, onFinish := s.OnStart, s.OnPhase, s.OnFinish
+	s.OnStart = func() {
+		wh.send(Event{Type: EventScenarioStarted, Time: CurrentClock().Now(), Scenario: s.Name})
+		if onStart != nil {
+			onStart()
+		}
+	}
+	s.OnPhase = func(p Phase) {
+		e := Event{Type: EventPhaseStarted, Time: CurrentClock().Now(), Scenario: s.Name, Phase: p.Name}
+		if p.Handler != nil {
+			e.Handler = p.Handler.Name
+			e.Fault = FaultName(p.Handler.f)
+		}
+		wh.send(e)
+		if onPhase != nil {
+			onPhase(p)
+		}
+	}
+	s.OnFinish = func(r Report) {
+		wh.send(Event{Type: EventScenarioFinished, Time: r.End, Scenario: s.Name, Error: r.Error, Report: &r})
+		if onFinish != nil {
+			onFinish(r)
+		}
+	}
+
+	if ss := s.SteadyState; ss != nil {
+		onViolation := ss.OnViolation
+		ss.OnViolation = func(err error) {
+			wh.send(Event{Type: EventSteadyStateViolated, Time: CurrentClock().Now(), Scenario: s.Name, Error: err.Error()})
+			if onViolation != nil {
+				onViolation(err)
+			}
+		}
+	}
+}
+
+// flush sends the event take returns after d, unless ctx is canceled first.
+func (wh *Webhook) flush(ctx context.Context, d time.Duration, take func() (Event, bool)) {
+	if CurrentClock().Sleep(ctx, d) != nil {
+		return
+	}
+	if e, ok := take(); ok {
+		wh.send(e)
+	}
+}
+
+// send queues the event.
+func (wh *Webhook) send(e Event) {
+	wh.enqueue(e.Type, func() Event { return e })
+}
+
+// enqueue queues the event of the type, which take returns when it is posted.
+// It returns false if the event is dropped.
+func (wh *Webhook) enqueue(typ string, take func() Event) bool {
+	wh.once.Do(func() {
+		wh.events = make(chan func() Event, 100)
+		go wh.loop()
+	})
+
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	select {
+	case wh.events <- take:
+		if wh.dropped > 0 {
+			wh.logf("fault: webhook %s: %d events were dropped as the queue was full", wh.URL, wh.dropped)
+			wh.dropped = 0
+		}
+		return true
+	default:
+		if wh.dropped == 0 {
+			wh.logf("fault: webhook %s: queue is full, %s event is dropped", wh.URL, typ)
+		}
+		wh.dropped++
+		return false
+	}
+}
+
+func (wh *Webhook) loop() {
+	for take := range wh.events {
+		e := take()
+		if err := wh.post(e); err != nil {
+			wh.logf("fault: webhook %s: post %s event: %v", wh.URL, e.Type, err)
+		}
+	}
+}
+
+func (wh *Webhook) post(e Event) error {
+	var v interface{} = e
+	if wh.Body != nil {
+		v = wh.Body(e)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, vs := range wh.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := wh.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (wh *Webhook) logf(format string, args ...interface{}) {
+	logf := wh.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+	logf(format, args...)
+}
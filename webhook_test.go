@@ -0,0 +1,127 @@
+package fault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// webhookServer returns a server sending the posted events to the channel after release returns.
+func webhookServer(t *testing.T, release func()) (*httptest.Server, chan Event) {
+	events := make(chan Event, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		events <- e
+		release()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, events
+}
+
+func receive(t *testing.T, events chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event is posted")
+		return Event{}
+	}
+}
+
+func TestWebhookIntervalFlush(t *testing.T) {
+	clk := NewFakeClock(epoch)
+	SetClock(clk)
+	defer SetClock(nil)
+
+	srv, events := webhookServer(t, func() {})
+	h := New(nop, 1)
+	wh := &Webhook{URL: srv.URL, Interval: time.Minute}
+	wh.Watch(h)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	h.Evaluate(r)
+	if e := receive(t, events); e.Type != EventInjected || e.Count != 1 {
+		t.Errorf("first event = %+v, want injected with count 1", e)
+	}
+
+	// The tail of the burst is posted when the Interval passes, even if no injection follows.
+	clk.Add(10 * time.Second)
+	h.Evaluate(r)
+	h.Evaluate(r)
+	waitSleepers(t, clk, 1)
+	clk.Add(50 * time.Second)
+	if e := receive(t, events); e.Type != EventInjected || e.Count != 2 {
+		t.Errorf("flushed event = %+v, want injected with count 2", e)
+	}
+
+	// The Interval counts from the flushed event.
+	clk.Add(30 * time.Second)
+	h.Evaluate(r)
+	waitSleepers(t, clk, 1)
+	clk.Add(30 * time.Second)
+	if e := receive(t, events); e.Count != 1 {
+		t.Errorf("flushed event = %+v, want count 1", e)
+	}
+}
+
+func TestWebhookCoalesce(t *testing.T) {
+	release := make(chan struct{})
+	srv, events := webhookServer(t, func() { <-release })
+	h := New(nop, 1)
+	var logs int
+	wh := &Webhook{URL: srv.URL, Logf: func(string, ...interface{}) { logs++ }}
+	wh.Watch(h)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	h.Evaluate(r)
+	if e := receive(t, events); e.Count != 1 {
+		t.Errorf("first event = %+v, want count 1", e)
+	}
+
+	// The injections while the event waits in the queue are reported by it.
+	for i := 0; i < 1000; i++ {
+		h.Evaluate(r)
+	}
+	close(release)
+	if e := receive(t, events); e.Count != 1000 {
+		t.Errorf("second event = %+v, want count 1000", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if logs != 0 {
+		t.Errorf("%d lines are logged, want none", logs)
+	}
+}
+
+func TestWebhookDropped(t *testing.T) {
+	release := make(chan struct{})
+	srv, events := webhookServer(t, func() { <-release })
+	var logs []string
+	wh := &Webhook{URL: srv.URL, Logf: func(format string, args ...interface{}) { logs = append(logs, format) }}
+
+	wh.send(Event{Type: EventPhaseStarted})
+	receive(t, events)
+	for i := 0; i < 150; i++ {
+		wh.send(Event{Type: EventPhaseStarted})
+	}
+	if len(logs) != 1 {
+		t.Errorf("%d lines are logged for the dropped events, want 1", len(logs))
+	}
+	close(release)
+	for i := 0; i < 100; i++ {
+		receive(t, events)
+	}
+	wh.send(Event{Type: EventPhaseStarted})
+	if len(logs) != 2 {
+		t.Errorf("%d lines are logged, want 2 reporting the number of the dropped events", len(logs))
+	}
+}